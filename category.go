@@ -0,0 +1,79 @@
+package release
+
+import (
+	"regexp"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+const (
+	categoryFromComment  = "comment"
+	categoryFromSections = "sections"
+	categoryFromMetadata = "metadata"
+)
+
+// categoryCommentRegex matches a line with a category annotation in the changelog,
+// e.g., "<!-- category: security -->".
+var categoryCommentRegex = regexp.MustCompile(`(?im)^[ \t]*<!--\s*category:\s*(.*?)\s*-->[ \t]*\n?`)
+
+// categorySectionRegex matches headings of Keep a Changelog sections.
+var categorySectionRegex = regexp.MustCompile(`(?im)^\s*###\s+(Added|Changed|Deprecated|Removed|Fixed|Security)\s*$`)
+
+// sectionsCategory determines the category of the release from sections in its changes:
+// "security" if there is a Security section, "feature" if there is any section other
+// than Fixed, and "bugfix" if there is only a Fixed section.
+func sectionsCategory(changes string) string {
+	category := ""
+	for _, match := range categorySectionRegex.FindAllStringSubmatch(changes, -1) {
+		switch strings.ToLower(match[1]) {
+		case "security":
+			return "security"
+		case "fixed":
+			if category == "" {
+				category = "bugfix"
+			}
+		default:
+			category = "feature"
+		}
+	}
+	return category
+}
+
+// applyCategories sets Category of releases from the source configured in config.
+//
+// With "comment" source, the category is read from a "<!-- category: ... -->" line
+// in release's changes, which is then removed from changes. With "sections" source,
+// it is determined from the changelog sections of the release. With "metadata" source,
+// it is read from the "category" metadata field.
+func applyCategories(config *Config, releases []Release) errors.E {
+	for i := range releases {
+		switch config.CategoryFrom {
+		case "":
+			// Nothing to do.
+		case categoryFromComment:
+			match := categoryCommentRegex.FindStringSubmatch(releases[i].Changes)
+			if match != nil {
+				releases[i].Category = match[1]
+				releases[i].Changes = categoryCommentRegex.ReplaceAllString(releases[i].Changes, "")
+			}
+		case categoryFromSections:
+			releases[i].Category = sectionsCategory(releases[i].Changes)
+		case categoryFromMetadata:
+			if category, ok := releases[i].Meta["category"]; ok {
+				s, ok := category.(string)
+				if !ok {
+					errE := errors.New("release category in metadata is not a string")
+					errors.Details(errE)["release"] = releases[i].Tag
+					return errE
+				}
+				releases[i].Category = s
+			}
+		default:
+			errE := errors.New("unknown release category source")
+			errors.Details(errE)["source"] = config.CategoryFrom
+			return errE
+		}
+	}
+	return nil
+}
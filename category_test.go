@@ -0,0 +1,74 @@
+package release
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSectionsCategory(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		changes  string
+		category string
+	}{
+		{"", ""},
+		{"Some notes.", ""},
+		{"### Fixed\n- Bug.", "bugfix"},
+		{"### Fixed\n- Bug.\n### Added\n- Feature.", "feature"},
+		{"### Changed\n- Something.", "feature"},
+		{"### Added\n- Feature.\n### Security\n- Fix XSS.", "security"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.category, sectionsCategory(tt.changes))
+		})
+	}
+}
+
+func TestApplyCategories(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+	err := os.WriteFile(changelogPath, []byte("# Changelog\n\n## [1.0.1] - 2023-01-02\n<!-- category: hotfix -->\n### Fixed\n- Bug.\n\n"+
+		"## [1.0.0] - 2023-01-01\n### Added\n- Feature.\n"), 0o600)
+	require.NoError(t, err)
+
+	releases, _, errE := changelogReleases(changelogPath, []string{"Unreleased"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	errE = applyCategories(&Config{CategoryFrom: "comment"}, releases) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "hotfix", releases[0].Category)
+	assert.Equal(t, "### Fixed\n- Bug.", releases[0].Changes)
+	assert.Equal(t, "", releases[1].Category)
+
+	errE = applyCategories(&Config{CategoryFrom: "sections"}, releases) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "bugfix", releases[0].Category)
+	assert.Equal(t, "feature", releases[1].Category)
+
+	releases = []Release{
+		{Tag: "v1.0.0", Meta: map[string]interface{}{"category": "security"}}, //nolint:exhaustruct
+		{Tag: "v0.1.0", Meta: map[string]interface{}{"category": 1}},          //nolint:exhaustruct
+	}
+	errE = applyCategories(&Config{CategoryFrom: "metadata"}, releases[:1]) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "security", releases[0].Category)
+	errE = applyCategories(&Config{CategoryFrom: "metadata"}, releases) //nolint:exhaustruct
+	assert.EqualError(t, errE, "release category in metadata is not a string")
+
+	description, errE := releaseDescription(&Config{}, releases[0], nil) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, descriptionMarker+"\n\n**Category:** security", description)
+}
@@ -0,0 +1,378 @@
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"github.com/xanzy/go-gitlab"
+	"gitlab.com/tozd/go/errors"
+)
+
+// basicAuthTransport adds HTTP basic auth credentials to every request.
+//
+// This is used when GitLab is behind a gateway which requires basic auth.
+// GitLab API token is still sent in its own header.
+type basicAuthTransport struct {
+	Username  string
+	Password  string
+	Transport http.RoundTripper
+}
+
+func (t *basicAuthTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrip should not modify the request, so we clone it.
+	req = req.Clone(req.Context())
+	req.SetBasicAuth(t.Username, t.Password)
+	return t.Transport.RoundTrip(req) //nolint:wrapcheck
+}
+
+// headerTransport adds Header to every request.
+//
+// This is used when GitLab is behind a gateway which requires custom headers.
+type headerTransport struct {
+	Header    http.Header
+	Transport http.RoundTripper
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// RoundTrip should not modify the request, so we clone it.
+	req = req.Clone(req.Context())
+	for key, values := range t.Header {
+		req.Header[key] = append([]string{}, values...)
+	}
+	return t.Transport.RoundTrip(req) //nolint:wrapcheck
+}
+
+// requestCounter counts requests made through countingTransport.
+type requestCounter struct {
+	mu        sync.Mutex
+	total     int
+	endpoints map[string]int
+}
+
+func newRequestCounter() *requestCounter {
+	return &requestCounter{
+		mu:        sync.Mutex{},
+		total:     0,
+		endpoints: map[string]int{},
+	}
+}
+
+func (c *requestCounter) add(endpoint string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.total++
+	c.endpoints[endpoint]++
+}
+
+// Total returns the total number of requests made.
+func (c *requestCounter) Total() int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.total
+}
+
+// Endpoints returns the number of requests made per endpoint.
+func (c *requestCounter) Endpoints() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	endpoints := make(map[string]int, len(c.endpoints))
+	for endpoint, count := range c.endpoints {
+		endpoints[endpoint] = count
+	}
+	return endpoints
+}
+
+// Print prints the total number of requests made and, if verbose is true,
+// also the number of requests per endpoint.
+func (c *requestCounter) Print(verbose bool) {
+	fmt.Printf("Made %d GitLab API requests.\n", c.Total())
+	if !verbose {
+		return
+	}
+	endpoints := c.Endpoints()
+	keys := make([]string, 0, len(endpoints))
+	for endpoint := range endpoints {
+		keys = append(keys, endpoint)
+	}
+	slices.Sort(keys)
+	for _, endpoint := range keys {
+		fmt.Printf("  %d %s\n", endpoints[endpoint], endpoint)
+	}
+}
+
+// countingTransport counts all requests using Counter.
+type countingTransport struct {
+	Counter   *requestCounter
+	Transport http.RoundTripper
+}
+
+func (t *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.Counter.add(req.Method + " " + endpointPath(req.URL.EscapedPath()))
+	return t.Transport.RoundTrip(req) //nolint:wrapcheck
+}
+
+// endpointPath replaces project IDs, tag names, and numeric IDs in the GitLab API
+// path with placeholders so that requests to the same endpoint can be grouped.
+func endpointPath(path string) string {
+	segments := strings.Split(path, "/")
+	for i, segment := range segments {
+		if i == 0 {
+			continue
+		}
+		switch {
+		case segments[i-1] == "projects":
+			segments[i] = ":id"
+		case segments[i-1] == "releases":
+			segments[i] = ":tag_name"
+		case segment != "" && strings.Trim(segment, "0123456789") == "":
+			segments[i] = ":id"
+		}
+	}
+	return strings.Join(segments, "/")
+}
+
+// redactedHeaders are headers which can contain secrets and are never logged.
+var redactedHeaders = []string{"Authorization", "Private-Token", "Job-Token"} //nolint:gochecknoglobals
+
+// sensitiveHeaderWords are words which, when contained in a header name, mark
+// the header as possibly containing secrets.
+var sensitiveHeaderWords = []string{"auth", "cookie", "key", "password", "secret", "token"} //nolint:gochecknoglobals
+
+// isSensitiveHeader returns true if the header with key can contain secrets.
+func isSensitiveHeader(key string) bool {
+	if slices.Contains(redactedHeaders, http.CanonicalHeaderKey(key)) {
+		return true
+	}
+	key = strings.ToLower(key)
+	for _, word := range sensitiveHeaderWords {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
+
+// debugTransport logs all requests and responses to Writer.
+//
+// By default only the method, URL, status, and duration are logged. If Bodies is
+// true, also headers and bodies are logged. Secrets are redacted.
+type debugTransport struct {
+	Writer    io.Writer
+	Bodies    bool
+	Secrets   []string
+	Transport http.RoundTripper
+}
+
+func (t *debugTransport) redact(s string) string {
+	for _, secret := range t.Secrets {
+		if secret != "" {
+			s = strings.ReplaceAll(s, secret, "[REDACTED]")
+		}
+	}
+	return s
+}
+
+func (t *debugTransport) log(prefix string, header http.Header, body []byte) {
+	keys := make([]string, 0, len(header))
+	for key := range header {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		value := strings.Join(header[key], ", ")
+		if isSensitiveHeader(key) {
+			value = "[REDACTED]"
+		}
+		fmt.Fprintf(t.Writer, "%s %s: %s\n", prefix, key, t.redact(value))
+	}
+	if len(body) > 0 {
+		fmt.Fprintf(t.Writer, "%s\n%s\n", prefix, t.redact(string(body)))
+	}
+}
+
+func (t *debugTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	var requestBody []byte
+	if t.Bodies && req.Body != nil && req.Body != http.NoBody {
+		var err error
+		requestBody, err = io.ReadAll(req.Body)
+		req.Body.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		// RoundTrip should not modify the request, so we clone it.
+		req = req.Clone(req.Context())
+		req.Body = io.NopCloser(bytes.NewReader(requestBody))
+	}
+
+	fmt.Fprintf(t.Writer, "> %s %s\n", req.Method, t.redact(req.URL.String()))
+	if t.Bodies {
+		t.log(">", req.Header, requestBody)
+	}
+
+	start := time.Now()
+	res, err := t.Transport.RoundTrip(req)
+	duration := time.Since(start)
+	if err != nil {
+		fmt.Fprintf(t.Writer, "< error after %s: %s\n", duration, t.redact(err.Error()))
+		return nil, err //nolint:wrapcheck
+	}
+
+	fmt.Fprintf(t.Writer, "< %s (%s)\n", res.Status, duration)
+	if t.Bodies {
+		responseBody, err := io.ReadAll(res.Body)
+		res.Body.Close()
+		if err != nil {
+			return nil, errors.WithStack(err)
+		}
+		res.Body = io.NopCloser(bytes.NewReader(responseBody))
+		t.log("<", res.Header, responseBody)
+	}
+
+	return res, nil
+}
+
+// defaultAPIPrefix is the path, relative to base URL, at which GitLab API is served by default.
+const defaultAPIPrefix = "/api/v4"
+
+// apiURL returns the URL of GitLab API served at prefix (or the default one) under baseURL.
+func apiURL(baseURL, prefix string) string {
+	if prefix == "" {
+		prefix = defaultAPIPrefix
+	}
+	return strings.TrimSuffix(baseURL, "/") + "/" + strings.Trim(prefix, "/")
+}
+
+// parseHeaders parses headers in "key:value" format.
+func parseHeaders(headers []string) (http.Header, errors.E) {
+	header := http.Header{}
+	for _, h := range headers {
+		key, value, ok := strings.Cut(h, ":")
+		key = strings.TrimSpace(key)
+		if !ok || key == "" {
+			errE := errors.New(`header should be in "key:value" format`)
+			errors.Details(errE)["header"] = key
+			return nil, errE
+		}
+		header.Add(key, strings.TrimSpace(value))
+	}
+	return header, nil
+}
+
+// newPlainHTTPClient creates an HTTP client as configured in config, but only with
+// debugging and without any credentials (custom headers, basic auth, or the GitLab API
+// token), so that it can be used for requests outside of GitLab.
+//
+// If config.HTTPClient is set, it is used as the base HTTP client (it is not modified).
+func newPlainHTTPClient(config *Config) (*http.Client, errors.E) {
+	var httpClient *http.Client
+	if config.HTTPClient != nil {
+		c := *config.HTTPClient
+		httpClient = &c
+		if httpClient.Transport == nil {
+			httpClient.Transport = http.DefaultTransport
+		}
+	} else {
+		httpClient = cleanhttp.DefaultPooledClient()
+	}
+
+	if config.DebugHTTP || config.DebugHTTPBodies {
+		header, errE := parseHeaders(config.Headers)
+		if errE != nil {
+			return nil, errE
+		}
+		secrets := []string{config.Token, config.BasicAuth}
+		for key, values := range header {
+			if isSensitiveHeader(key) {
+				secrets = append(secrets, values...)
+			}
+		}
+		// We install it first so that it sees requests as they are sent.
+		httpClient.Transport = &debugTransport{
+			Writer:    os.Stderr,
+			Bodies:    config.DebugHTTPBodies,
+			Secrets:   secrets,
+			Transport: httpClient.Transport,
+		}
+	}
+
+	return httpClient, nil
+}
+
+// newHTTPClient creates an HTTP client for requests to GitLab as configured in config
+// (with debugging, custom headers, and basic auth, but without the GitLab API token).
+//
+// If config.HTTPClient is set, it is used as the base HTTP client (it is not modified).
+// If counter is provided, it counts all requests made by the client.
+func newHTTPClient(config *Config, counter *requestCounter) (*http.Client, errors.E) {
+	httpClient, errE := newPlainHTTPClient(config)
+	if errE != nil {
+		return nil, errE
+	}
+
+	header, errE := parseHeaders(config.Headers)
+	if errE != nil {
+		return nil, errE
+	}
+
+	if len(header) > 0 {
+		httpClient.Transport = &headerTransport{
+			Header:    header,
+			Transport: httpClient.Transport,
+		}
+	}
+
+	if config.BasicAuth != "" {
+		username, password, ok := strings.Cut(config.BasicAuth, ":")
+		if !ok {
+			return nil, errors.New(`basic auth should be in "user:pass" format`)
+		}
+		httpClient.Transport = &basicAuthTransport{
+			Username:  username,
+			Password:  password,
+			Transport: httpClient.Transport,
+		}
+	}
+
+	if counter != nil {
+		httpClient.Transport = &countingTransport{
+			Counter:   counter,
+			Transport: httpClient.Transport,
+		}
+	}
+
+	return httpClient, nil
+}
+
+// newClient creates a GitLab API client as configured in config.
+//
+// If config.HTTPClient is set, it is used as the base HTTP client (it is not modified).
+// If counter is provided, it counts all requests made by the client.
+func newClient(config *Config, counter *requestCounter) (*gitlab.Client, errors.E) {
+	httpClient, errE := newHTTPClient(config, counter)
+	if errE != nil {
+		return nil, errE
+	}
+
+	// The GitLab API client appends "/api/v4" to the URL if it does not already end with it,
+	// so we can support only prefixes ending with it.
+	u := apiURL(config.BaseURL, config.APIPrefix)
+	if !strings.HasSuffix(u, defaultAPIPrefix) {
+		errE := errors.New(`GitLab API prefix should end with "/api/v4"`)
+		errors.Details(errE)["prefix"] = config.APIPrefix
+		return nil, errE
+	}
+
+	client, err := gitlab.NewClient(config.Token, gitlab.WithBaseURL(u), gitlab.WithHTTPClient(httpClient))
+	if err != nil {
+		return nil, errors.WithMessage(err, "failed to create GitLab API client instance")
+	}
+	return client, nil
+}
@@ -0,0 +1,263 @@
+package release
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
+)
+
+func TestNewClientBasicAuth(t *testing.T) {
+	t.Parallel()
+
+	var username, password, token string
+	var ok bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		username, password, ok = r.BasicAuth()
+		token = r.Header.Get("Private-Token")
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client, errE := newClient(&Config{
+		BaseURL:   server.URL,
+		Token:     "secret",
+		BasicAuth: "user:pass",
+	}, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	_, _, err := client.Projects.GetProject("1", nil)
+	require.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, "user", username)
+	assert.Equal(t, "pass", password)
+	assert.Equal(t, "secret", token)
+
+	_, errE = newClient(&Config{
+		BaseURL:   server.URL,
+		Token:     "secret",
+		BasicAuth: "user",
+	}, nil)
+	assert.EqualError(t, errE, `basic auth should be in "user:pass" format`)
+}
+
+func TestNewClientHeaders(t *testing.T) {
+	t.Parallel()
+
+	var header http.Header
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	t.Cleanup(server.Close)
+
+	client, errE := newClient(&Config{
+		BaseURL: server.URL,
+		Token:   "secret",
+		Headers: []string{"X-Gateway-Key: abc:def", "X-Team: a", "X-Team: b"},
+	}, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	_, _, err := client.Projects.GetProject("1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "abc:def", header.Get("X-Gateway-Key"))
+	assert.Equal(t, []string{"a", "b"}, header.Values("X-Team"))
+	assert.Equal(t, "secret", header.Get("Private-Token"))
+
+	_, errE = newClient(&Config{
+		BaseURL: server.URL,
+		Headers: []string{"X-Gateway-Key"},
+	}, nil)
+	assert.EqualError(t, errE, `header should be in "key:value" format`)
+}
+
+func TestIsSensitiveHeader(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		key       string
+		sensitive bool
+	}{
+		{"Authorization", true},
+		{"private-token", true},
+		{"X-Gateway-Key", true},
+		{"X-Api-Secret", true},
+		{"Cookie", true},
+		{"Content-Type", false},
+		{"X-Team", false},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.sensitive, isSensitiveHeader(tt.key))
+		})
+	}
+}
+
+func TestAPIURL(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		baseURL string
+		prefix  string
+		want    string
+	}{
+		{"https://gitlab.com", "", "https://gitlab.com/api/v4"},
+		{"https://gitlab.com/", "", "https://gitlab.com/api/v4"},
+		{"https://example.com", "/gitlab/api/v4", "https://example.com/gitlab/api/v4"},
+		{"https://example.com/", "gitlab/api/v4/", "https://example.com/gitlab/api/v4"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, apiURL(tt.baseURL, tt.prefix))
+		})
+	}
+}
+
+func TestNewClientAPIPrefix(t *testing.T) {
+	t.Parallel()
+
+	var path string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		path = r.URL.Path
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	t.Cleanup(server.Close)
+
+	config := &Config{
+		BaseURL:   server.URL,
+		APIPrefix: "/gitlab/api/v4",
+		Token:     "secret",
+		Project:   "1",
+	}
+	client, errE := newClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	_, _, err := client.Projects.GetProject("1", nil)
+	require.NoError(t, err)
+	assert.Equal(t, "/gitlab/api/v4/projects/1", path)
+
+	// Download links use the same prefix.
+	file := "a.txt"
+	options := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, "foo/a.txt", link{
+		Name:    "foo/a.txt",
+		ID:      nil,
+		Package: &Package{Generic: true, Name: "foo", Version: "1.0.0"},
+		File:    &file,
+		Asset:   nil,
+	})
+	assert.Equal(t, server.URL+"/gitlab/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/a%2Etxt", *options.URL)
+
+	_, errE = newClient(&Config{
+		BaseURL:   server.URL,
+		APIPrefix: "/gitlab/api",
+		Token:     "secret",
+	}, nil)
+	assert.EqualError(t, errE, `GitLab API prefix should end with "/api/v4"`)
+}
+
+func TestNewClientCounter(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method == http.MethodGet && strings.HasSuffix(r.URL.Path, "/links") {
+			_, _ = w.Write([]byte(`[]`))
+			return
+		}
+		_, _ = w.Write([]byte(`{"id": 1}`))
+	}))
+	t.Cleanup(server.Close)
+
+	counter := newRequestCounter()
+	client, errE := newClient(&Config{
+		BaseURL: server.URL,
+		Token:   "secret",
+	}, counter)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	_, _, err := client.Projects.GetProject("group/project", nil)
+	require.NoError(t, err)
+	_, _, err = client.Projects.GetProject("123", nil)
+	require.NoError(t, err)
+	_, _, err = client.ReleaseLinks.ListReleaseLinks("group/project", "v1.0.0", nil)
+	require.NoError(t, err)
+	_, _, err = client.ReleaseLinks.DeleteReleaseLink("group/project", "v1.0.0", 42)
+	require.NoError(t, err)
+
+	assert.Equal(t, 4, counter.Total())
+	assert.Equal(t, map[string]int{
+		"GET /api/v4/projects/:id":                                        2,
+		"GET /api/v4/projects/:id/releases/:tag_name/assets/links":        1,
+		"DELETE /api/v4/projects/:id/releases/:tag_name/assets/links/:id": 1,
+	}, counter.Endpoints())
+}
+
+func TestDebugTransport(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write([]byte(`{"id": 1, "description": "token secret"}`))
+	}))
+	t.Cleanup(server.Close)
+
+	for _, bodies := range []bool{false, true} {
+		bodies := bodies
+
+		t.Run(fmt.Sprintf("bodies=%t", bodies), func(t *testing.T) {
+			t.Parallel()
+
+			var buf bytes.Buffer
+			client, err := gitlab.NewClient("secret", gitlab.WithBaseURL(server.URL), gitlab.WithHTTPClient(&http.Client{
+				Transport: &basicAuthTransport{
+					Username: "user",
+					Password: "pass",
+					Transport: &debugTransport{
+						Writer:    &buf,
+						Bodies:    bodies,
+						Secrets:   []string{"secret", "user:pass"},
+						Transport: http.DefaultTransport,
+					},
+				},
+			}))
+			require.NoError(t, err)
+
+			description := "created with secret"
+			_, _, err = client.Releases.UpdateRelease("1", "v1.0.0", &gitlab.UpdateReleaseOptions{Description: &description}) //nolint:exhaustruct
+			require.NoError(t, err)
+
+			output := buf.String()
+			assert.Contains(t, output, "> PUT "+server.URL+"/api/v4/projects/1/releases/v1%2E0%2E0\n")
+			assert.Contains(t, output, "< 200 OK (")
+			assert.NotContains(t, output, "secret")
+			assert.NotContains(t, output, "dXNlcjpwYXNz")
+			if bodies {
+				assert.Contains(t, output, "> Private-Token: [REDACTED]\n")
+				assert.Contains(t, output, "> Authorization: [REDACTED]\n")
+				assert.Contains(t, output, `"description":"created with [REDACTED]"`)
+				assert.Contains(t, output, `"description": "token [REDACTED]"`)
+			} else {
+				assert.NotContains(t, output, "description")
+			}
+		})
+	}
+}
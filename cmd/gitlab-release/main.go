@@ -40,6 +40,17 @@ func main() {
 		),
 	)
 
+	// Kong already changed the working directory when parsing ChangeTo, so we make
+	// it absolute to not apply a relative path again when Sync uses it.
+	if config.ChangeTo != "" {
+		cwd, err := os.Getwd()
+		if err != nil {
+			fmt.Fprintf(ctx.Stderr, "error: %s\n", err)
+			ctx.Exit(exitCode)
+		}
+		config.ChangeTo = kong.ChangeDirFlag(cwd)
+	}
+
 	err := release.Sync(&config)
 	if err != nil {
 		fmt.Fprintf(ctx.Stderr, "error: % -+#.1v", err)
@@ -0,0 +1,178 @@
+package release
+
+import (
+	"fmt"
+	"regexp"
+	"slices"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"gitlab.com/tozd/go/errors"
+)
+
+// conventionalCommitRegex matches the subject of a conventional commit,
+// e.g., "feat(api)!: add endpoint".
+//
+// See: https://www.conventionalcommits.org/
+var conventionalCommitRegex = regexp.MustCompile(`^([a-zA-Z]+)(?:\([^)]*\))?!?:\s+(.+)$`)
+
+// commitsOtherGroup is the heading for commits which are not conventional commits
+// or are of a type not among commitsGroups.
+const commitsOtherGroup = "Other"
+
+// commitsGroups maps conventional commit types to headings, in the order
+// in which they are rendered.
+var commitsGroups = []struct { //nolint:gochecknoglobals
+	Type    string
+	Heading string
+}{
+	{"feat", "Features"},
+	{"fix", "Bug Fixes"},
+	{"perf", "Performance"},
+	{"refactor", "Refactoring"},
+	{"docs", "Documentation"},
+}
+
+// tagCommit resolves a git tag with name to the commit it points to.
+func tagCommit(repository *git.Repository, name string) (*object.Commit, errors.E) {
+	ref, err := repository.Tag(name)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot resolve git tag")
+		errors.Details(errE)["tag"] = name
+		return nil, errE
+	}
+	hash := ref.Hash()
+	tag, err := repository.TagObject(hash)
+	if err == nil {
+		hash = tag.Target
+	} else if !errors.Is(err, plumbing.ErrObjectNotFound) {
+		errE := errors.WithMessage(err, "tag object")
+		errors.Details(errE)["tag"] = name
+		return nil, errE
+	}
+	commit, err := repository.CommitObject(hash)
+	if err != nil {
+		errE := errors.WithMessage(err, "commit object")
+		errors.Details(errE)["tag"] = name
+		errors.Details(errE)["hash"] = hash
+		return nil, errE
+	}
+	return commit, nil
+}
+
+// commitsNotes renders release notes from subjects of commits. If any of them
+// is a conventional commit, subjects are grouped by the commit type.
+func commitsNotes(subjects []string) string {
+	groups := map[string][]string{}
+	conventional := false
+	for _, subject := range subjects {
+		match := conventionalCommitRegex.FindStringSubmatch(subject)
+		if match == nil {
+			groups[commitsOtherGroup] = append(groups[commitsOtherGroup], subject)
+			continue
+		}
+		conventional = true
+		heading := commitsOtherGroup
+		for _, group := range commitsGroups {
+			if strings.ToLower(match[1]) == group.Type {
+				heading = group.Heading
+				subject = match[2]
+				break
+			}
+		}
+		groups[heading] = append(groups[heading], subject)
+	}
+
+	var notes strings.Builder
+	if !conventional {
+		for _, subject := range subjects {
+			fmt.Fprintf(&notes, "- %s\n", subject)
+		}
+		return notes.String()
+	}
+
+	headings := []string{}
+	for _, group := range commitsGroups {
+		headings = append(headings, group.Heading)
+	}
+	headings = append(headings, commitsOtherGroup)
+	for _, heading := range headings {
+		if len(groups[heading]) == 0 {
+			continue
+		}
+		if notes.Len() > 0 {
+			notes.WriteString("\n")
+		}
+		fmt.Fprintf(&notes, "### %s\n\n", heading)
+		for _, subject := range groups[heading] {
+			fmt.Fprintf(&notes, "- %s\n", subject)
+		}
+	}
+	return notes.String()
+}
+
+// commitsReleases generates releases for tags in the git repository at path,
+// with notes generated from commits between each tag and the previous tag.
+// Tags are ordered by their dates. Merge commits are skipped.
+//
+// Releases are returned newest first, like releases from the changelog.
+func commitsReleases(path string, tags []Tag) ([]Release, errors.E) {
+	repository, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: false,
+	})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot open git repository")
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+
+	sortedTags := append([]Tag{}, tags...)
+	sort.SliceStable(sortedTags, func(i, j int) bool {
+		return sortedTags[i].Date.Before(sortedTags[j].Date)
+	})
+
+	// Commits reachable from tags processed so far.
+	seen := map[plumbing.Hash]bool{}
+	releases := make([]Release, 0, len(sortedTags))
+	for _, tag := range sortedTags {
+		commit, errE := tagCommit(repository, tag.Name)
+		if errE != nil {
+			return nil, errE
+		}
+		// This is the same order as git log uses by default, but commits reachable
+		// from previous tags are not walked again.
+		commits := object.NewCommitPreorderIter(commit, seen, nil)
+		subjects := []string{}
+		err := commits.ForEach(func(c *object.Commit) error {
+			seen[c.Hash] = true
+			if c.NumParents() > 1 {
+				return nil
+			}
+			subject, _, _ := strings.Cut(strings.TrimSpace(c.Message), "\n")
+			subjects = append(subjects, strings.TrimSpace(subject))
+			return nil
+		})
+		if err != nil {
+			errE := errors.WithMessage(err, "cannot obtain git log")
+			errors.Details(errE)["tag"] = tag.Name
+			return nil, errE
+		}
+		date := tag.Date.UTC()
+		releases = append(releases, Release{ //nolint:exhaustruct
+			Tag:        tag.Name,
+			Title:      tag.Name,
+			Changes:    commitsNotes(subjects),
+			Date:       time.Date(date.Year(), date.Month(), date.Day(), 0, 0, 0, 0, time.UTC),
+			References: map[string]string{},
+		})
+	}
+
+	// Newest first.
+	slices.Reverse(releases)
+	return releases, nil
+}
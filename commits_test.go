@@ -0,0 +1,101 @@
+package release
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommitsNotes(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		subjects []string
+		notes    string
+	}{
+		{[]string{}, ""},
+		{[]string{"Add feature.", "Fix bug."}, "- Add feature.\n- Fix bug.\n"},
+		{
+			[]string{"feat(api): add endpoint", "fix: crash", "Update README.", "chore: bump deps", "feat!: drop old API"},
+			"### Features\n\n- add endpoint\n- drop old API\n\n### Bug Fixes\n\n- crash\n\n### Other\n\n- Update README.\n- chore: bump deps\n",
+		},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.notes, commitsNotes(tt.subjects))
+		})
+	}
+}
+
+func TestCommitsReleases(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	repository, err := git.PlainInit(tempDir, false)
+	require.NoError(t, err)
+	workTree, err := repository.Worktree()
+	require.NoError(t, err)
+	filename := filepath.Join(tempDir, "file.txt")
+
+	date := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	for _, step := range []struct {
+		message string
+		tag     string
+	}{
+		{"Initial commit.", ""},
+		{"feat: first feature", "v1.0.0"},
+		{"fix: a bug\n\nLonger description.", ""},
+		{"Another change.", "v1.1.0"},
+	} {
+		date = date.Add(24 * time.Hour)
+		err := os.WriteFile(filename, []byte(step.message), 0o600) //nolint:govet
+		require.NoError(t, err)
+		_, err = workTree.Add("file.txt")
+		require.NoError(t, err)
+		author := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: date}
+		hash, err := workTree.Commit(step.message, &git.CommitOptions{ //nolint:exhaustruct
+			Author: author,
+		})
+		require.NoError(t, err)
+		if step.tag != "" {
+			_, err = repository.CreateTag(step.tag, hash, &git.CreateTagOptions{ //nolint:exhaustruct
+				Tagger:  author,
+				Message: step.tag,
+			})
+			require.NoError(t, err)
+		}
+	}
+
+	tags, errE := gitTags(tempDir, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	releases, errE := commitsReleases(tempDir, tags)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []Release{
+		{ //nolint:exhaustruct
+			Tag:        "v1.1.0",
+			Title:      "v1.1.0",
+			Changes:    "### Bug Fixes\n\n- a bug\n\n### Other\n\n- Another change.\n",
+			Date:       time.Date(2023, 1, 5, 0, 0, 0, 0, time.UTC),
+			References: map[string]string{},
+		},
+		{ //nolint:exhaustruct
+			Tag:        "v1.0.0",
+			Title:      "v1.0.0",
+			Changes:    "### Features\n\n- first feature\n\n### Other\n\n- Initial commit.\n",
+			Date:       time.Date(2023, 1, 3, 0, 0, 0, 0, time.UTC),
+			References: map[string]string{},
+		},
+	}, releases)
+}
@@ -1,19 +1,189 @@
 package release
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
 	"github.com/alecthomas/kong"
+	"gitlab.com/tozd/go/errors"
 )
 
-// We do not use type=path for Changelog because we want a relative path.
+// We do not use type=path for Changelog because we want a relative path
+// (relative to ChangeTo, if it is set).
 
 // Config provides configuration.
 // It is used as configuration for Kong command-line parser as well.
 type Config struct {
-	ChangeTo  kong.ChangeDirFlag `                             env:"CI_PROJECT_DIR"   help:"Run as if the program was started in PATH instead of the current working directory. Environment variable: ${env}."                                                      placeholder:"PATH"             short:"C"`
-	Version   kong.VersionFlag   `                                                    help:"Show program's version and exit."                                                                                                                                                                      short:"V"`
-	Project   string             `                             env:"CI_PROJECT_ID"    help:"GitLab project to release to. It can be project ID or <namespace/project_path>. By default it infers it from the repository. Environment variable: ${env}."                                            short:"p"`
-	BaseURL   string             `default:"https://gitlab.com" env:"CI_SERVER_URL"    help:"Base URL for GitLab API to use. Default is \"${default}\". Environment variable: ${env}."                                                                   name:"base" placeholder:"URL"              short:"B"`
-	Token     string             `                             env:"GITLAB_API_TOKEN" help:"GitLab API token to use. Environment variable: ${env}."                                                                                                                                    required:"" short:"t"`
-	Changelog string             `default:"CHANGELOG.md"                              help:"Path to the changelog file to use. Default is \"${default}\"."                                                                                                          placeholder:"PATH"             short:"f"`
-	NoCreate  bool               `                                                    help:"Only update or remove releases, do not create them."                                                                                                                                                   short:"U"`
+	ChangeTo                     kong.ChangeDirFlag `                                                                                      env:"CI_PROJECT_DIR"                 help:"Run as if the program was started in PATH instead of the current working directory. Environment variable: ${env}."                                                                                                                                                                                                                   placeholder:"PATH"                 short:"C"`
+	Version                      kong.VersionFlag   `                                                                                                                           help:"Show program's version and exit."                                                                                                                                                                                                                                                                                                                                       short:"V"`
+	Verbose                      bool               `                                                                                                                           help:"Print more details, e.g., the number of GitLab API requests made per endpoint."                                                                                                                                                                                                                                                                                         short:"v"`
+	DebugHTTP                    bool               `                                                                                                                           help:"Log GitLab API requests and responses to stderr. Secrets are redacted."                                                                                                                                                                                                                       name:"debug-http"`
+	DebugHTTPBodies              bool               `                                                                                                                           help:"Log also headers and bodies of GitLab API requests and responses to stderr. Secrets are redacted. Implies --debug-http."                                                                                                                                                                      name:"debug-http-bodies"`
+	Project                      string             `                                                                                      env:"CI_PROJECT_ID"                  help:"GitLab project to release to. It can be project ID or <namespace/project_path>. By default it infers it from the repository. Environment variable: ${env}."                                                                                                                                                                                                             short:"p"`
+	BaseURL                      string             `default:"https://gitlab.com"                                                          env:"CI_SERVER_URL"                  help:"Base URL for GitLab API to use. Default is \"${default}\". Environment variable: ${env}."                                                                                                                                                                                                     name:"base"                            placeholder:"URL"                  short:"B"`
+	APIPrefix                    string             `                                                                                                                           help:"Path, relative to base URL, at which GitLab API is served, e.g., for GitLab behind a reverse proxy. It has to end with \"/api/v4\". Default is \"/api/v4\"."                                                                                                                                  name:"api-prefix"                      placeholder:"PATH"`
+	DownloadBaseURL              string             `                                                                                                                           help:"Base URL for GitLab API to use in links to download files of generic packages, if the API is available at a different host than the web interface. Default is base URL."                                                                                                                      name:"download-base"                   placeholder:"URL"`
+	Mirrors                      []string           `                                                                                                                           help:"Additional GitLab instance to sync releases to, in \"base=URL[,project=PROJECT][,token-env=VAR]\" format. By default, project and token are determined as for the primary GitLab instance. Can be repeated."                                                                                  name:"mirror"                          placeholder:"MIRROR"    sep:"none"`
+	MirrorFailuresFatal          bool               `                                                                                                                           help:"Fail when syncing to a mirror fails. By default, a warning is printed and other mirrors are still synced."`
+	Token                        string             `                                                                                                                           help:"GitLab API token to use. Environment variable: GITLAB_API_TOKEN."                                                                                                                                                                                                                                                                                                       short:"t"`
+	TokenCommand                 string             `                                                                                                                           help:"Command to run (using the shell) to obtain GitLab API token from its output."                                                                                                                                                                                                                                                        placeholder:"CMD"`
+	TokenFile                    string             `                                                                                                                           help:"Path to the file with GitLab API token. It should not be accessible by group or others."                                                                                                                                                                                                                                             placeholder:"PATH"                           type:"path"`
+	ReadToken                    string             `                                                                                      env:"GITLAB_API_READ_TOKEN"          help:"GitLab API token to use for requests which only read (e.g., listing milestones, packages, and Docker images). Default is the token used for other requests. Environment variable: ${env}."                                                                                                                                           placeholder:"TOKEN"`
+	BasicAuth                    string             `                                                                                      env:"GITLAB_BASIC_AUTH"              help:"HTTP basic auth credentials to send with every request, e.g., for a gateway in front of GitLab. GitLab API token is still sent for API authentication. Environment variable: ${env}."                                                                                                                                                placeholder:"USER:PASS"`
+	Headers                      []string           `                                                                                                                           help:"HTTP header to send with every request, e.g., for a gateway in front of GitLab. Can be repeated. Values of headers which look sensitive are redacted in debug output."                                                                                                                        name:"header"                          placeholder:"KEY:VALUE" sep:"none"`
+	Changelog                    string             `                                                                                                                           help:"Path to the changelog file to use, or its http(s) URL. Default is \"CHANGELOG.md\"."                                                                                                                                                                                                                                                 placeholder:"PATH"                 short:"f"`
+	FromCommits                  bool               `                                                                                                                           help:"Generate release notes from commit messages between tags instead of reading them from the changelog. Conventional commits are grouped by their type."                                                                                                                                         name:"from-commits"`
+	ChangelogRef                 string             `                                                                                                                           help:"Read the changelog as it exists at this git ref (a branch, a tag, or a commit) instead of from the working tree."                                                                                                                                                                                                                    placeholder:"REF"`
+	ChangedOnly                  bool               `                                                                                                                           help:"Sync only releases whose changelog sections changed since the base git ref (e.g., in merge request pipelines)."`
+	ChangedBase                  string             `                                                                                      env:"CI_MERGE_REQUEST_DIFF_BASE_SHA" help:"Base git ref with which the changelog is compared to determine changed releases. Environment variable: ${env}."                                                                                                                                                                                                                      placeholder:"REF"`
+	AfterDate                    string             `                                                                                                                           help:"Sync only releases with the changelog date on or after this date (YYYY-MM-DD). GitLab releases released before it are not deleted."                                                                                                                                                                                                  placeholder:"DATE"`
+	BeforeDate                   string             `                                                                                                                           help:"Sync only releases with the changelog date before this date (YYYY-MM-DD), exclusive. GitLab releases released on or after it are not deleted."                                                                                                                                                                                       placeholder:"DATE"`
+	DiscoverChangelog            bool               `                                                                                                                           help:"When changelog path is not provided, use the first existing changelog file among changelog locations."`
+	ChangelogLocations           []string           `default:"CHANGELOG.md,docs/CHANGELOG.md,CHANGES.md"                                                                        help:"Path, relative to the repository root, where to search for the changelog file. Can be repeated. Default is \"${default}\"."                                                                                                                                                                   name:"changelog-location"              placeholder:"PATH"`
+	UnreleasedHeadings           []string           `                                                                                                                           help:"Heading of the changelog section with unreleased changes, compared case-insensitively, e.g., for localized changelogs. Can be repeated. Default is \"Unreleased\"."                                                                                                                           name:"unreleased-heading"              placeholder:"TEXT"`
+	ChangelogAssets              bool               `                                                                                                                           help:"Create release links from Markdown links listed in the \"Assets\" section of each release in the changelog. The section is not included in release descriptions."`
+	RequireNotes                 bool               `                                                                                                                           help:"Fail if any release in the changelog has no notes."`
+	RequireSemver                bool               `                                                                                                                           help:"Fail if any git tag or release in the changelog is not a valid semantic version."`
+	Concurrency                  int                `default:"4"                                                                                                                help:"Maximum number of concurrent GitLab API requests when fetching package files. Default is ${default}."                                                                                                                                                                                                                                placeholder:"N"`
+	PageSize                     int                `default:"100"                                                                                                              help:"Number of items to request per page when listing from GitLab API. At most 100 is used. Default is ${default}."                                                                                                                                                                      hidden:""                                        placeholder:"N"`
+	CreateConflictRetries        int                `default:"3"                                                                                                                help:"How many times to fetch again and update a release which already exists when creating it, e.g., because it has been created concurrently. Default is ${default}."                                                                                                                   hidden:""                                        placeholder:"N"`
+	Lock                         bool               `                                                                                                                           help:"Hold an advisory lock (a GitLab project CI/CD variable) while running, failing if another run holds it. Modes which do not change anything do not use it. The token needs permission to manage CI/CD variables."`
+	LockTimeout                  time.Duration      `default:"1h"                                                                                                               help:"Consider a lock held for longer than DURATION stale and take it over. It should be longer than the longest run. Zero disables it. Default is ${default}."                                                                                                                                                                            placeholder:"DURATION"`
+	Tag                          string             `                                                                                                                           help:"Sync only the release for this tag and do not delete any releases."                                                                                                                                                                                                                                                                  placeholder:"TAG"`
+	FailFast                     bool               `                                                                                                                           help:"Stop at the first release which fails to sync. By default, other releases are still synced and all failures are reported at the end."`
+	PreReleaseCommand            string             `                                                                                                                           help:"Shell command to run before each release is synced. Release's tag, version, name, and description are available in GITLAB_RELEASE_TAG, GITLAB_RELEASE_VERSION, GITLAB_RELEASE_NAME, and GITLAB_RELEASE_DESCRIPTION environment variables."                                                                                           placeholder:"COMMAND"`
+	PostReleaseCommand           string             `                                                                                                                           help:"Shell command to run after each release is synced. The same environment variables are available as for the pre-release command."                                                                                                                                                                                                     placeholder:"COMMAND"`
+	IgnoreReleaseCommandFailures bool               `                                                                                                                           help:"Only warn when a pre-release or post-release command fails instead of failing syncing of the release."`
+	FailOnWarnings               bool               `                                                                                                                           help:"Fail if any warning has been emitted."`
+	NoCreate                     bool               `                                                                                                                           help:"Only update or remove releases, do not create them."                                                                                                                                                                                                                                                                                                                    short:"U"`
+	OnlyManaged                  bool               `                                                                                                                           help:"Do not delete GitLab releases which are not in the changelog if they have not been created by this tool (e.g., drafts created by other tools)."`
+	NoMilestones                 bool               `                                                                                                                           help:"Do not fetch milestones and do not associate them with releases. Existing associations are removed."`
+	CreateMilestones             bool               `                                                                                                                           help:"Create a milestone, titled after the version, for every release without an associated milestone."`
+	NoPackages                   bool               `                                                                                                                           help:"Do not fetch packages and do not associate them with releases. Existing package links are removed."`
+	PackagesProjects             []string           `                                                                                                                           help:"Additional GitLab project from which to associate packages with releases. It can be project ID or <namespace/project_path>. Can be repeated."                                                                                                                                                 name:"packages-project"                placeholder:"PROJECT"`
+	NoImages                     bool               `                                                                                                                           help:"Do not fetch Docker images and do not list them in release descriptions."`
+	ImageLabel                   string             `                                                                                                                           help:"Associate Docker images with releases by the version in their label (e.g., org.opencontainers.image.version) instead of by their tags. It fetches every image's configuration from the container registry."                                                                                                                          placeholder:"LABEL"`
+	MilestoneState               string             `default:"all"                                       enum:"all,active,closed"                                               help:"Associate only milestones in this state: all, active, or closed. Default is ${default}."                                                                                                                                                                                                                                             placeholder:"STATE"`
+	ExactMatchOnly               bool               `                                                                                                                           help:"Map milestones, packages, and Docker images to releases only if their title, version, or Docker image tag equals the tag or version, without other transformations and substring matching."`
+	StripPrefixes                []string           `                                                                                                                           help:"Prefix to strip from git tags when mapping them to milestones, packages, and Docker images, e.g., \"release-\". Can be repeated."                                                                                                                                                             name:"strip-prefix"                    placeholder:"PREFIX"    sep:"none"`
+	TagStripPrefixes             []string           `                                                                                                                           help:"Prefix to strip from git tags before comparing them with changelog releases, e.g., \"release/\". A \"v\" prefix is added to stripped tags without it. Can be repeated."                                                                                                                       name:"tag-strip-prefix"                placeholder:"PREFIX"    sep:"none"`
+	Replacements                 []string           `                                                                                                                           help:"Replace OLD with NEW in git tags when mapping them to milestones, packages, and Docker images, e.g., \"_:.\". Can be repeated."                                                                                                                                                               name:"replace"                         placeholder:"OLD:NEW"   sep:"none"`
+	MilestoneMulti               bool               `                                                                                                                           help:"Allow one milestone to be associated with all matching releases and not just the most specific one. Milestone then also matches releases for which its title is a version prefix (e.g., 1.0 for 1.0.1)."                                                                                                                negatable:""`
+	IncludeIssues                bool               `                                                                                                                           help:"List issues closed by milestones associated with each release in an \"Issues closed\" section of its description. It requires additional GitLab API requests."`
+	NormalizeMarkdown            bool               `                                                                                                                           help:"Normalize Markdown of changes from the changelog: resolve reference-style links to inline links and escape raw HTML."                                                                                                                                                                                                   negatable:""`
+	LinkifyReferences            bool               `                                                                                                                           help:"Convert GitLab issue (#123) and merge request (!456) references in changes into links to the project."`
+	LinkNameTemplate             string             `                                                                                                                           help:"Go template used to render names of release links. Available are .PackageName, .File (empty for non-generic packages), .Version, .Size (in bytes), and .SHA256 (the last two only for files). Names must be unique."                                                                                                                 placeholder:"TEMPLATE"`
+	LinkGroups                   []string           `                                                                                                                           help:"Comma-separated glob patterns of file names for a group of release links. Links are created ordered by groups and by name inside a group. Pattern \"*\" marks the group for all other links. Can be repeated. Default is source archives, binaries, then checksums and signatures."           name:"link-group"                      placeholder:"PATTERNS"  sep:"none"`
+	LinkFilepathPrefix           string             `                                                                                                                           help:"Prefix of file paths of release links to files, e.g., \"binaries\" for \"/binaries/<link name>\"."                                                                                                                                                                                                                                   placeholder:"PREFIX"`
+	MaxLinkFileSize              int                `                                                                                                                           help:"Do not link files of generic packages larger than this many bytes. Existing links to them are removed."                                                                                                                                                                                                                              placeholder:"BYTES"`
+	VerifyLinks                  bool               `                                                                                                                           help:"Before syncing a release, check with a HEAD request that its links to files of generic packages and changelog assets resolve, and warn if they do not."`
+	VerifyLinksFatal             bool               `                                                                                                                           help:"Fail syncing the release instead of warning when its link does not resolve."`
+	CheckLinkCount               bool               `                                                                                                                           help:"After syncing a release, fetch its links again and warn if their number differs from the number of expected links."`
+	LinkOrder                    bool               `                                                                                                                           help:"Keep links of existing releases ordered by link groups, too. GitLab does not support setting positions of links, so links out of order are deleted and created again."`
+	AssetsExcludeYanked          bool               `                                                                                                                           help:"Do not associate packages and changelog assets with yanked releases. Their existing links are removed, while releases themselves are kept."`
+	PrintMapping                 bool               `                                                                                                                           help:"Only print milestones, packages, and Docker images associated with each release, without changing anything."`
+	Graph                        string             `default:""                                          enum:",dot,mermaid"                                                    help:"Only print milestones, packages, and Docker images associated with each release as a diagram in this format (dot for Graphviz or mermaid), without changing anything."                                                                                                                                                               placeholder:"FORMAT"`
+	GraphOutput                  string             `                                                                                                                           help:"File to write the diagram to, relative to the repository directory, instead of standard output."                                                                                                                                                                                                                                     placeholder:"PATH"`
+	PrintConfig                  bool               `                                                                                                                           help:"Only print the effective configuration (from command line flags, environment variables, and defaults) as JSON, with secrets redacted, and exit."`
+	PreviewLinks                 bool               `                                                                                                                           help:"Only print which links would be deleted, updated, or created for existing releases, without changing anything."`
+	DeleteOrphanedLinks          bool               `                                                                                                                           help:"Only delete links of existing releases which are not associated with releases anymore, without changing anything else. Combine with --preview-links to only print them."`
+	Audit                        bool               `                                                                                                                           help:"Only report releases which are missing, out of date, or not in the changelog, without changing anything. Fail if there are any."`
+	Report                       bool               `                                                                                                                           help:"Only print a table of changelog releases and GitLab releases with what would be done for each of them, without changing anything."`
+	CreateMissingTags            bool               `                                                                                                                           help:"Create annotated git tags for changelog releases without them. Tags are created locally and GitLab creates them in the project when creating releases."`
+	Ref                          string             `default:"HEAD"                                                                                                             help:"Git ref (branch, tag, or commit) at which to create missing tags. It has to be pushed to GitLab, too. Default is \"${default}\"."                                                                                                                                                                                                    placeholder:"REF"`
+	DateThreshold                int                `default:"7"                                                                                                                help:"Warn when the changelog date and the git tag date of a release differ by more than N days. Set to -1 to disable. Default is ${default}."                                                                                                                                                                                             placeholder:"N"`
+	DetectMovedTags              bool               `                                                                                                                           help:"Warn when the git tag date of a release differs from the date of the existing GitLab release, which happens when the tag has been moved. The date of the GitLab release is updated."`
+	StateFile                    string             `                                                                                                                           help:"Path to a file where to record releases which have been synced, to skip them (unless they changed) when sync is run again, e.g., after a failure. Delete it to force a full sync."                                                                                                                                                   placeholder:"PATH"`
+	Manifest                     string             `                                                                                                                           help:"Write a JSON manifest describing all releases synced by this run (tags, dates, description lengths, links, milestones, and Docker images) to this file, relative to the repository directory."                                                                                                                                       placeholder:"PATH"`
+	ExportGitHub                 string             `                                                                                                                           help:"Write releases as JSON payloads for GitHub Releases API (tag, name, body, and changelog assets) to this file, relative to the repository directory."                                                                                                                                                                                 placeholder:"PATH"`
+	ExportGitHubOnly             bool               `                                                                                                                           help:"Only write releases to the file set with --export-github, do not sync them to GitLab."`
+	LintFormat                   string             `default:""                                          enum:",codequality,sarif"                                              help:"Only validate the changelog, without accessing GitLab, and write found problems in this format (codequality for GitLab Code Quality or sarif) to the file set with --lint-output."                                                                                                                                                   placeholder:"FORMAT"`
+	LintOutput                   string             `default:"changelog-lint.json"                                                                                              help:"File to write the changelog lint report to, relative to the repository directory. Default is \"${default}\"."                                                                                                                                                                                                                        placeholder:"PATH"`
+	Metadata                     string             `                                                                                                                           help:"Path to a YAML file with additional per-release metadata, keyed by version. Available in the description template as .Meta."                                                                                                                                                                                                         placeholder:"PATH"`
+	NameTemplate                 string             `                                                                                                                           help:"Go template used to render release name. Available are .Tag, .Version, .Title (release heading from the changelog), .Yanked, and .Category."                                                                                                                                                                                         placeholder:"TEMPLATE"`
+	TagMessageTemplate           string             `                                                                                                                           help:"Go template used to render the message of the tag GitLab creates for a release, when the tag is missing in the GitLab project. Available are .Tag, .Version, .Title (release heading from the changelog), .Yanked, and .Category. Default is the release name."                                                                      placeholder:"TEMPLATE"`
+	CategoryFrom                 string             `default:""                                          enum:",comment,sections,metadata"                                      help:"Determine release category from a \"<!-- category: ... -->\" line in the changelog (comment), from changelog sections (sections), or from \"category\" metadata field (metadata). It is shown in the description and available in templates as .Category."                                                                           placeholder:"SOURCE"`
+	DescriptionHeader            string             `                                                                                                                           help:"Markdown to prepend to every release description."                                                                                                                                                                                                                                                                                   placeholder:"TEXT"`
+	DescriptionFooter            string             `                                                                                                                           help:"Markdown to append to every release description. It is kept even when the description is truncated."                                                                                                                                                                                                                                 placeholder:"TEXT"`
+	LatestBadge                  bool               `                                                                                                                           help:"Show \"⭐ Latest release\" at the top of the description of the newest release (by semantic version, ignoring yanked releases and pre-releases). It is removed from other releases."`
+	DescriptionTemplate          string             `                                                                                                                           help:"Go template used to render release description. Available are .Tag, .Version, .Changes, .Images, .Meta, and .Category."                                                                                                                                                                                                              placeholder:"TEMPLATE"`
+	KeepBlankLines               bool               `                                                                                                                           help:"Do not collapse multiple consecutive blank lines in release descriptions into one."`
+	MaxDescriptionLength         int                `default:"1000000"                                                                                                          help:"Truncate release descriptions longer than N characters, linking to the full changelog. Default is ${default}."                                                                                                                                                                                                                       placeholder:"N"`
+
+	// HTTPClient is used as the base HTTP client for GitLab API requests, if set.
+	// It is not configurable from the command line and is meant for programmatic use
+	// (e.g., to provide a transport which replays recorded API responses in tests).
+	HTTPClient *http.Client `kong:"-"`
+
+	// explicitFlags are names of flags which have been explicitly provided on the command line.
+	explicitFlags map[string]bool
+}
+
+// AfterApply records which flags have been explicitly provided on the command line,
+// so that configuration from the changelog front matter does not override them.
+func (c *Config) AfterApply(ctx *kong.Context) error { //nolint:unparam
+	c.explicitFlags = map[string]bool{}
+	for _, path := range ctx.Path {
+		if path.Flag != nil {
+			c.explicitFlags[path.Flag.Name] = true
+		}
+	}
+	return nil
+}
+
+// effectiveConfig returns configuration values from config keyed by their
+// command line flag names. The token, basic auth password, and values of sensitive
+// headers are redacted.
+func effectiveConfig(config *Config) (map[string]interface{}, errors.E) {
+	// Only the model is used, so variables used in help can be empty.
+	parser, err := kong.New(config, kong.Vars{"version": ""})
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	values := map[string]interface{}{}
+	for _, flag := range parser.Model.Flags {
+		switch flag.Name {
+		case "help", "version", "print-config":
+			continue
+		}
+		values[flag.Name] = flag.Target.Interface()
+	}
+	if config.Token != "" {
+		values["token"] = "[REDACTED]"
+	}
+	if config.ReadToken != "" {
+		values["read-token"] = "[REDACTED]"
+	}
+	if config.BasicAuth != "" {
+		username, _, _ := strings.Cut(config.BasicAuth, ":")
+		values["basic-auth"] = username + ":[REDACTED]"
+	}
+	headers := make([]string, 0, len(config.Headers))
+	for _, h := range config.Headers {
+		key, _, ok := strings.Cut(h, ":")
+		if ok && isSensitiveHeader(strings.TrimSpace(key)) {
+			h = key + ":[REDACTED]"
+		}
+		headers = append(headers, h)
+	}
+	values["header"] = headers
+	return values, nil
+}
+
+// printConfig writes the effective configuration as JSON to w.
+func printConfig(w io.Writer, config *Config) errors.E {
+	values, errE := effectiveConfig(config)
+	if errE != nil {
+		return errE
+	}
+	data, err := json.MarshalIndent(values, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = fmt.Fprintf(w, "%s\n", data)
+	return errors.WithStack(err)
 }
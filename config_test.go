@@ -0,0 +1,75 @@
+package release
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConfigParse(t *testing.T) {
+	t.Parallel()
+
+	var config Config
+	parser, err := kong.New(&config, kong.Vars{"version": ""}, kong.Exit(func(code int) {
+		t.Errorf("Kong exited with code %d", code)
+	}))
+	require.NoError(t, err)
+
+	_, err = parser.Parse([]string{"--category-from", "sections"})
+	require.NoError(t, err)
+	assert.Equal(t, "sections", config.CategoryFrom)
+
+	_, err = parser.Parse([]string{})
+	require.NoError(t, err)
+	assert.Equal(t, "", config.CategoryFrom)
+	assert.Equal(t, 100, config.PageSize)
+}
+
+func TestEffectiveConfig(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{ //nolint:exhaustruct
+		BaseURL:     "https://gitlab.example.com",
+		Token:       "secret",
+		ReadToken:   "read-secret",
+		BasicAuth:   "user:password",
+		Headers:     []string{"Private-Token: abc", "X-Foo: bar"},
+		PageSize:    50,
+		Mirrors:     []string{"base=https://mirror.example.com,token-env=MIRROR_TOKEN"},
+		ChangeTo:    "/tmp/project",
+		NoCreate:    true,
+		Changelog:   "CHANGES.md",
+		FailFast:    true,
+		PrintConfig: true,
+	}
+
+	values, errE := effectiveConfig(config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "https://gitlab.example.com", values["base"])
+	assert.Equal(t, "[REDACTED]", values["token"])
+	assert.Equal(t, "[REDACTED]", values["read-token"])
+	assert.Equal(t, "user:[REDACTED]", values["basic-auth"])
+	assert.Equal(t, []string{"Private-Token:[REDACTED]", "X-Foo: bar"}, values["header"])
+	assert.Equal(t, 50, values["page-size"])
+	assert.Equal(t, []string{"base=https://mirror.example.com,token-env=MIRROR_TOKEN"}, values["mirror"])
+	assert.Equal(t, kong.ChangeDirFlag("/tmp/project"), values["change-to"])
+	assert.Equal(t, true, values["no-create"])
+	assert.Equal(t, "CHANGES.md", values["changelog"])
+	assert.NotContains(t, values, "help")
+	assert.NotContains(t, values, "version")
+	assert.NotContains(t, values, "print-config")
+
+	// Secrets in config itself are not changed.
+	assert.Equal(t, "secret", config.Token)
+	assert.Equal(t, "user:password", config.BasicAuth)
+
+	var output strings.Builder
+	errE = printConfig(&output, config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.NotContains(t, output.String(), "secret")
+	assert.NotContains(t, output.String(), "password")
+	assert.Contains(t, output.String(), `"page-size": 50`)
+}
@@ -1,11 +1,24 @@
 package release
 
 import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/alecthomas/kong"
+	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
 )
 
 func TestE2E(t *testing.T) {
@@ -29,3 +42,501 @@ func TestE2E(t *testing.T) {
 	err = Sync(&config)
 	require.NoError(t, err, "% -+#.1v", err)
 }
+
+// fixture is a recorded GitLab API response to a request.
+type fixture struct {
+	Method string          `json:"method"`
+	Path   string          `json:"path"`
+	Status int             `json:"status"`
+	Body   json.RawMessage `json:"body"`
+}
+
+// fixtureRequest is a request made to the fixtures server.
+type fixtureRequest struct {
+	Method string
+	Path   string
+	Body   map[string]interface{}
+}
+
+// fixturesServer serves responses recorded in the fixtures file at path,
+// matching them by request method and path. It returns all requests made.
+func fixturesServer(t *testing.T, path string) (*httptest.Server, func() []fixtureRequest) {
+	t.Helper()
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var fixtures []fixture
+	err = json.Unmarshal(data, &fixtures)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	requests := []fixtureRequest{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]interface{}
+		if r.Body != nil && r.ContentLength != 0 {
+			err := json.NewDecoder(r.Body).Decode(&body)
+			assert.NoError(t, err)
+		}
+		mu.Lock()
+		requests = append(requests, fixtureRequest{Method: r.Method, Path: r.URL.Path, Body: body})
+		mu.Unlock()
+
+		for _, f := range fixtures {
+			if f.Method == r.Method && f.Path == r.URL.Path {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(f.Status)
+				_, _ = w.Write(f.Body)
+				return
+			}
+		}
+		t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []fixtureRequest {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]fixtureRequest{}, requests...)
+	}
+}
+
+// recordingServer serves responses with handler and records all requests made
+// (as method and path). It returns all requests made.
+func recordingServer(t *testing.T, handler http.HandlerFunc) (*httptest.Server, func() []string) {
+	t.Helper()
+
+	var mu sync.Mutex
+	requests := []string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Method+" "+r.URL.Path)
+		mu.Unlock()
+
+		handler(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	return server, func() []string {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]string{}, requests...)
+	}
+}
+
+// syncFixturesRepository creates a git repository with tags and a changelog
+// matching recorded fixtures and returns its path.
+func syncFixturesRepository(t *testing.T) string {
+	t.Helper()
+
+	tempDir := t.TempDir()
+	repository, err := git.PlainInit(tempDir, false)
+	require.NoError(t, err)
+	workTree, err := repository.Worktree()
+	require.NoError(t, err)
+	filename := filepath.Join(tempDir, "file.txt")
+	for _, tag := range []Tag{
+		{"v0.1.0", mustParse("2023-01-01 12:00:00 +0000 UTC")},
+		{"v1.0.0", mustParse("2023-06-01 12:00:00 +0000 UTC")},
+	} {
+		err = os.WriteFile(filename, []byte("Data: "+tag.Name), 0o600)
+		require.NoError(t, err)
+		_, err = workTree.Add("file.txt")
+		require.NoError(t, err)
+		commit, err := workTree.Commit("Change for "+tag.Name, &git.CommitOptions{ //nolint:govet
+			Author: &object.Signature{
+				Name:  "John Doe",
+				Email: "john@doe.org",
+				When:  tag.Date,
+			},
+		})
+		require.NoError(t, err)
+		_, err = repository.CreateTag(tag.Name, commit, nil)
+		require.NoError(t, err)
+	}
+	err = os.WriteFile(filepath.Join(tempDir, "CHANGELOG.md"), []byte(
+		"# Changelog\n\n## [Unreleased]\n\n## [1.0.0] - 2023-06-01\n### Added\n- Feature.\n\n## [0.1.0] - 2023-01-01\n### Added\n- Initial release.\n",
+	), 0o600)
+	require.NoError(t, err)
+
+	return tempDir
+}
+
+func TestSyncFixtures(t *testing.T) {
+	t.Parallel()
+
+	tempDir := syncFixturesRepository(t)
+
+	server, requests := fixturesServer(t, filepath.Join("testdata", "fixtures", "sync.json"))
+
+	config := &Config{
+		ChangeTo:   kong.ChangeDirFlag(tempDir),
+		Project:    "1",
+		BaseURL:    server.URL,
+		Token:      "token",
+		Changelog:  "CHANGELOG.md",
+		HTTPClient: server.Client(),
+	}
+	errE := Sync(config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	mutations := map[string]fixtureRequest{}
+	for _, r := range requests() {
+		if r.Method != http.MethodGet {
+			mutations[r.Method+" "+r.Path] = r
+		}
+	}
+	require.Len(t, mutations, 5)
+
+	update := mutations["PUT /api/v4/projects/1/releases/v1.0.0"]
+	assert.Equal(t, "v1.0.0", update.Body["name"])
+	assert.Equal(t, []interface{}{"1.0.0"}, update.Body["milestones"])
+	assert.Contains(t, update.Body["description"], "registry.example.com/group/project:1.0.0")
+	assert.Contains(t, update.Body["description"], "- Feature.")
+
+	assert.Contains(t, mutations, "DELETE /api/v4/projects/1/releases/v1.0.0/assets/links/5")
+	link := mutations["POST /api/v4/projects/1/releases/v1.0.0/assets/links"]
+	assert.Equal(t, "foo/foo.tar.gz", link.Body["name"])
+	assert.Equal(t, server.URL+"/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/foo%2Etar%2Egz", link.Body["url"])
+
+	create := mutations["POST /api/v4/projects/1/releases"]
+	assert.Equal(t, "v0.1.0", create.Body["tag_name"])
+	releasedAt, err := time.Parse(time.RFC3339, create.Body["released_at"].(string)) //nolint:forcetypeassert
+	require.NoError(t, err)
+	assert.Equal(t, mustParse("2023-01-01 12:00:00 +0000 UTC"), releasedAt.UTC())
+
+	assert.Contains(t, mutations, "DELETE /api/v4/projects/1/releases/v0.0.1")
+}
+
+func TestSyncTagAppliesMetadata(t *testing.T) {
+	t.Parallel()
+
+	tempDir := syncFixturesRepository(t)
+	err := os.WriteFile(filepath.Join(tempDir, "metadata.yml"), []byte("1.0.0:\n  author: Jane\n"), 0o600)
+	require.NoError(t, err)
+
+	server, requests := fixturesServer(t, filepath.Join("testdata", "fixtures", "sync.json"))
+
+	config := &Config{
+		ChangeTo:            kong.ChangeDirFlag(tempDir),
+		Project:             "1",
+		BaseURL:             server.URL,
+		Token:               "token",
+		Changelog:           "CHANGELOG.md",
+		HTTPClient:          server.Client(),
+		Tag:                 "v1.0.0",
+		Metadata:            "metadata.yml",
+		DescriptionTemplate: "By {{.Meta.author}}.\n\n{{.Changes}}",
+	}
+	errE := Sync(config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	// Releases are updated before one is selected, so the selected release has its metadata.
+	for _, r := range requests() {
+		if r.Method == http.MethodPut && r.Path == "/api/v4/projects/1/releases/v1.0.0" {
+			assert.Contains(t, r.Body["description"], "By Jane.")
+			return
+		}
+	}
+	assert.Fail(t, "release has not been updated")
+}
+
+func TestSyncTagAppliesCategories(t *testing.T) {
+	t.Parallel()
+
+	tempDir := syncFixturesRepository(t)
+
+	server, requests := fixturesServer(t, filepath.Join("testdata", "fixtures", "sync.json"))
+
+	config := &Config{
+		ChangeTo:     kong.ChangeDirFlag(tempDir),
+		Project:      "1",
+		BaseURL:      server.URL,
+		Token:        "token",
+		Changelog:    "CHANGELOG.md",
+		HTTPClient:   server.Client(),
+		Tag:          "v1.0.0",
+		CategoryFrom: "sections",
+	}
+	errE := Sync(config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	// Releases are updated before one is selected, so the selected release has its category.
+	for _, r := range requests() {
+		if r.Method == http.MethodPut && r.Path == "/api/v4/projects/1/releases/v1.0.0" {
+			assert.Contains(t, r.Body["description"], "**Category:** feature")
+			return
+		}
+	}
+	assert.Fail(t, "release has not been updated")
+}
+
+func TestSyncFailures(t *testing.T) {
+	t.Parallel()
+
+	for _, failFast := range []bool{false, true} {
+		failFast := failFast
+
+		t.Run(fmt.Sprintf("failFast=%t", failFast), func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := syncFixturesRepository(t)
+			server, requests := fixturesServer(t, filepath.Join("testdata", "fixtures", "sync-failures.json"))
+
+			errE := Sync(&Config{
+				ChangeTo:   kong.ChangeDirFlag(tempDir),
+				Project:    "1",
+				BaseURL:    server.URL,
+				Token:      "token",
+				Changelog:  "CHANGELOG.md",
+				HTTPClient: server.Client(),
+				FailFast:   failFast,
+			})
+
+			mutations := []string{}
+			for _, r := range requests() {
+				if r.Method != http.MethodGet {
+					mutations = append(mutations, r.Method+" "+r.Path)
+				}
+			}
+
+			if failFast {
+				assert.ErrorContains(t, errE, "403")
+				// Syncing stops at the first release which fails.
+				assert.Empty(t, mutations)
+			} else {
+				assert.ErrorContains(t, errE, "failed to sync GitLab releases")
+				assert.Equal(t, []string{"v1.0.0"}, errors.AllDetails(errE)["tags"])
+				// Other releases are still synced, but releases are not deleted.
+				assert.Equal(t, []string{"POST /api/v4/projects/1/releases"}, mutations)
+			}
+		})
+	}
+}
+
+func TestSyncReadOnlyModesCreateNoMilestones(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config func(config *Config)
+	}{
+		{"preview-links", func(config *Config) { config.PreviewLinks = true }},
+		{"export-github-only", func(config *Config) {
+			config.ExportGitHub = "releases.json"
+			config.ExportGitHubOnly = true
+		}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := syncFixturesRepository(t)
+			server, requests := fixturesServer(t, filepath.Join("testdata", "fixtures", "sync.json"))
+
+			// Release 0.1.0 does not have a milestone.
+			config := &Config{
+				ChangeTo:         kong.ChangeDirFlag(tempDir),
+				Project:          "1",
+				BaseURL:          server.URL,
+				Token:            "token",
+				Changelog:        "CHANGELOG.md",
+				HTTPClient:       server.Client(),
+				CreateMilestones: true,
+			}
+			tt.config(config)
+			errE := Sync(config)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			for _, r := range requests() {
+				assert.Equal(t, http.MethodGet, r.Method, r.Path)
+			}
+		})
+	}
+}
+
+func TestSyncReadOnlyModesCreateNoTags(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name   string
+		config func(config *Config)
+	}{
+		{"preview-links", func(config *Config) { config.PreviewLinks = true }},
+		{"export-github-only", func(config *Config) {
+			config.ExportGitHub = "releases.json"
+			config.ExportGitHubOnly = true
+		}},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := syncFixturesRepository(t)
+			err := os.WriteFile(filepath.Join(tempDir, "CHANGELOG.md"), []byte(
+				"# Changelog\n\n## [1.1.0] - 2023-07-01\n### Added\n- Another feature.\n\n"+
+					"## [1.0.0] - 2023-06-01\n### Added\n- Feature.\n\n## [0.1.0] - 2023-01-01\n### Added\n- Initial release.\n",
+			), 0o600)
+			require.NoError(t, err)
+			server, _ := fixturesServer(t, filepath.Join("testdata", "fixtures", "sync.json"))
+
+			config := &Config{
+				ChangeTo:          kong.ChangeDirFlag(tempDir),
+				Project:           "1",
+				BaseURL:           server.URL,
+				Token:             "token",
+				Changelog:         "CHANGELOG.md",
+				HTTPClient:        server.Client(),
+				CreateMissingTags: true,
+			}
+			tt.config(config)
+			// Release 1.1.0 does not have a tag, which is an error because it is not created.
+			errE := Sync(config)
+			assert.EqualError(t, errE, "found changelog releases not among git tags")
+
+			tags, errE := gitTags(tempDir, newWarnings(io.Discard))
+			require.NoError(t, errE, "% -+#.1v", errE)
+			names := []string{}
+			for _, tag := range tags {
+				names = append(names, tag.Name)
+			}
+			assert.ElementsMatch(t, []string{"v0.1.0", "v1.0.0"}, names)
+		})
+	}
+}
+
+func TestSyncTagCreatesOnlySelectedTag(t *testing.T) {
+	t.Parallel()
+
+	tempDir := syncFixturesRepository(t)
+	err := os.WriteFile(filepath.Join(tempDir, "CHANGELOG.md"), []byte(
+		"# Changelog\n\n## [1.2.0] - 2023-08-01\n### Added\n- Third feature.\n\n## [1.1.0] - 2023-07-01\n### Added\n- Another feature.\n\n"+
+			"## [1.0.0] - 2023-06-01\n### Added\n- Feature.\n\n## [0.1.0] - 2023-01-01\n### Added\n- Initial release.\n",
+	), 0o600)
+	require.NoError(t, err)
+	repository, err := git.PlainOpen(tempDir)
+	require.NoError(t, err)
+	cfg, err := repository.Config()
+	require.NoError(t, err)
+	cfg.User.Name = "John Doe"
+	cfg.User.Email = "john@doe.org"
+	err = repository.SetConfig(cfg)
+	require.NoError(t, err)
+
+	server, requests := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/1":
+			_, _ = w.Write([]byte(`{"id": 1, "issues_access_level": "disabled", "packages_enabled": false, "container_registry_access_level": "disabled"}`))
+		case r.Method == http.MethodGet && strings.HasPrefix(r.URL.Path, "/api/v4/projects/1/repository/commits/"):
+			_, _ = w.Write([]byte(`{"id": "` + strings.TrimPrefix(r.URL.Path, "/api/v4/projects/1/repository/commits/") + `"}`))
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v4/projects/1/releases":
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"tag_name": "v1.1.0"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/1/releases/v1.1.0/assets/links":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "404 Not Found"}`))
+		}
+	})
+
+	errE := Sync(&Config{
+		ChangeTo:          kong.ChangeDirFlag(tempDir),
+		Project:           "1",
+		BaseURL:           server.URL,
+		Token:             "token",
+		Changelog:         "CHANGELOG.md",
+		HTTPClient:        server.Client(),
+		Tag:               "v1.1.0",
+		CreateMissingTags: true,
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Contains(t, requests(), "POST /api/v4/projects/1/releases")
+
+	tags, errE := gitTags(tempDir, newWarnings(io.Discard))
+	require.NoError(t, errE, "% -+#.1v", errE)
+	names := []string{}
+	for _, tag := range tags {
+		names = append(names, tag.Name)
+	}
+	assert.ElementsMatch(t, []string{"v0.1.0", "v1.0.0", "v1.1.0"}, names)
+}
+
+func TestSyncNothing(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	_, err := git.PlainInit(tempDir, false)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(tempDir, "CHANGELOG.md"), []byte("# Changelog\n\n## [Unreleased]\n"), 0o600)
+	require.NoError(t, err)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Fail(t, "unexpected request", "%s %s", r.Method, r.URL.Path)
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	t.Cleanup(server.Close)
+
+	errE := Sync(&Config{
+		ChangeTo:   kong.ChangeDirFlag(tempDir),
+		Project:    "1",
+		BaseURL:    server.URL,
+		Token:      "token",
+		Changelog:  "CHANGELOG.md",
+		HTTPClient: server.Client(),
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+}
+
+// tokenTransport records which token was used for each request.
+type tokenTransport struct {
+	base   http.RoundTripper
+	mu     sync.Mutex
+	tokens map[string]string
+}
+
+func (t *tokenTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	t.mu.Lock()
+	t.tokens[req.Method+" "+req.URL.Path] = req.Header.Get("Private-Token")
+	t.mu.Unlock()
+	return t.base.RoundTrip(req)
+}
+
+func TestSyncReadToken(t *testing.T) {
+	t.Parallel()
+
+	tempDir := syncFixturesRepository(t)
+
+	server, _ := fixturesServer(t, filepath.Join("testdata", "fixtures", "sync.json"))
+	transport := &tokenTransport{base: server.Client().Transport, tokens: map[string]string{}}
+
+	errE := Sync(&Config{
+		ChangeTo:   kong.ChangeDirFlag(tempDir),
+		Project:    "1",
+		BaseURL:    server.URL,
+		Token:      "write",
+		ReadToken:  "read",
+		Changelog:  "CHANGELOG.md",
+		HTTPClient: &http.Client{Transport: transport},
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	transport.mu.Lock()
+	defer transport.mu.Unlock()
+	assert.Equal(t, "read", transport.tokens["GET /api/v4/projects/1/releases/v1.0.0"])
+	// All requests which only read use the read token.
+	for request, token := range transport.tokens {
+		if strings.HasPrefix(request, http.MethodGet+" ") {
+			assert.Equal(t, "read", token, request)
+		} else {
+			assert.Equal(t, "write", token, request)
+		}
+	}
+}
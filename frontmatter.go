@@ -0,0 +1,104 @@
+package release
+
+import (
+	"bufio"
+	"bytes"
+
+	"gitlab.com/tozd/go/errors"
+	"gopkg.in/yaml.v3"
+)
+
+// frontMatter holds configuration which can be set in the YAML front matter
+// at the start of the changelog file. It is applied to Config only for options
+// which have not been set otherwise, so command line flags and environment
+// variables take precedence. Boolean options are pointers to distinguish options
+// not set in front matter.
+type frontMatter struct {
+	DescriptionTemplate string   `yaml:"description-template"`
+	LinkNameTemplate    string   `yaml:"link-name-template"`
+	Metadata            string   `yaml:"metadata"`
+	MilestoneMulti      *bool    `yaml:"milestone-multi"`
+	NameTemplate        string   `yaml:"name-template"`
+	NormalizeMarkdown   *bool    `yaml:"normalize-markdown"`
+	PackagesProjects    []string `yaml:"packages-projects"`
+}
+
+// apply sets config options from front matter f which are not already set in config.
+// Boolean options are set only if their flags have not been explicitly provided,
+// because false cannot be distinguished from an unset option otherwise.
+func (f *frontMatter) apply(config *Config) {
+	if config.DescriptionTemplate == "" {
+		config.DescriptionTemplate = f.DescriptionTemplate
+	}
+	if config.LinkNameTemplate == "" {
+		config.LinkNameTemplate = f.LinkNameTemplate
+	}
+	if config.Metadata == "" {
+		config.Metadata = f.Metadata
+	}
+	if f.MilestoneMulti != nil && !config.explicitFlags["milestone-multi"] {
+		config.MilestoneMulti = *f.MilestoneMulti
+	}
+	if config.NameTemplate == "" {
+		config.NameTemplate = f.NameTemplate
+	}
+	if f.NormalizeMarkdown != nil && !config.explicitFlags["normalize-markdown"] {
+		config.NormalizeMarkdown = *f.NormalizeMarkdown
+	}
+	if len(config.PackagesProjects) == 0 {
+		config.PackagesProjects = f.PackagesProjects
+	}
+}
+
+// splitFrontMatter splits data into YAML front matter (delimited by "---" lines at
+// the very start of data) and the rest of data. If there is no front matter,
+// returned front matter is nil and the rest is data itself.
+//
+// Lines of the front matter are replaced with empty lines in the rest of data,
+// so that line numbers in the rest match line numbers in data.
+func splitFrontMatter(data []byte) ([]byte, []byte, errors.E) {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	if !scanner.Scan() || scanner.Text() != "---" {
+		return nil, data, nil
+	}
+
+	var front bytes.Buffer
+	lines := 1
+	for scanner.Scan() {
+		lines++
+		if scanner.Text() == "---" {
+			rest := data
+			for i := 0; i < lines && len(rest) > 0; i++ {
+				j := bytes.IndexByte(rest, '\n')
+				if j < 0 {
+					rest = nil
+					break
+				}
+				rest = rest[j+1:]
+			}
+			return front.Bytes(), append(bytes.Repeat([]byte("\n"), lines), rest...), nil
+		}
+		front.WriteString(scanner.Text())
+		front.WriteString("\n")
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, nil, errors.WithStack(err)
+	}
+
+	return nil, nil, errors.New("changelog front matter is not closed")
+}
+
+// parseFrontMatter parses YAML front matter. Unknown keys are rejected.
+func parseFrontMatter(data []byte) (*frontMatter, errors.E) {
+	f := &frontMatter{} //nolint:exhaustruct
+	if len(bytes.TrimSpace(data)) == 0 {
+		return f, nil
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err := decoder.Decode(f)
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot parse changelog front matter")
+	}
+	return f, nil
+}
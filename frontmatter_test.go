@@ -0,0 +1,70 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
+	"gitlab.com/tozd/go/errors"
+)
+
+func TestChangelogFrontMatter(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+	err := os.WriteFile(changelogPath, []byte(
+		"---\nnormalize-markdown: true\npackages-projects:\n  - group/artifacts\n---\n"+
+			"# Changelog\n\n## [1.0.0] - 2017-06-20\n### Added\n- Feature.\n\n## [0.1.0]\n",
+	), 0o600)
+	require.NoError(t, err)
+
+	_, _, errE := changelogReleases(changelogPath, []string{"Unreleased"})
+	assert.EqualError(t, errE, "release in the changelog is missing date")
+	// Line numbers account for the front matter.
+	assert.Equal(t, 12, errors.AllDetails(errE)["line"])
+
+	err = os.WriteFile(changelogPath, []byte(
+		"---\nnormalize-markdown: true\npackages-projects:\n  - group/artifacts\n---\n"+
+			"# Changelog\n\n## [1.0.0] - 2017-06-20\n### Added\n- Feature.\n",
+	), 0o600)
+	require.NoError(t, err)
+
+	releases, front, errE := changelogReleases(changelogPath, []string{"Unreleased"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	require.Len(t, releases, 1)
+	assert.Equal(t, "### Added\n- Feature.", releases[0].Changes)
+	assert.Equal(t, &frontMatter{
+		NormalizeMarkdown: gitlab.Bool(true),
+		PackagesProjects:  []string{"group/artifacts"},
+	}, front)
+
+	config := &Config{PackagesProjects: []string{"group/other"}}
+	front.apply(config)
+	assert.True(t, config.NormalizeMarkdown)
+	// Explicitly set options take precedence.
+	assert.Equal(t, []string{"group/other"}, config.PackagesProjects)
+
+	// Boolean options can be explicitly disabled on the command line.
+	config = &Config{}
+	parser, err := kong.New(config, kong.Vars{"version": ""})
+	require.NoError(t, err)
+	_, err = parser.Parse([]string{"--no-normalize-markdown"})
+	require.NoError(t, err)
+	front.apply(config)
+	assert.False(t, config.NormalizeMarkdown)
+
+	err = os.WriteFile(changelogPath, []byte("---\nunknown: true\n---\n# Changelog\n"), 0o600)
+	require.NoError(t, err)
+	_, _, errE = changelogReleases(changelogPath, []string{"Unreleased"})
+	assert.ErrorContains(t, errE, "cannot parse changelog front matter")
+
+	err = os.WriteFile(changelogPath, []byte("---\nnormalize-markdown: true\n# Changelog\n"), 0o600)
+	require.NoError(t, err)
+	_, _, errE = changelogReleases(changelogPath, []string{"Unreleased"})
+	assert.EqualError(t, errE, "changelog front matter is not closed")
+}
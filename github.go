@@ -0,0 +1,90 @@
+package release
+
+import (
+	"encoding/json"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// githubRelease is a payload for GitHub Releases API endpoint to create a release,
+// extended with assets which have to be uploaded or linked separately.
+type githubRelease struct {
+	TagName    string        `json:"tag_name"`
+	Name       string        `json:"name"`
+	Body       string        `json:"body"`
+	Draft      bool          `json:"draft"`
+	Prerelease bool          `json:"prerelease"`
+	MakeLatest string        `json:"make_latest"`
+	Assets     []githubAsset `json:"assets"`
+}
+
+// githubAsset describes an asset of a GitHub release.
+type githubAsset struct {
+	Name               string `json:"name"`
+	BrowserDownloadURL string `json:"browser_download_url"`
+}
+
+// newGitHubRelease computes GitHub payload for the release. Only assets from the changelog are
+// included because package links point to the GitLab instance.
+func newGitHubRelease(config *Config, release Release, images []string) (githubRelease, errors.E) {
+	name, errE := releaseName(config, release)
+	if errE != nil {
+		return githubRelease{}, errE //nolint:exhaustruct
+	}
+	description, errE := releaseDescription(config, release, images)
+	if errE != nil {
+		return githubRelease{}, errE //nolint:exhaustruct
+	}
+	// The marker is used to recognize releases managed on GitLab, so it is not needed on GitHub.
+	body := strings.TrimPrefix(description, descriptionMarker+"\n\n")
+
+	assets := []githubAsset{}
+	for _, asset := range releaseAssets(config, release) {
+		assets = append(assets, githubAsset{
+			Name:               asset.Name,
+			BrowserDownloadURL: asset.URL,
+		})
+	}
+
+	match := semverRegex.FindStringSubmatch(removeVPrefix(release.Tag))
+	makeLatest := "false"
+	if release.Latest {
+		makeLatest = "true"
+	}
+
+	return githubRelease{
+		TagName:    releaseGitTag(release),
+		Name:       name,
+		Body:       body,
+		Draft:      false,
+		Prerelease: match != nil && match[4] != "",
+		MakeLatest: makeLatest,
+		Assets:     assets,
+	}, nil
+}
+
+// exportGitHub writes GitHub payloads for releases to the file at path.
+func exportGitHub(config *Config, path string, releases []Release, tagsToImages map[string][]string) errors.E {
+	payloads := make([]githubRelease, 0, len(releases))
+	for _, release := range releases {
+		payload, errE := newGitHubRelease(config, release, tagsToImages[release.Tag])
+		if errE != nil {
+			return errE
+		}
+		payloads = append(payloads, payload)
+	}
+
+	data, err := json.MarshalIndent(payloads, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	data = append(data, '\n')
+	err = writeFileAtomically(path, data)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot write GitHub export")
+		errors.Details(errE)["path"] = path
+		return errE
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package release
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExportGitHub(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{ //nolint:exhaustruct
+		BaseURL: "https://gitlab.com",
+		Project: "group/project",
+	}
+	releases := []Release{
+		{ //nolint:exhaustruct
+			Tag:     "v2.0.0-rc.1",
+			Changes: "- Candidate.",
+			Assets:  []Asset{{Name: "Binary", URL: "https://example.com/binary"}},
+		},
+		{ //nolint:exhaustruct
+			Tag:     "v1.0.0",
+			Changes: "- Feature.",
+			Latest:  true,
+		},
+	}
+	tagsToImages := map[string][]string{
+		"v1.0.0": {"registry.gitlab.com/group/project:v1.0.0"},
+	}
+
+	path := filepath.Join(t.TempDir(), "github.json")
+	errE := exportGitHub(config, path, releases, tagsToImages)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var payloads []githubRelease
+	err = json.Unmarshal(data, &payloads)
+	require.NoError(t, err)
+
+	description, errE := releaseDescription(config, releases[1], tagsToImages["v1.0.0"])
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	assert.Equal(t, []githubRelease{
+		{
+			TagName:    "v2.0.0-rc.1",
+			Name:       "v2.0.0-rc.1",
+			Body:       "- Candidate.",
+			Draft:      false,
+			Prerelease: true,
+			MakeLatest: "false",
+			Assets:     []githubAsset{{Name: "Binary", BrowserDownloadURL: "https://example.com/binary"}},
+		},
+		{
+			TagName:    "v1.0.0",
+			Name:       "v1.0.0",
+			Body:       description[len(descriptionMarker+"\n\n"):],
+			Draft:      false,
+			Prerelease: false,
+			MakeLatest: "true",
+			Assets:     []githubAsset{},
+		},
+	}, payloads)
+	assert.Contains(t, payloads[1].Body, "registry.gitlab.com/group/project:v1.0.0")
+	assert.NotContains(t, string(data), descriptionMarker)
+}
@@ -0,0 +1,156 @@
+package release
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+const (
+	graphFormatDOT     = "dot"
+	graphFormatMermaid = "mermaid"
+)
+
+const (
+	graphNodeRelease   = "release"
+	graphNodeMilestone = "milestone"
+	graphNodePackage   = "package"
+	graphNodeImage     = "image"
+)
+
+type graphNode struct {
+	ID    string
+	Kind  string
+	Label string
+}
+
+type graphEdge struct {
+	From string
+	To   string
+}
+
+// mappingGraph converts milestones, packages, and Docker images mapped to each
+// release into graph nodes and edges from releases to what is mapped to them.
+// Milestones, packages, and images mapped to multiple releases are one node.
+func mappingGraph(
+	releases []Release, tagsToMilestones map[string][]string,
+	tagsToPackages map[string][]Package, tagsToImages map[string][]string,
+) ([]graphNode, []graphEdge) {
+	nodes := []graphNode{}
+	edges := []graphEdge{}
+	keysToIDs := map[string]string{}
+
+	node := func(kind, key, label string) string {
+		key = kind + ":" + key
+		if id, ok := keysToIDs[key]; ok {
+			return id
+		}
+		id := fmt.Sprintf("n%d", len(nodes))
+		keysToIDs[key] = id
+		nodes = append(nodes, graphNode{ID: id, Kind: kind, Label: label})
+		return id
+	}
+
+	for _, release := range releases {
+		r := node(graphNodeRelease, release.Tag, release.Tag)
+		for _, milestone := range tagsToMilestones[release.Tag] {
+			edges = append(edges, graphEdge{From: r, To: node(graphNodeMilestone, milestone, milestone)})
+		}
+		for _, p := range tagsToPackages[release.Tag] {
+			label := fmt.Sprintf("%s %s", p.Name, p.Version)
+			if p.Project != "" {
+				label += fmt.Sprintf(" (project %s)", p.Project)
+			}
+			edges = append(edges, graphEdge{From: r, To: node(graphNodePackage, fmt.Sprintf("%s:%d", p.Project, p.ID), label)})
+		}
+		for _, image := range tagsToImages[release.Tag] {
+			edges = append(edges, graphEdge{From: r, To: node(graphNodeImage, image, image)})
+		}
+	}
+
+	return nodes, edges
+}
+
+// writeGraph writes milestones, packages, and Docker images mapped to each
+// release as a Graphviz DOT or Mermaid diagram.
+func writeGraph(
+	w io.Writer, format string, releases []Release, tagsToMilestones map[string][]string,
+	tagsToPackages map[string][]Package, tagsToImages map[string][]string,
+) errors.E {
+	nodes, edges := mappingGraph(releases, tagsToMilestones, tagsToPackages, tagsToImages)
+
+	switch format {
+	case graphFormatDOT:
+		shapes := map[string]string{
+			graphNodeRelease:   "box",
+			graphNodeMilestone: "ellipse",
+			graphNodePackage:   "component",
+			graphNodeImage:     "cylinder",
+		}
+		fmt.Fprintf(w, "digraph releases {\n")
+		fmt.Fprintf(w, "  rankdir=LR;\n")
+		for _, n := range nodes {
+			fmt.Fprintf(w, "  %s [label=%s, shape=%s];\n", n.ID, strconv.Quote(n.Label), shapes[n.Kind])
+		}
+		for _, e := range edges {
+			fmt.Fprintf(w, "  %s -> %s;\n", e.From, e.To)
+		}
+		fmt.Fprintf(w, "}\n")
+	case graphFormatMermaid:
+		// Mermaid does not support escaping double quotes in labels, but it supports entity codes.
+		shapes := map[string][2]string{
+			graphNodeRelease:   {"[", "]"},
+			graphNodeMilestone: {"([", "])"},
+			graphNodePackage:   {"[[", "]]"},
+			graphNodeImage:     {"[(", ")]"},
+		}
+		fmt.Fprintf(w, "flowchart LR\n")
+		for _, n := range nodes {
+			label := strings.ReplaceAll(n.Label, `"`, "#quot;")
+			fmt.Fprintf(w, "  %s%s\"%s\"%s\n", n.ID, shapes[n.Kind][0], label, shapes[n.Kind][1])
+		}
+		for _, e := range edges {
+			fmt.Fprintf(w, "  %s --> %s\n", e.From, e.To)
+		}
+	default:
+		errE := errors.New("unknown graph format")
+		errors.Details(errE)["format"] = format
+		return errE
+	}
+
+	return nil
+}
+
+// graph writes the mapping diagram in config.Graph format to config.GraphOutput
+// (relative to dir) or to stdout if it is not set.
+func graph(
+	config *Config, dir string, releases []Release, tagsToMilestones map[string][]string,
+	tagsToPackages map[string][]Package, tagsToImages map[string][]string,
+) errors.E {
+	if config.GraphOutput == "" {
+		return writeGraph(os.Stdout, config.Graph, releases, tagsToMilestones, tagsToPackages, tagsToImages)
+	}
+
+	var out strings.Builder
+	errE := writeGraph(&out, config.Graph, releases, tagsToMilestones, tagsToPackages, tagsToImages)
+	if errE != nil {
+		return errE
+	}
+
+	path := config.GraphOutput
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	err := writeFileAtomically(path, []byte(out.String()))
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot write graph")
+		errors.Details(errE)["path"] = path
+		return errE
+	}
+	return nil
+}
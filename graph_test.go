@@ -0,0 +1,109 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteGraph(t *testing.T) {
+	t.Parallel()
+
+	releases := []Release{
+		{Tag: "v1.1.0"}, //nolint:exhaustruct
+		{Tag: "v1.0.0"}, //nolint:exhaustruct
+	}
+	tagsToMilestones := map[string][]string{
+		"v1.1.0": {"1.x"},
+		"v1.0.0": {"1.x", `Release "one"`},
+	}
+	tagsToPackages := map[string][]Package{
+		"v1.0.0": {
+			{ID: 1, Generic: true, Name: "foo", Version: "1.0.0"},       //nolint:exhaustruct
+			{ID: 2, Name: "bar", Version: "v1.0.0", Project: "group/x"}, //nolint:exhaustruct
+		},
+	}
+	tagsToImages := map[string][]string{"v1.1.0": {"registry.gitlab.com/group/project:1.1.0"}}
+
+	tests := []struct {
+		format   string
+		expected string
+	}{
+		{
+			graphFormatDOT,
+			"" +
+				"digraph releases {\n" +
+				"  rankdir=LR;\n" +
+				"  n0 [label=\"v1.1.0\", shape=box];\n" +
+				"  n1 [label=\"1.x\", shape=ellipse];\n" +
+				"  n2 [label=\"registry.gitlab.com/group/project:1.1.0\", shape=cylinder];\n" +
+				"  n3 [label=\"v1.0.0\", shape=box];\n" +
+				"  n4 [label=\"Release \\\"one\\\"\", shape=ellipse];\n" +
+				"  n5 [label=\"foo 1.0.0\", shape=component];\n" +
+				"  n6 [label=\"bar v1.0.0 (project group/x)\", shape=component];\n" +
+				"  n0 -> n1;\n" +
+				"  n0 -> n2;\n" +
+				"  n3 -> n1;\n" +
+				"  n3 -> n4;\n" +
+				"  n3 -> n5;\n" +
+				"  n3 -> n6;\n" +
+				"}\n",
+		},
+		{
+			graphFormatMermaid,
+			"" +
+				"flowchart LR\n" +
+				"  n0[\"v1.1.0\"]\n" +
+				"  n1([\"1.x\"])\n" +
+				"  n2[(\"registry.gitlab.com/group/project:1.1.0\")]\n" +
+				"  n3[\"v1.0.0\"]\n" +
+				"  n4([\"Release #quot;one#quot;\"])\n" +
+				"  n5[[\"foo 1.0.0\"]]\n" +
+				"  n6[[\"bar v1.0.0 (project group/x)\"]]\n" +
+				"  n0 --> n1\n" +
+				"  n0 --> n2\n" +
+				"  n3 --> n1\n" +
+				"  n3 --> n4\n" +
+				"  n3 --> n5\n" +
+				"  n3 --> n6\n",
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.format, func(t *testing.T) {
+			t.Parallel()
+
+			var out strings.Builder
+			errE := writeGraph(&out, tt.format, releases, tagsToMilestones, tagsToPackages, tagsToImages)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, tt.expected, out.String())
+		})
+	}
+
+	errE := writeGraph(&strings.Builder{}, "svg", releases, tagsToMilestones, tagsToPackages, tagsToImages)
+	assert.EqualError(t, errE, "unknown graph format")
+}
+
+func TestGraphOutput(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	config := &Config{ //nolint:exhaustruct
+		Graph:       graphFormatMermaid,
+		GraphOutput: "mapping.mmd",
+	}
+	releases := []Release{{Tag: "v1.0.0"}} //nolint:exhaustruct
+
+	errE := graph(config, dir, releases, map[string][]string{}, map[string][]Package{}, map[string][]string{})
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	data, err := os.ReadFile(filepath.Join(dir, "mapping.mmd"))
+	require.NoError(t, err)
+	assert.Equal(t, "flowchart LR\n  n0[\"v1.0.0\"]\n", string(data))
+}
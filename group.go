@@ -0,0 +1,80 @@
+package release
+
+import (
+	"github.com/xanzy/go-gitlab"
+	"gitlab.com/tozd/go/errors"
+)
+
+// GroupProjectReleases holds releases of one project in a GitLab group.
+type GroupProjectReleases struct {
+	// ProjectID is the numeric ID of the project.
+	ProjectID int
+	// Project is the full path of the project (i.e., <namespace/project_path>).
+	Project string
+	// Releases are project's releases, the latest first.
+	Releases []*gitlab.Release
+}
+
+// Latest returns the latest release of the project or nil if it has no releases.
+func (p GroupProjectReleases) Latest() *gitlab.Release {
+	if len(p.Releases) == 0 {
+		return nil
+	}
+	return p.Releases[0]
+}
+
+// groupProjects fetches all projects in GitLab groupID group, including projects in subgroups.
+func groupProjects(client *gitlab.Client, groupID string) ([]*gitlab.Project, errors.E) {
+	projects := []*gitlab.Project{}
+	options := &gitlab.ListGroupProjectsOptions{ //nolint:exhaustruct
+		ListOptions: gitlab.ListOptions{
+			PerPage: maxGitLabPageSize,
+			Page:    1,
+		},
+		IncludeSubGroups: gitlab.Bool(true),
+	}
+	for {
+		page, response, err := client.Groups.ListGroupProjects(groupID, options)
+		if err != nil {
+			errE := errors.WithMessage(err, "failed to list GitLab group projects")
+			errors.Details(errE)["group"] = groupID
+			errors.Details(errE)["page"] = options.Page
+			return nil, errE
+		}
+
+		projects = append(projects, page...)
+
+		if response.NextPage == 0 {
+			break
+		}
+
+		options.Page = response.NextPage
+	}
+	return projects, nil
+}
+
+// ListGroupReleases lists releases of all projects in GitLab groupID group,
+// including projects in subgroups. Projects without releases are included, too.
+//
+// It does not change anything and is meant for reporting.
+func ListGroupReleases(client *gitlab.Client, groupID string) ([]GroupProjectReleases, errors.E) {
+	projects, errE := groupProjects(client, groupID)
+	if errE != nil {
+		return nil, errE
+	}
+
+	result := make([]GroupProjectReleases, 0, len(projects))
+	for _, project := range projects {
+		releases, errE := projectReleases(client, project.PathWithNamespace, maxGitLabPageSize)
+		if errE != nil {
+			errors.Details(errE)["project"] = project.PathWithNamespace
+			return nil, errE
+		}
+		result = append(result, GroupProjectReleases{
+			ProjectID: project.ID,
+			Project:   project.PathWithNamespace,
+			Releases:  releases,
+		})
+	}
+	return result, nil
+}
@@ -0,0 +1,54 @@
+package release
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestListGroupReleases(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v4/groups/group/projects":
+			assert.Equal(t, "true", r.URL.Query().Get("include_subgroups"))
+			_, _ = w.Write([]byte(`[
+				{"id": 1, "path_with_namespace": "group/foo"},
+				{"id": 2, "path_with_namespace": "group/sub/bar"}
+			]`))
+		case "/api/v4/projects/group/foo/releases":
+			_, _ = w.Write([]byte(`[{"tag_name": "v2.0.0"}, {"tag_name": "v1.0.0"}]`))
+		case "/api/v4/projects/group/sub/bar/releases":
+			_, _ = w.Write([]byte(`[]`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	client, errE := newClient(&Config{BaseURL: server.URL}, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	result, errE := ListGroupReleases(client, "group")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	require.Len(t, result, 2)
+
+	assert.Equal(t, 1, result[0].ProjectID)
+	assert.Equal(t, "group/foo", result[0].Project)
+	require.Len(t, result[0].Releases, 2)
+	require.NotNil(t, result[0].Latest())
+	assert.Equal(t, "v2.0.0", result[0].Latest().TagName)
+
+	assert.Equal(t, 2, result[1].ProjectID)
+	assert.Equal(t, "group/sub/bar", result[1].Project)
+	assert.Empty(t, result[1].Releases)
+	assert.Nil(t, result[1].Latest())
+
+	_, errE = ListGroupReleases(client, "missing")
+	assert.ErrorContains(t, errE, "failed to list GitLab group projects")
+}
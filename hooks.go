@@ -0,0 +1,76 @@
+package release
+
+import (
+	"os"
+	"os/exec"
+	"runtime"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// Environment variables with information about the release passed to release commands.
+const (
+	releaseCommandTagEnv         = "GITLAB_RELEASE_TAG"
+	releaseCommandVersionEnv     = "GITLAB_RELEASE_VERSION"
+	releaseCommandNameEnv        = "GITLAB_RELEASE_NAME"
+	releaseCommandDescriptionEnv = "GITLAB_RELEASE_DESCRIPTION"
+)
+
+// runReleaseCommand runs command using the shell in dir, passing information
+// about the release in environment variables. Output of the command is passed through.
+func runReleaseCommand(command, dir, tag, version, name, description string) errors.E {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	cmd.Dir = dir
+	cmd.Env = append(
+		os.Environ(),
+		releaseCommandTagEnv+"="+tag,
+		releaseCommandVersionEnv+"="+version,
+		releaseCommandNameEnv+"="+name,
+		releaseCommandDescriptionEnv+"="+description,
+	)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err != nil {
+		errE := errors.WithStack(err)
+		errors.Details(errE)["command"] = command
+		return errE
+	}
+	return nil
+}
+
+// runReleaseHook runs the hook command (e.g., "pre-release") for the release, if set.
+//
+// If the command fails, an error is returned, unless config.IgnoreReleaseCommandFailures
+// is set, in which case a warning is made instead.
+func runReleaseHook(
+	config *Config, hook, command, dir string, release Release, images []string, warnings *warnings,
+) errors.E {
+	if command == "" {
+		return nil
+	}
+	name, errE := releaseName(config, release)
+	if errE != nil {
+		return errE
+	}
+	description, errE := releaseDescription(config, release, images)
+	if errE != nil {
+		return errE
+	}
+	errE = runReleaseCommand(command, dir, releaseGitTag(release), removeVPrefix(release.Tag), name, description)
+	if errE != nil {
+		if config.IgnoreReleaseCommandFailures {
+			warnings.Warnf("%s command for tag \"%s\" failed: %s.", hook, release.Tag, errE.Error())
+			return nil
+		}
+		errE = errors.WithMessage(errE, hook+" command failed")
+		errors.Details(errE)["tag"] = release.Tag
+		return errE
+	}
+	return nil
+}
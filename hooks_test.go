@@ -0,0 +1,45 @@
+package release
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
+)
+
+func TestRunReleaseHook(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses Unix shell")
+	}
+
+	tempDir := t.TempDir()
+	release := Release{Tag: "v1.0.0", Changes: "- Feature."} //nolint:exhaustruct
+
+	errE := runReleaseHook(&Config{}, "pre-release", "", tempDir, release, nil, nil) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	command := `printf '%s\n%s\n%s\n%s' "$GITLAB_RELEASE_TAG" "$GITLAB_RELEASE_VERSION" "$GITLAB_RELEASE_NAME" "$GITLAB_RELEASE_DESCRIPTION" > env.txt`
+	errE = runReleaseHook(&Config{}, "pre-release", command, tempDir, release, nil, nil) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	data, err := os.ReadFile(filepath.Join(tempDir, "env.txt"))
+	require.NoError(t, err)
+	description, errE := releaseDescription(&Config{}, release, nil) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "v1.0.0\n1.0.0\nv1.0.0\n"+description, string(data))
+
+	errE = runReleaseHook(&Config{}, "post-release", "exit 3", tempDir, release, nil, nil) //nolint:exhaustruct
+	assert.EqualError(t, errE, "post-release command failed: exit status 3")
+	assert.Equal(t, "v1.0.0", errors.AllDetails(errE)["tag"])
+
+	w := newWarnings(io.Discard)
+	errE = runReleaseHook(&Config{IgnoreReleaseCommandFailures: true}, "post-release", "exit 3", tempDir, release, nil, w) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []string{`post-release command for tag "v1.0.0" failed: exit status 3.`}, w.Messages())
+}
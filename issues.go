@@ -0,0 +1,116 @@
+package release
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+	"gitlab.com/tozd/go/errors"
+)
+
+// Issue is a GitLab issue closed as part of a release.
+type Issue struct {
+	IID    int
+	Title  string
+	WebURL string
+}
+
+// issuesSectionTitle is the title of the description section listing closed issues.
+const issuesSectionTitle = "Issues closed"
+
+// milestoneClosedIssues fetches closed issues of the milestone with title for GitLab projectID project,
+// ordered by their IIDs.
+func milestoneClosedIssues(client *gitlab.Client, projectID, title string, perPage int) ([]Issue, errors.E) {
+	issues := []Issue{}
+	options := &gitlab.ListProjectIssuesOptions{ //nolint:exhaustruct
+		ListOptions: gitlab.ListOptions{
+			PerPage: perPage,
+			Page:    1,
+		},
+		State:     gitlab.String("closed"),
+		Milestone: gitlab.String(title),
+		OrderBy:   gitlab.String("created_at"),
+		Sort:      gitlab.String("asc"),
+	}
+	for {
+		page, response, err := client.Issues.ListProjectIssues(projectID, options)
+		if err != nil {
+			errE := errors.WithMessage(err, "failed to list GitLab issues for milestone")
+			errors.Details(errE)["milestone"] = title
+			errors.Details(errE)["page"] = options.Page
+			return nil, errE
+		}
+
+		for _, issue := range page {
+			issues = append(issues, Issue{
+				IID:    issue.IID,
+				Title:  issue.Title,
+				WebURL: issue.WebURL,
+			})
+		}
+
+		if response.NextPage == 0 {
+			break
+		}
+
+		options.Page = response.NextPage
+	}
+	return issues, nil
+}
+
+// mapIssuesToTags fetches issues closed by milestones associated with releases and
+// maps them to releases' tags. Issues of each milestone are fetched only once and
+// an issue is listed only once for a release, even if it has multiple milestones.
+func mapIssuesToTags(
+	client *gitlab.Client, projectID string, releases []Release, tagsToMilestones map[string][]string, perPage int,
+) (map[string][]Issue, errors.E) {
+	milestonesToIssues := map[string][]Issue{}
+	tagsToIssues := map[string][]Issue{}
+	for _, release := range releases {
+		seen := map[int]bool{}
+		for _, milestone := range tagsToMilestones[release.Tag] {
+			issues, ok := milestonesToIssues[milestone]
+			if !ok {
+				var errE errors.E
+				issues, errE = milestoneClosedIssues(client, projectID, milestone, perPage)
+				if errE != nil {
+					return nil, errE
+				}
+				milestonesToIssues[milestone] = issues
+			}
+			for _, issue := range issues {
+				if seen[issue.IID] {
+					continue
+				}
+				seen[issue.IID] = true
+				tagsToIssues[release.Tag] = append(tagsToIssues[release.Tag], issue)
+			}
+		}
+	}
+	return tagsToIssues, nil
+}
+
+// applyIssues sets issues of releases from tagsToIssues.
+func applyIssues(releases []Release, tagsToIssues map[string][]Issue) {
+	for i := range releases {
+		releases[i].Issues = tagsToIssues[releases[i].Tag]
+	}
+}
+
+// issuesSection renders the description section listing closed issues,
+// or an empty string if there are no issues.
+func issuesSection(issues []Issue) string {
+	if len(issues) == 0 {
+		return ""
+	}
+	var section strings.Builder
+	section.WriteString("### " + issuesSectionTitle + "\n\n")
+	for _, issue := range issues {
+		if issue.WebURL != "" {
+			fmt.Fprintf(&section, "- [#%d](%s) %s\n", issue.IID, issue.WebURL, issue.Title)
+		} else {
+			fmt.Fprintf(&section, "- #%d %s\n", issue.IID, issue.Title)
+		}
+	}
+	return strings.TrimSuffix(section.String(), "\n")
+}
@@ -0,0 +1,79 @@
+package release
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMapIssuesToTags(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	requests := map[string]int{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/v4/projects/1/issues" {
+			http.NotFound(w, r)
+			return
+		}
+		assert.Equal(t, "closed", r.URL.Query().Get("state"))
+		milestone, page := r.URL.Query().Get("milestone"), r.URL.Query().Get("page")
+		mu.Lock()
+		requests[milestone]++
+		mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch milestone + "@" + page {
+		case "1.0@1":
+			w.Header().Set("X-Next-Page", "2")
+			_, _ = w.Write([]byte(`[{"id": 101, "iid": 1, "title": "First", "web_url": "https://gitlab.com/group/project/-/issues/1"}]`))
+		case "1.0@2":
+			_, _ = w.Write([]byte(`[{"id": 102, "iid": 2, "title": "Second", "web_url": "https://gitlab.com/group/project/-/issues/2"}]`))
+		case "1.0.1@1":
+			_, _ = w.Write([]byte(`[{"id": 102, "iid": 2, "title": "Second", "web_url": "https://gitlab.com/group/project/-/issues/2"}, {"id": 103, "iid": 3, "title": "Third"}]`))
+		default:
+			_, _ = w.Write([]byte(`[]`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	config := &Config{BaseURL: server.URL, Project: "1", Token: "token", HTTPClient: server.Client()} //nolint:exhaustruct
+	client, errE := newClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	releases := []Release{{Tag: "v1.0.1"}, {Tag: "v1.0.0"}, {Tag: "v0.1.0"}} //nolint:exhaustruct
+	tagsToMilestones := map[string][]string{
+		"v1.0.1": {"1.0", "1.0.1"},
+		"v1.0.0": {"1.0"},
+	}
+	tagsToIssues, errE := mapIssuesToTags(client, "1", releases, tagsToMilestones, 1)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	first := Issue{IID: 1, Title: "First", WebURL: "https://gitlab.com/group/project/-/issues/1"}
+	second := Issue{IID: 2, Title: "Second", WebURL: "https://gitlab.com/group/project/-/issues/2"}
+	third := Issue{IID: 3, Title: "Third", WebURL: ""}
+	assert.Equal(t, map[string][]Issue{
+		// Issue 2 is closed by both milestones, but it is listed only once.
+		"v1.0.1": {first, second, third},
+		"v1.0.0": {first, second},
+	}, tagsToIssues)
+	// Issues of milestone "1.0" are fetched only once (two pages).
+	assert.Equal(t, map[string]int{"1.0": 2, "1.0.1": 1}, requests)
+
+	applyIssues(releases, tagsToIssues)
+	assert.Nil(t, releases[2].Issues)
+
+	description, errE := releaseDescription(config, releases[1], nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, fmt.Sprintf(
+		"%s\n\n### Issues closed\n\n- [#1](%s) First\n- [#2](%s) Second",
+		descriptionMarker, first.WebURL, second.WebURL,
+	), description)
+
+	assert.Equal(t, "### Issues closed\n\n- #3 Third", issuesSection([]Issue{third}))
+	assert.Equal(t, "", issuesSection(nil))
+}
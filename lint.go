@@ -0,0 +1,245 @@
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+const (
+	lintFormatCodeQuality = "codequality"
+	lintFormatSARIF       = "sarif"
+)
+
+// lintFinding is a problem found in the changelog.
+type lintFinding struct {
+	Description string
+	Path        string
+	Line        int
+}
+
+// codeQualityIssue is an issue in GitLab Code Quality report format.
+type codeQualityIssue struct {
+	Description string              `json:"description"`
+	CheckName   string              `json:"check_name"`
+	Fingerprint string              `json:"fingerprint"`
+	Severity    string              `json:"severity"`
+	Location    codeQualityLocation `json:"location"`
+}
+
+type codeQualityLocation struct {
+	Path  string           `json:"path"`
+	Lines codeQualityLines `json:"lines"`
+}
+
+type codeQualityLines struct {
+	Begin int `json:"begin"`
+}
+
+// sarifLog is a minimal SARIF 2.1.0 log.
+type sarifLog struct {
+	Version string     `json:"version"`
+	Schema  string     `json:"$schema"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri"`
+}
+
+type sarifResult struct {
+	RuleID    string          `json:"ruleId"`
+	Level     string          `json:"level"`
+	Message   sarifMessage    `json:"message"`
+	Locations []sarifLocation `json:"locations"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+	Region           sarifRegion           `json:"region"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type sarifRegion struct {
+	StartLine int `json:"startLine"`
+}
+
+// lintChangelog validates the changelog the same way syncing does, but without
+// accessing GitLab API. It returns the first problem found.
+func lintChangelog(config *Config, dir string) (string, errors.E) {
+	if config.FromCommits {
+		return "", errors.New("linting requires a changelog")
+	}
+	changelogPath, errE := resolveChangelogPath(config, dir)
+	if errE != nil {
+		return "", errE
+	}
+	releases, _, errE := loadReleases(config, dir, nil)
+	if errE != nil {
+		return changelogPath, errE
+	}
+	if config.ChangelogAssets {
+		errE = extractAssets(releases)
+		if errE != nil {
+			return changelogPath, errE
+		}
+	}
+	if config.RequireNotes {
+		errE = checkNotes(releases)
+		if errE != nil {
+			return changelogPath, errE
+		}
+	}
+	return changelogPath, nil
+}
+
+// newLintFinding converts the error into a finding, using path and line from
+// error details if available. Findings without a line are reported at the first line.
+func newLintFinding(errE errors.E, changelogPath, dir string) lintFinding {
+	details := errors.AllDetails(errE)
+	path := changelogPath
+	if p, ok := details["path"].(string); ok && p != "" {
+		path = p
+	}
+	if !isChangelogURL(path) {
+		if rel, err := filepath.Rel(dir, path); err == nil && !strings.HasPrefix(rel, "..") {
+			path = rel
+		}
+		path = filepath.ToSlash(path)
+	}
+	line, ok := details["line"].(int)
+	if !ok || line < 1 {
+		line = 1
+	}
+
+	description := errE.Error()
+	if release, ok := details["release"].(string); ok {
+		description += fmt.Sprintf(" (release %s)", release)
+	} else if releases, ok := details["releases"].([]string); ok {
+		description += fmt.Sprintf(" (releases %s)", strings.Join(releases, ", "))
+	}
+
+	return lintFinding{
+		Description: description,
+		Path:        path,
+		Line:        line,
+	}
+}
+
+// lintReport renders findings in the format.
+func lintReport(format string, findings []lintFinding) ([]byte, errors.E) {
+	var report interface{}
+	switch format {
+	case lintFormatCodeQuality:
+		issues := make([]codeQualityIssue, 0, len(findings))
+		for _, f := range findings {
+			fingerprint := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%s", f.Path, f.Line, f.Description)))
+			issues = append(issues, codeQualityIssue{
+				Description: f.Description,
+				CheckName:   "changelog",
+				Fingerprint: hex.EncodeToString(fingerprint[:]),
+				Severity:    "major",
+				Location: codeQualityLocation{
+					Path:  f.Path,
+					Lines: codeQualityLines{Begin: f.Line},
+				},
+			})
+		}
+		report = issues
+	case lintFormatSARIF:
+		results := make([]sarifResult, 0, len(findings))
+		for _, f := range findings {
+			results = append(results, sarifResult{
+				RuleID:  "changelog",
+				Level:   "error",
+				Message: sarifMessage{Text: f.Description},
+				Locations: []sarifLocation{{
+					PhysicalLocation: sarifPhysicalLocation{
+						ArtifactLocation: sarifArtifactLocation{URI: f.Path},
+						Region:           sarifRegion{StartLine: f.Line},
+					},
+				}},
+			})
+		}
+		report = sarifLog{
+			Version: "2.1.0",
+			Schema:  "https://json.schemastore.org/sarif-2.1.0.json",
+			Runs: []sarifRun{{
+				Tool: sarifTool{Driver: sarifDriver{
+					Name:           "gitlab-release",
+					InformationURI: "https://gitlab.com/tozd/gitlab/release",
+				}},
+				Results: results,
+			}},
+		}
+	default:
+		errE := errors.New("unknown lint format")
+		errors.Details(errE)["format"] = format
+		return nil, errE
+	}
+
+	data, err := json.MarshalIndent(report, "", "  ")
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	return append(data, '\n'), nil
+}
+
+// lint validates the changelog and writes findings in config.LintFormat to config.LintOutput.
+// If the changelog is invalid, the report is written and the error is returned as well.
+func lint(config *Config) errors.E {
+	dir := workDir(config)
+
+	findings := []lintFinding{}
+	changelogPath, lintErrE := lintChangelog(config, dir)
+	if lintErrE != nil {
+		findings = append(findings, newLintFinding(lintErrE, changelogPath, dir))
+	}
+
+	data, errE := lintReport(config.LintFormat, findings)
+	if errE != nil {
+		return errE
+	}
+	outputPath := config.LintOutput
+	if !filepath.IsAbs(outputPath) {
+		outputPath = filepath.Join(dir, outputPath)
+	}
+	err := writeFileAtomically(outputPath, data)
+	if err != nil {
+		errE = errors.WithMessage(err, "cannot write lint report")
+		errors.Details(errE)["path"] = outputPath
+		return errE
+	}
+
+	if lintErrE != nil {
+		return lintErrE
+	}
+	fmt.Printf("Changelog is valid.\n")
+	return nil
+}
@@ -0,0 +1,79 @@
+package release
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/alecthomas/kong"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLint(t *testing.T) {
+	t.Parallel()
+
+	for _, format := range []string{lintFormatCodeQuality, lintFormatSARIF} {
+		format := format
+
+		t.Run(format, func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			changelog := "# Changelog\n\n## [Unreleased]\n\n## [1.0.0]\n### Added\n- Feature.\n"
+			err := os.WriteFile(filepath.Join(tempDir, "CHANGELOG.md"), []byte(changelog), 0o600)
+			require.NoError(t, err)
+
+			errE := lint(&Config{ //nolint:exhaustruct
+				ChangeTo:   kong.ChangeDirFlag(tempDir),
+				LintFormat: format,
+				LintOutput: "report.json",
+			})
+			assert.EqualError(t, errE, "release in the changelog is missing date")
+
+			data, err := os.ReadFile(filepath.Join(tempDir, "report.json"))
+			require.NoError(t, err)
+
+			switch format {
+			case lintFormatCodeQuality:
+				var issues []codeQualityIssue
+				err = json.Unmarshal(data, &issues)
+				require.NoError(t, err)
+				require.Len(t, issues, 1)
+				assert.Equal(t, "release in the changelog is missing date (release 1.0.0)", issues[0].Description)
+				assert.Equal(t, "CHANGELOG.md", issues[0].Location.Path)
+				assert.Equal(t, 5, issues[0].Location.Lines.Begin)
+				assert.NotEmpty(t, issues[0].Fingerprint)
+			case lintFormatSARIF:
+				var log sarifLog
+				err = json.Unmarshal(data, &log)
+				require.NoError(t, err)
+				require.Len(t, log.Runs, 1)
+				require.Len(t, log.Runs[0].Results, 1)
+				result := log.Runs[0].Results[0]
+				assert.Equal(t, "release in the changelog is missing date (release 1.0.0)", result.Message.Text)
+				assert.Equal(t, "CHANGELOG.md", result.Locations[0].PhysicalLocation.ArtifactLocation.URI)
+				assert.Equal(t, 5, result.Locations[0].PhysicalLocation.Region.StartLine)
+			}
+
+			// After fixing the changelog, the report is empty.
+			changelog = "# Changelog\n\n## [Unreleased]\n\n## [1.0.0] - 2023-01-01\n### Added\n- Feature.\n"
+			err = os.WriteFile(filepath.Join(tempDir, "CHANGELOG.md"), []byte(changelog), 0o600)
+			require.NoError(t, err)
+
+			errE = lint(&Config{ //nolint:exhaustruct
+				ChangeTo:   kong.ChangeDirFlag(tempDir),
+				LintFormat: format,
+				LintOutput: "report.json",
+			})
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			data, err = os.ReadFile(filepath.Join(tempDir, "report.json"))
+			require.NoError(t, err)
+			findings, errE := lintReport(format, []lintFinding{})
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, string(findings), string(data))
+		})
+	}
+}
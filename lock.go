@@ -0,0 +1,113 @@
+package release
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+	"gitlab.com/tozd/go/errors"
+)
+
+// lockVariable is the key of the GitLab project CI/CD variable used as an advisory lock.
+// Its value describes the run holding the lock.
+const lockVariable = "GITLAB_RELEASE_LOCK"
+
+// lockHolder describes this run, to be stored as the value of the lock.
+func lockHolder() string {
+	holder := os.Getenv("CI_JOB_URL")
+	if holder == "" {
+		hostname, _ := os.Hostname()
+		holder = fmt.Sprintf("%s (pid %d)", hostname, os.Getpid())
+	}
+	return fmt.Sprintf("%s at %s", holder, time.Now().UTC().Format(time.RFC3339))
+}
+
+// lockAcquiredAt returns the time at which the lock with value has been acquired,
+// as recorded by lockHolder.
+func lockAcquiredAt(value string) (time.Time, bool) {
+	i := strings.LastIndex(value, " at ")
+	if i < 0 {
+		return time.Time{}, false
+	}
+	acquiredAt, err := time.Parse(time.RFC3339, value[i+len(" at "):])
+	if err != nil {
+		return time.Time{}, false
+	}
+	return acquiredAt, true
+}
+
+// acquireLock acquires the advisory lock for the GitLab project by creating
+// the lock variable. It fails if the variable already exists, i.e., if another
+// run holds the lock, unless the lock has been acquired more than timeout ago
+// (e.g., by a run which has been killed), in which case the lock is taken over.
+// Zero timeout disables taking over locks.
+//
+// It returns the value of the lock variable, which has to be passed to releaseLock.
+func acquireLock(client *gitlab.Client, projectID string, timeout time.Duration, warnings *warnings) (string, errors.E) {
+	holder := lockHolder()
+	_, response, err := client.ProjectVariables.CreateVariable(projectID, &gitlab.CreateProjectVariableOptions{ //nolint:exhaustruct
+		Key:       gitlab.String(lockVariable),
+		Value:     gitlab.String(holder),
+		Protected: gitlab.Bool(false),
+		Masked:    gitlab.Bool(false),
+		Raw:       gitlab.Bool(true),
+	})
+	if err == nil {
+		return holder, nil
+	}
+	// GitLab responds with 400 Bad Request if the variable already exists.
+	if response != nil && response.StatusCode == http.StatusBadRequest {
+		variable, _, err2 := client.ProjectVariables.GetVariable(projectID, lockVariable, nil)
+		if err2 == nil {
+			acquiredAt, ok := lockAcquiredAt(variable.Value)
+			if timeout > 0 && ok && time.Since(acquiredAt) > timeout {
+				warnings.Warnf("taking over the stale lock held by %s.", variable.Value)
+				// The stale lock is deleted only if no other run has taken it over in the meantime.
+				errE := releaseLock(client, projectID, variable.Value)
+				if errE != nil {
+					return "", errE
+				}
+				// If another run takes over the lock in the meantime, we fail.
+				return acquireLock(client, projectID, 0, warnings)
+			}
+			errE := errors.New("another run holds the lock for the GitLab project")
+			errors.Details(errE)["variable"] = lockVariable
+			errors.Details(errE)["holder"] = variable.Value
+			return "", errE
+		}
+	}
+	errE := errors.WithMessage(err, "failed to acquire the lock for the GitLab project")
+	errors.Details(errE)["variable"] = lockVariable
+	return "", errE
+}
+
+// releaseLock releases the advisory lock for the GitLab project by deleting the lock variable,
+// but only if its value still equals holder. Otherwise another run holds the lock (e.g., because
+// it took over the lock from this run as stale) and releaseLock fails without deleting it.
+//
+// GitLab API cannot delete the variable conditionally, so the value is checked just before
+// deleting the variable.
+func releaseLock(client *gitlab.Client, projectID, holder string) errors.E {
+	variable, _, err := client.ProjectVariables.GetVariable(projectID, lockVariable, nil)
+	if err != nil {
+		errE := errors.WithMessage(err, "failed to release the lock for the GitLab project")
+		errors.Details(errE)["variable"] = lockVariable
+		return errE
+	}
+	if variable.Value != holder {
+		errE := errors.New("another run holds the lock for the GitLab project")
+		errors.Details(errE)["variable"] = lockVariable
+		errors.Details(errE)["holder"] = variable.Value
+		return errE
+	}
+	_, err = client.ProjectVariables.RemoveVariable(projectID, lockVariable, nil)
+	if err != nil {
+		errE := errors.WithMessage(err, "failed to release the lock for the GitLab project")
+		errors.Details(errE)["variable"] = lockVariable
+		return errE
+	}
+	return nil
+}
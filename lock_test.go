@@ -0,0 +1,123 @@
+package release
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
+)
+
+func TestLock(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	holder := ""
+	server, requests := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodPost && r.URL.Path == "/api/v4/projects/1/variables":
+			if holder != "" {
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte(`{"message": {"key": ["(GITLAB_RELEASE_LOCK) has already been taken"]}}`))
+				return
+			}
+			var options struct {
+				Value string `json:"value"`
+			}
+			err := json.NewDecoder(r.Body).Decode(&options)
+			assert.NoError(t, err)
+			holder = options.Value
+			w.WriteHeader(http.StatusCreated)
+			_, _ = w.Write([]byte(`{"key": "GITLAB_RELEASE_LOCK"}`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/1/variables/GITLAB_RELEASE_LOCK":
+			if holder == "" {
+				w.WriteHeader(http.StatusNotFound)
+				_, _ = w.Write([]byte(`{"message": "404 Variable Not Found"}`))
+				return
+			}
+			data, err := json.Marshal(map[string]string{"key": lockVariable, "value": holder})
+			assert.NoError(t, err)
+			_, _ = w.Write(data)
+		case r.Method == http.MethodDelete && r.URL.Path == "/api/v4/projects/1/variables/GITLAB_RELEASE_LOCK":
+			holder = ""
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	client, errE := newClient(&Config{BaseURL: server.URL}, nil) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	warnings := newWarnings(io.Discard)
+
+	acquired, errE := acquireLock(client, "1", time.Hour, warnings)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	mu.Lock()
+	current := holder
+	mu.Unlock()
+	assert.Equal(t, current, acquired)
+
+	_, errE = acquireLock(client, "1", time.Hour, warnings)
+	assert.EqualError(t, errE, "another run holds the lock for the GitLab project")
+	assert.Equal(t, current, errors.AllDetails(errE)["holder"])
+
+	errE = releaseLock(client, "1", acquired)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	acquired, errE = acquireLock(client, "1", time.Hour, warnings)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	// The lock changed hands (e.g., it has been taken over as stale) before it is released.
+	mu.Lock()
+	holder = "other run at 2023-01-01T00:00:00Z"
+	mu.Unlock()
+	count := len(requests())
+	errE = releaseLock(client, "1", acquired)
+	assert.EqualError(t, errE, "another run holds the lock for the GitLab project")
+	assert.Equal(t, "other run at 2023-01-01T00:00:00Z", errors.AllDetails(errE)["holder"])
+	// The lock of the other run is not deleted.
+	assert.Equal(t, []string{"GET /api/v4/projects/1/variables/GITLAB_RELEASE_LOCK"}, requests()[count:])
+
+	// A stale lock is taken over.
+	count = len(requests())
+	acquired, errE = acquireLock(client, "1", time.Hour, warnings)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []string{
+		"POST /api/v4/projects/1/variables",
+		"GET /api/v4/projects/1/variables/GITLAB_RELEASE_LOCK",
+		"GET /api/v4/projects/1/variables/GITLAB_RELEASE_LOCK",
+		"DELETE /api/v4/projects/1/variables/GITLAB_RELEASE_LOCK",
+		"POST /api/v4/projects/1/variables",
+	}, requests()[count:])
+	assert.Equal(t, []string{"taking over the stale lock held by other run at 2023-01-01T00:00:00Z."}, warnings.Messages())
+	mu.Lock()
+	assert.Equal(t, acquired, holder)
+	mu.Unlock()
+
+	// But not when taking over is disabled.
+	mu.Lock()
+	holder = "other run at 2023-01-01T00:00:00Z"
+	mu.Unlock()
+	_, errE = acquireLock(client, "1", 0, warnings)
+	assert.EqualError(t, errE, "another run holds the lock for the GitLab project")
+}
+
+func TestLockAcquiredAt(t *testing.T) {
+	t.Parallel()
+
+	acquiredAt, ok := lockAcquiredAt("https://gitlab.com/group/project/-/jobs/1 at 2023-06-01T12:00:00Z")
+	assert.True(t, ok)
+	assert.Equal(t, time.Date(2023, 6, 1, 12, 0, 0, 0, time.UTC), acquiredAt)
+
+	_, ok = lockAcquiredAt("other run")
+	assert.False(t, ok)
+}
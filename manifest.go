@@ -0,0 +1,88 @@
+package release
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+	"gitlab.com/tozd/go/errors"
+)
+
+// manifest describes releases synced by a run, for downstream consumption.
+type manifest struct {
+	Releases []manifestRelease `json:"releases"`
+}
+
+// manifestRelease describes a synced release.
+type manifestRelease struct {
+	Tag               string         `json:"tag"`
+	Name              string         `json:"name"`
+	ReleasedAt        *time.Time     `json:"releasedAt"`
+	DescriptionLength int            `json:"descriptionLength"`
+	Links             []manifestLink `json:"links"`
+	Milestones        []string       `json:"milestones"`
+	Images            []string       `json:"images"`
+}
+
+// manifestLink describes a link of a synced release.
+type manifestLink struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// newManifestRelease describes what Upsert syncs for the release.
+func newManifestRelease(
+	config *Config, release Release, releasedAt *time.Time,
+	milestones []string, packages []Package, images []string,
+) (manifestRelease, errors.E) {
+	name, errE := releaseName(config, release)
+	if errE != nil {
+		return manifestRelease{}, errE //nolint:exhaustruct
+	}
+	description, errE := releaseDescription(config, release, images)
+	if errE != nil {
+		return manifestRelease{}, errE //nolint:exhaustruct
+	}
+	expectedLinks, errE := getExpectedLinks(config, releasePackages(config, release, packages), releaseAssets(config, release))
+	if errE != nil {
+		return manifestRelease{}, errE //nolint:exhaustruct
+	}
+	links := make([]manifestLink, 0, len(expectedLinks))
+	for _, l := range expectedLinks {
+		options := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, l.Name, l)
+		links = append(links, manifestLink{
+			Name: l.Name,
+			URL:  *options.URL,
+		})
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Name < links[j].Name
+	})
+
+	return manifestRelease{
+		Tag:               releaseGitTag(release),
+		Name:              name,
+		ReleasedAt:        releasedAt,
+		DescriptionLength: len(description),
+		Links:             links,
+		Milestones:        append([]string{}, milestones...),
+		Images:            append([]string{}, images...),
+	}, nil
+}
+
+// write writes the manifest to the file at path.
+func (m *manifest) write(path string) errors.E {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	data = append(data, '\n')
+	err = writeFileAtomically(path, data)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot write manifest")
+		errors.Details(errE)["path"] = path
+		return errE
+	}
+	return nil
+}
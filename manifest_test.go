@@ -0,0 +1,65 @@
+package release
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestManifest(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{ //nolint:exhaustruct
+		BaseURL: "https://gitlab.com",
+		Project: "group/project",
+	}
+	releasedAt := time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)
+	release := Release{ //nolint:exhaustruct
+		Tag:     "v1.0.0",
+		Title:   "[1.0.0] - 2023-01-01",
+		Changes: "- Feature.",
+	}
+	packages := []Package{
+		{ //nolint:exhaustruct
+			ID:      1,
+			Generic: true,
+			WebPath: "/group/project/-/packages/1",
+			Name:    "foo",
+			Version: "1.0.0",
+			Files:   []string{"foo.zip"},
+		},
+	}
+
+	r, errE := newManifestRelease(config, release, &releasedAt, []string{"1.0.0"}, packages, []string{"registry.gitlab.com/group/project:v1.0.0"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	description, errE := releaseDescription(config, release, []string{"registry.gitlab.com/group/project:v1.0.0"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "v1.0.0", r.Tag)
+	assert.Equal(t, "v1.0.0", r.Name)
+	assert.Equal(t, &releasedAt, r.ReleasedAt)
+	assert.Equal(t, len(description), r.DescriptionLength)
+	assert.Equal(t, []string{"1.0.0"}, r.Milestones)
+	assert.Equal(t, []string{"registry.gitlab.com/group/project:v1.0.0"}, r.Images)
+	assert.Equal(t, []manifestLink{
+		{Name: "foo/foo.zip", URL: "https://gitlab.com/api/v4/projects/group%2Fproject/packages/generic/foo/1%2E0%2E0/foo%2Ezip"},
+	}, r.Links)
+
+	path := filepath.Join(t.TempDir(), "manifest.json")
+	m := &manifest{Releases: []manifestRelease{r}}
+	errE = m.write(path)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var read manifest
+	err = json.Unmarshal(data, &read)
+	require.NoError(t, err)
+	assert.Equal(t, m.Releases[0].Tag, read.Releases[0].Tag)
+	assert.Equal(t, m.Releases[0].Links, read.Releases[0].Links)
+	assert.True(t, m.Releases[0].ReleasedAt.Equal(*read.Releases[0].ReleasedAt))
+}
@@ -0,0 +1,301 @@
+package release
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+var (
+	// Matches "[text]", "[text][]", and "[text][label]".
+	markdownReferenceLinkRegex = regexp.MustCompile(`\[([^\[\]]+)\](?:\[([^\[\]]*)\])?`)
+	// Matches opening and closing HTML tags, but not autolinks (e.g., "<https://example.com>").
+	markdownHTMLTagRegex = regexp.MustCompile(`</?[A-Za-z][A-Za-z0-9-]*(?:\s[^<>]*)?/?>`)
+	markdownFenceRegex   = regexp.MustCompile("^\\s{0,3}(```|~~~)")
+	// Matches inline links and images (e.g., "[text](url)" and "![alt](url)").
+	markdownInlineLinkRegex = regexp.MustCompile(`!?\[[^\[\]]*\]\([^()]*\)`)
+	// Matches ATX headings (e.g., "### Added").
+	markdownHeadingRegex = regexp.MustCompile(`^\s{0,3}(#{1,6})\s+(.*?)(?:\s+#+)?\s*$`)
+	// Matches inline links, capturing text and URL. It matches images as well,
+	// use markdownLinks to get only links.
+	markdownLinkRegex = regexp.MustCompile(`\[([^\[\]]+)\]\(\s*([^()\s]+)\s*\)`)
+	// Matches GitLab issue (e.g., "#123") and merge request (e.g., "!456") references
+	// which are not part of a word, an URL, an HTML entity, or a link text.
+	markdownGitLabReferenceRegex = regexp.MustCompile(`(^|[^\w&/#!\[\]])([#!])(\d+)\b`)
+	// Matches autolinks (e.g., "<https://example.com>").
+	markdownAutolinkRegex = regexp.MustCompile(`<[A-Za-z][A-Za-z0-9+.-]*:[^<>\s]*>`)
+)
+
+// markdownLinks returns text and URL of all inline links in s, but not images.
+func markdownLinks(s string) [][2]string {
+	links := [][2]string{}
+	for _, match := range markdownLinkRegex.FindAllStringSubmatchIndex(s, -1) {
+		// We check for the "!" of images before the match instead of matching
+		// it, so that adjacent links do not consume each other.
+		if match[0] > 0 && s[match[0]-1] == '!' {
+			continue
+		}
+		links = append(links, [2]string{s[match[2]:match[3]], s[match[4]:match[5]]})
+	}
+	return links
+}
+
+// mapMarkdownText calls f on all parts of Markdown s which are not inside
+// fenced code blocks or inline code spans, replacing them with the result.
+func mapMarkdownText(s string, f func(string) string) string {
+	lines := strings.Split(s, "\n")
+	fence := ""
+	for i, line := range lines {
+		match := markdownFenceRegex.FindStringSubmatch(line)
+		if fence != "" {
+			if match != nil && match[1] == fence {
+				fence = ""
+			}
+			continue
+		}
+		if match != nil {
+			fence = match[1]
+			continue
+		}
+
+		// Parts at even indices are outside of inline code spans.
+		parts := strings.Split(line, "`")
+		for j := 0; j < len(parts); j += 2 {
+			parts[j] = f(parts[j])
+		}
+		lines[i] = strings.Join(parts, "`")
+	}
+	return strings.Join(lines, "\n")
+}
+
+// resolveReferenceLinks converts reference-style links in s to inline links using
+// references, a map between lower-cased labels and URLs. Links with unknown labels
+// are left as they are.
+func resolveReferenceLinks(s string, references map[string]string) string {
+	var result strings.Builder
+	last := 0
+	for _, match := range markdownReferenceLinkRegex.FindAllStringSubmatchIndex(s, -1) {
+		text := s[match[2]:match[3]]
+		label := text
+		if match[4] >= 0 {
+			if match[5] > match[4] {
+				label = s[match[4]:match[5]]
+			}
+		} else if match[1] < len(s) && (s[match[1]] == '(' || s[match[1]] == ':') {
+			// Inline link or a link reference definition.
+			continue
+		}
+		url, ok := references[strings.ToLower(label)]
+		if !ok {
+			continue
+		}
+		result.WriteString(s[last:match[0]])
+		result.WriteString("[" + text + "](" + url + ")")
+		last = match[1]
+	}
+	result.WriteString(s[last:])
+	return result.String()
+}
+
+// escapeHTMLTags escapes raw HTML tags in s so that they are rendered as text.
+func escapeHTMLTags(s string) string {
+	return markdownHTMLTagRegex.ReplaceAllStringFunc(s, func(tag string) string {
+		return strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(tag)
+	})
+}
+
+// normalizeMarkdown resolves reference-style links in Markdown s to inline links
+// and escapes raw HTML tags, leaving code blocks and code spans as they are.
+func normalizeMarkdown(s string, references map[string]string) string {
+	return mapMarkdownText(s, func(text string) string {
+		return escapeHTMLTags(resolveReferenceLinks(text, references))
+	})
+}
+
+// truncateMarkdown truncates Markdown s so that, together with suffix appended,
+// it is at most maxLength characters (runes) long. If s is already short enough,
+// it is returned as it is, without suffix.
+//
+// It truncates at the last line break (or, if there is none, at the last space)
+// and never in the middle of a link. If truncation happens inside a fenced code
+// block, the block is closed.
+func truncateMarkdown(s string, maxLength int, suffix string) string {
+	if utf8.RuneCountInString(s) <= maxLength {
+		return s
+	}
+
+	// We reserve space to close a fenced code block, if needed.
+	runes := maxLength - utf8.RuneCountInString(suffix) - len("\n```")
+	if runes <= 0 {
+		return ""
+	}
+	// We convert the budget in runes to a byte offset into s.
+	budget := 0
+	for ; runes > 0; runes-- {
+		_, size := utf8.DecodeRuneInString(s[budget:])
+		budget += size
+	}
+
+	end := strings.LastIndexByte(s[:budget], '\n')
+	if end <= 0 {
+		end = strings.LastIndexByte(s[:budget], ' ')
+	}
+	if end <= 0 {
+		end = budget
+	}
+
+	for _, match := range markdownInlineLinkRegex.FindAllStringIndex(s, -1) {
+		if match[0] < end && end < match[1] {
+			end = match[0]
+			break
+		}
+	}
+	for _, match := range markdownReferenceLinkRegex.FindAllStringIndex(s, -1) {
+		if match[0] < end && end < match[1] {
+			end = match[0]
+			break
+		}
+	}
+
+	truncated := strings.TrimRight(s[:end], " \n")
+
+	fence := ""
+	for _, line := range strings.Split(truncated, "\n") {
+		match := markdownFenceRegex.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		if fence == "" {
+			fence = match[1]
+		} else if match[1] == fence {
+			fence = ""
+		}
+	}
+	if fence != "" {
+		truncated += "\n" + fence
+	}
+
+	return truncated + suffix
+}
+
+// extractMarkdownSection removes the section with heading title (compared
+// case-insensitively) from Markdown s. The section ends at the next heading
+// of the same or higher level. It returns s without the section and the
+// lines of the section (without the heading), if the section has been found.
+func extractMarkdownSection(s, title string) (string, []string, bool) {
+	lines := strings.Split(s, "\n")
+
+	// Lines inside fenced code blocks are not headings.
+	fenced := make([]bool, len(lines))
+	fence := ""
+	for i, line := range lines {
+		match := markdownFenceRegex.FindStringSubmatch(line)
+		if fence != "" {
+			if match != nil && match[1] == fence {
+				fence = ""
+			}
+			fenced[i] = true
+			continue
+		}
+		if match != nil {
+			fence = match[1]
+			fenced[i] = true
+		}
+	}
+
+	for i, line := range lines {
+		if fenced[i] {
+			continue
+		}
+		match := markdownHeadingRegex.FindStringSubmatch(line)
+		if match == nil || !strings.EqualFold(match[2], title) {
+			continue
+		}
+		level := len(match[1])
+		end := len(lines)
+		for j := i + 1; j < len(lines); j++ {
+			if fenced[j] {
+				continue
+			}
+			m := markdownHeadingRegex.FindStringSubmatch(lines[j])
+			if m != nil && len(m[1]) <= level {
+				end = j
+				break
+			}
+		}
+		section := lines[i+1 : end]
+		rest := append(append([]string{}, lines[:i]...), lines[end:]...)
+		return strings.Join(rest, "\n"), section, true
+	}
+	return s, nil, false
+}
+
+// trimBlankLines removes leading blank lines and trailing whitespace from Markdown s.
+// If collapse is true, it also collapses runs of blank lines outside of fenced
+// code blocks into one blank line.
+func trimBlankLines(s string, collapse bool) string {
+	lines := strings.Split(strings.TrimRightFunc(s, unicode.IsSpace), "\n")
+	result := make([]string, 0, len(lines))
+	fence := ""
+	blank := true
+	for _, line := range lines {
+		match := markdownFenceRegex.FindStringSubmatch(line)
+		if fence != "" {
+			if match != nil && match[1] == fence {
+				fence = ""
+			}
+			result = append(result, line)
+			continue
+		}
+		if match != nil {
+			fence = match[1]
+		}
+		isBlank := strings.TrimSpace(line) == ""
+		if isBlank && blank && (collapse || len(result) == 0) {
+			continue
+		}
+		blank = isBlank
+		result = append(result, line)
+	}
+	return strings.Join(result, "\n")
+}
+
+// linkifyReferences converts GitLab issue (e.g., "#123") and merge request (e.g., "!456")
+// references in Markdown s into links to the project at projectURL. References inside
+// code blocks, code spans, and existing links are left as they are.
+func linkifyReferences(s, projectURL string) string {
+	projectURL = strings.TrimSuffix(projectURL, "/")
+	return mapMarkdownText(s, func(text string) string {
+		var result strings.Builder
+		last := 0
+		links := markdownInlineLinkRegex.FindAllStringIndex(text, -1)
+		links = append(links, markdownAutolinkRegex.FindAllStringIndex(text, -1)...)
+		sort.Slice(links, func(i, j int) bool {
+			return links[i][0] < links[j][0]
+		})
+		for _, link := range links {
+			if link[0] < last {
+				continue
+			}
+			result.WriteString(linkifyText(text[last:link[0]], projectURL))
+			result.WriteString(text[link[0]:link[1]])
+			last = link[1]
+		}
+		result.WriteString(linkifyText(text[last:], projectURL))
+		return result.String()
+	})
+}
+
+// linkifyText converts GitLab references in s, which does not contain any links, into links.
+func linkifyText(s, projectURL string) string {
+	return markdownGitLabReferenceRegex.ReplaceAllStringFunc(s, func(match string) string {
+		m := markdownGitLabReferenceRegex.FindStringSubmatch(match)
+		path := "issues"
+		if m[2] == "!" {
+			path = "merge_requests"
+		}
+		return m[1] + "[" + m[2] + m[3] + "](" + projectURL + "/-/" + path + "/" + m[3] + ")"
+	})
+}
@@ -0,0 +1,172 @@
+package release
+
+import (
+	"fmt"
+	"testing"
+	"unicode/utf8"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeMarkdown(t *testing.T) {
+	t.Parallel()
+
+	references := map[string]string{
+		"1.0.0": "https://example.com/compare/v0.1.0...v1.0.0",
+		"#123":  "https://example.com/issues/123",
+		"docs":  "https://example.com/docs",
+	}
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"- Plain text.", "- Plain text."},
+		{"- Fixed [#123].", "- Fixed [#123](https://example.com/issues/123)."},
+		{"- See [documentation][docs].", "- See [documentation](https://example.com/docs)."},
+		{"- See [docs][].", "- See [docs](https://example.com/docs)."},
+		{"- See [Docs].", "- See [Docs](https://example.com/docs)."},
+		{"- See [unknown].", "- See [unknown]."},
+		{"- See [docs](https://other.example.com).", "- See [docs](https://other.example.com)."},
+		{"- Image ![docs].", "- Image ![docs](https://example.com/docs)."},
+		{"- Use <br> and <b>bold</b>.", "- Use &lt;br&gt; and &lt;b&gt;bold&lt;/b&gt;."},
+		{"- Autolink <https://example.com>.", "- Autolink <https://example.com>."},
+		{"- Code `[docs] <b>`.", "- Code `[docs] <b>`."},
+		{"```\n[docs] <b>\n```\n[docs]", "```\n[docs] <b>\n```\n[docs](https://example.com/docs)"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, normalizeMarkdown(tt.input, references))
+		})
+	}
+}
+
+func TestLinkifyReferences(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"- Fixed #123.", "- Fixed [#123](https://gitlab.com/group/project/-/issues/123)."},
+		{"- Merged !456 (closes #1, #2).", "- Merged [!456](https://gitlab.com/group/project/-/merge_requests/456) " +
+			"(closes [#1](https://gitlab.com/group/project/-/issues/1), [#2](https://gitlab.com/group/project/-/issues/2))."},
+		{"#7 at the start.", "[#7](https://gitlab.com/group/project/-/issues/7) at the start."},
+		{"- Code `#123` and\n```\n#123\n```\n#123", "- Code `#123` and\n```\n#123\n```\n[#123](https://gitlab.com/group/project/-/issues/123)"},
+		{"- Already [#123](https://example.com/123) and [fix #1](https://example.com/1).", "- Already [#123](https://example.com/123) and [fix #1](https://example.com/1)."},
+		{"- URL https://example.com/page#123 and <https://example.com/#1>.", "- URL https://example.com/page#123 and <https://example.com/#1>."},
+		{"- Entity &#123; and word abc#123 and #123abc.", "- Entity &#123; and word abc#123 and #123abc."},
+		{"- Reference [#123].", "- Reference [#123]."},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, linkifyReferences(tt.input, "https://gitlab.com/group/project/"))
+		})
+	}
+}
+
+func TestTruncateMarkdown(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input     string
+		maxLength int
+		want      string
+	}{
+		{"- One.", 100, "- One."},
+		{"- One.\n- Two.\n- Three.", 18, "- One.\n…"},
+		{"- One.\n- Two.\n- Three.", 22, "- One.\n- Two.\n- Three."},
+		{"See [the documentation](https://example.com/docs) now.", 40, "See\n…"},
+		{"- One.\n- See [the documentation](https://example.com/docs).", 50, "- One.\n…"},
+		{"- See [the\ndocumentation](https://example.com/docs).", 30, "- See\n…"},
+		{"- See [the documentation][docs] now.", 30, "- See\n…"},
+		{"Text.\n```\nfirst line\nsecond line\n```", 35, "Text.\n```\nfirst line\n```\n…"},
+		// Lengths are counted in characters, not bytes.
+		{"éééééééééé", 8, "éé\n…"},
+		{"ééééé", 5, "ééééé"},
+		{"- One.\n- Two.", 5, ""},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			got := truncateMarkdown(tt.input, tt.maxLength, "\n…")
+			assert.Equal(t, tt.want, got)
+			assert.LessOrEqual(t, utf8.RuneCountInString(got), tt.maxLength)
+		})
+	}
+}
+
+func TestExtractMarkdownSection(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input   string
+		rest    string
+		section []string
+		found   bool
+	}{
+		{"### Added\n- One.", "### Added\n- One.", nil, false},
+		{"### Added\n- One.\n### Assets\n- [a](https://example.com/a)", "### Added\n- One.", []string{"- [a](https://example.com/a)"}, true},
+		{"### assets\n- [a](https://example.com/a)\n### Fixed\n- Two.", "### Fixed\n- Two.", []string{"- [a](https://example.com/a)"}, true},
+		{"### Assets ###\n- [a](https://example.com/a)\n#### Extra\n- [b](https://example.com/b)\n## Other", "## Other", []string{"- [a](https://example.com/a)", "#### Extra", "- [b](https://example.com/b)"}, true},
+		// Headings inside fenced code blocks do not end the section.
+		{"### Assets\n- [a](https://example.com/a)\n```\n## Usage\n```\n### Fixed\n- Two.", "### Fixed\n- Two.", []string{"- [a](https://example.com/a)", "```", "## Usage", "```"}, true},
+		// Headings inside fenced code blocks are not sections.
+		{"### Added\n~~~\n### Assets\n~~~", "### Added\n~~~\n### Assets\n~~~", nil, false},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			rest, section, found := extractMarkdownSection(tt.input, "Assets")
+			assert.Equal(t, tt.rest, rest)
+			assert.Equal(t, tt.section, section)
+			assert.Equal(t, tt.found, found)
+		})
+	}
+}
+
+func TestTrimBlankLines(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		input    string
+		collapse bool
+		want     string
+	}{
+		{"- One.\n- Two.\n", true, "- One.\n- Two."},
+		{"\n  \n- One.\n\n\n\n- Two.\n \n\n", true, "- One.\n\n- Two."},
+		{"\n  \n- One.\n\n\n\n- Two.\n \n\n", false, "- One.\n\n\n\n- Two."},
+		{"    code\n\n\n- One.", true, "    code\n\n- One."},
+		{"Text.\n```\nfirst\n\n\n\nsecond\n```\n\n\nText.", true, "Text.\n```\nfirst\n\n\n\nsecond\n```\n\nText."},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.want, trimBlankLines(tt.input, tt.collapse))
+		})
+	}
+}
@@ -0,0 +1,97 @@
+package release
+
+import (
+	"os"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// mirror describes an additional GitLab instance to which releases are synced.
+type mirror struct {
+	// BaseURL is the base URL of the GitLab instance.
+	BaseURL string
+
+	// Project is GitLab project ID or path on the mirror. If empty, the project
+	// is determined as for the primary GitLab instance.
+	Project string
+
+	// TokenEnv is the name of the environment variable with GitLab API token
+	// for the mirror. If empty, the token is determined as for the primary GitLab instance.
+	TokenEnv string
+}
+
+// parseMirror parses a mirror in "base=URL[,project=PROJECT][,token-env=VAR]" format.
+func parseMirror(s string) (mirror, errors.E) {
+	m := mirror{} //nolint:exhaustruct
+	for _, field := range strings.Split(s, ",") {
+		key, value, ok := strings.Cut(strings.TrimSpace(field), "=")
+		if !ok {
+			errE := errors.New(`mirror should be in "base=URL[,project=PROJECT][,token-env=VAR]" format`)
+			errors.Details(errE)["mirror"] = s
+			return mirror{}, errE //nolint:exhaustruct
+		}
+		switch key {
+		case "base":
+			m.BaseURL = value
+		case "project":
+			m.Project = value
+		case "token-env":
+			m.TokenEnv = value
+		default:
+			errE := errors.New("unknown mirror field")
+			errors.Details(errE)["mirror"] = s
+			errors.Details(errE)["field"] = key
+			return mirror{}, errE //nolint:exhaustruct
+		}
+	}
+	if m.BaseURL == "" {
+		errE := errors.New("mirror is missing base URL")
+		errors.Details(errE)["mirror"] = s
+		return mirror{}, errE //nolint:exhaustruct
+	}
+	return m, nil
+}
+
+// parseMirrors parses all mirrors.
+func parseMirrors(mirrors []string) ([]mirror, errors.E) {
+	result := make([]mirror, 0, len(mirrors))
+	for _, s := range mirrors {
+		m, errE := parseMirror(s)
+		if errE != nil {
+			return nil, errE
+		}
+		result = append(result, m)
+	}
+	return result, nil
+}
+
+// config returns configuration to sync to the mirror, based on config for the primary
+// GitLab instance. The state file is not used for mirrors because it records releases
+// synced to the primary GitLab instance. Similarly, the manifest and the GitHub export
+// describe only the primary sync.
+func (m mirror) config(config *Config) (*Config, errors.E) {
+	c := *config
+	c.BaseURL = m.BaseURL
+	c.DownloadBaseURL = ""
+	c.Mirrors = nil
+	c.StateFile = ""
+	c.Manifest = ""
+	c.ExportGitHub = ""
+	if m.Project != "" {
+		c.Project = m.Project
+	}
+	if m.TokenEnv != "" {
+		token := os.Getenv(m.TokenEnv)
+		if token == "" {
+			errE := errors.New("environment variable with GitLab API token for the mirror is not set")
+			errors.Details(errE)["mirror"] = m.BaseURL
+			errors.Details(errE)["env"] = m.TokenEnv
+			return nil, errE
+		}
+		c.Token = token
+		// The read token is for the primary GitLab instance, so the mirror's token is used for all requests.
+		c.ReadToken = ""
+	}
+	return &c, nil
+}
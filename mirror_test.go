@@ -0,0 +1,78 @@
+package release
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseMirror(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		mirror   string
+		expected mirror
+		err      string
+	}{
+		{"base=https://gitlab.example.com", mirror{BaseURL: "https://gitlab.example.com"}, ""},
+		{
+			"base=https://gitlab.example.com, project=group/project, token-env=MIRROR_TOKEN",
+			mirror{BaseURL: "https://gitlab.example.com", Project: "group/project", TokenEnv: "MIRROR_TOKEN"},
+			"",
+		},
+		{"https://gitlab.example.com", mirror{}, `mirror should be in "base=URL[,project=PROJECT][,token-env=VAR]" format`},
+		{"project=group/project", mirror{}, "mirror is missing base URL"},
+		{"base=https://gitlab.example.com,token=secret", mirror{}, "unknown mirror field"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			m, errE := parseMirror(tt.mirror)
+			if tt.err != "" {
+				assert.EqualError(t, errE, tt.err)
+			} else {
+				require.NoError(t, errE, "% -+#.1v", errE)
+				assert.Equal(t, tt.expected, m)
+			}
+		})
+	}
+}
+
+//nolint:paralleltest
+func TestMirrorConfig(t *testing.T) {
+	t.Setenv("GITLAB_RELEASE_TEST_MIRROR_TOKEN", "mirror-secret")
+
+	config := &Config{ //nolint:exhaustruct
+		BaseURL:   "https://gitlab.com",
+		Project:   "group/project",
+		Token:     "secret",
+		StateFile: "state.json",
+		Manifest:  "manifest.json",
+		Mirrors:   []string{"base=https://gitlab.example.com"},
+	}
+
+	c, errE := mirror{BaseURL: "https://gitlab.example.com"}.config(config) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "https://gitlab.example.com", c.BaseURL)
+	assert.Equal(t, "group/project", c.Project)
+	assert.Equal(t, "secret", c.Token)
+	assert.Empty(t, c.StateFile)
+	assert.Empty(t, c.Manifest)
+	assert.Empty(t, c.Mirrors)
+	// The original configuration is not changed.
+	assert.Equal(t, "https://gitlab.com", config.BaseURL)
+
+	c, errE = mirror{BaseURL: "https://gitlab.example.com", Project: "mirror/project", TokenEnv: "GITLAB_RELEASE_TEST_MIRROR_TOKEN"}.config(config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "mirror/project", c.Project)
+	assert.Equal(t, "mirror-secret", c.Token)
+
+	_, errE = mirror{BaseURL: "https://gitlab.example.com", TokenEnv: "GITLAB_RELEASE_TEST_MISSING_TOKEN"}.config(config) //nolint:exhaustruct
+	assert.EqualError(t, errE, "environment variable with GitLab API token for the mirror is not set")
+}
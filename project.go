@@ -0,0 +1,147 @@
+package release
+
+import (
+	"net/url"
+	"os"
+	"regexp"
+	"slices"
+	"strings"
+
+	"github.com/go-git/go-git/v5"
+	giturls "github.com/whilp/git-urls"
+	"gitlab.com/tozd/go/errors"
+)
+
+// projectPathRegex matches <namespace/project_path> where namespace can have subgroups.
+// See: https://docs.gitlab.com/ee/user/reserved_names.html#limitations-on-usernames-project-and-group-names
+var projectPathRegex = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.\-]*(?:/[A-Za-z0-9_][A-Za-z0-9_.\-]*)+$`)
+
+// validateProject returns an error if project is neither a numeric project ID
+// nor a <namespace/project_path> project path.
+func validateProject(project string) errors.E {
+	if project != "" && strings.Trim(project, "0123456789") == "" && strings.TrimLeft(project, "0") != "" {
+		return nil
+	}
+	if projectPathRegex.MatchString(project) && !strings.Contains(project, "..") {
+		return nil
+	}
+	errE := errors.New("GitLab project should be a numeric project ID or <namespace/project_path>")
+	errors.Details(errE)["project"] = project
+	return errE
+}
+
+// resolveProject returns the GitLab project to use. Explicitly configured project
+// is used first, then numeric project ID from CI_PROJECT_ID environment variable
+// (e.g., when config is not populated by Kong), and only then the project is
+// inferred from git remotes of the git repository at path.
+func resolveProject(project, path, baseURL string, warnings *warnings) (string, errors.E) {
+	if project == "" {
+		project = os.Getenv("CI_PROJECT_ID")
+	}
+	if project == "" {
+		inferred, errE := inferProjectID(path, baseURL, warnings)
+		if errE != nil {
+			return "", errE
+		}
+		project = inferred
+	}
+	errE := validateProject(project)
+	if errE != nil {
+		return "", errE
+	}
+	return project, nil
+}
+
+// normalizeURL parses rawURL and returns its lower-cased host name (without port)
+// and path without the trailing slash. Scheme is ignored, so "http" and "https"
+// (and "ssh" for git remotes) URLs for the same host match.
+func normalizeURL(rawURL string) (string, string, errors.E) {
+	u, err := giturls.Parse(strings.TrimSpace(rawURL))
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot parse URL")
+		errors.Details(errE)["url"] = rawURL
+		return "", "", errE
+	}
+	return strings.ToLower(u.Hostname()), strings.TrimSuffix(u.Path, "/"), nil
+}
+
+// inferProjectID infers a GitLab project ID (i.e., <namespace/project_path>) from
+// a remote of a git repository at path whose host matches the host of baseURL.
+// The "origin" remote is tried first, then other remotes in alphabetical order.
+// If no remote matches, "origin" remote is used.
+//
+// If baseURL has a path (e.g., GitLab is served under a path prefix), the path
+// is removed from the project ID as well.
+func inferProjectID(path, baseURL string, warnings *warnings) (string, errors.E) {
+	repository, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: false,
+	})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot open git repository")
+		errors.Details(errE)["path"] = path
+		return "", errE
+	}
+
+	remotes, err := repository.Remotes()
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot obtain git remotes")
+		errors.Details(errE)["path"] = path
+		return "", errE
+	}
+	slices.SortStableFunc(remotes, func(a, b *git.Remote) int {
+		// "origin" remote is sorted first.
+		if a.Config().Name == "origin" {
+			return -1
+		} else if b.Config().Name == "origin" {
+			return 1
+		}
+		return strings.Compare(a.Config().Name, b.Config().Name)
+	})
+
+	baseHost, basePath, errE := normalizeURL(baseURL)
+	if errE != nil {
+		return "", errE
+	}
+
+	for _, remote := range remotes {
+		for _, remoteURL := range remote.Config().URLs {
+			host, remotePath, errE := normalizeURL(remoteURL)
+			if errE != nil {
+				continue
+			}
+			if host == baseHost {
+				return projectPath(remotePath, basePath), nil
+			}
+		}
+	}
+
+	for _, remote := range remotes {
+		if remote.Config().Name == "origin" && len(remote.Config().URLs) > 0 {
+			_, remotePath, errE := normalizeURL(remote.Config().URLs[0])
+			if errE != nil {
+				errors.Details(errE)["path"] = path
+				errors.Details(errE)["remote"] = "origin"
+				return "", errE
+			}
+			warnings.Warnf("no git remote matches GitLab base URL \"%s\", using \"origin\" remote.", baseURL)
+			return projectPath(remotePath, basePath), nil
+		}
+	}
+
+	errE = errors.New("cannot infer GitLab project from git remotes")
+	errors.Details(errE)["path"] = path
+	return "", errE
+}
+
+// projectPath returns the project ID from remote URL's path,
+// removing basePath prefix and ".git" suffix.
+func projectPath(remotePath, basePath string) string {
+	p := strings.TrimPrefix(remotePath, "/")
+	p = strings.TrimPrefix(p, strings.TrimPrefix(basePath+"/", "/"))
+	p = strings.TrimSuffix(p, ".git")
+	if unescaped, err := url.PathUnescape(p); err == nil {
+		p = unescaped
+	}
+	return p
+}
@@ -0,0 +1,139 @@
+package release
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInferProjectID(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		baseURL string
+		remotes map[string]string
+		want    string
+	}{
+		{"https://gitlab.com", map[string]string{"origin": "https://gitlab.com/tozd/gitlab/release.git"}, "tozd/gitlab/release"},
+		{"https://gitlab.com", map[string]string{"origin": "git@gitlab.com:tozd/gitlab/release.git"}, "tozd/gitlab/release"},
+		{"https://gitlab.com/", map[string]string{"origin": "ssh://git@gitlab.com:2222/tozd/gitlab/release.git"}, "tozd/gitlab/release"},
+		{"HTTPS://Gitlab.Example.com/", map[string]string{"origin": "https://gitlab.example.com/group/project.git"}, "group/project"},
+		{"http://gitlab.example.com", map[string]string{"origin": "https://GitLab.Example.com/group/project"}, "group/project"},
+		{
+			"https://gitlab.example.com",
+			map[string]string{"origin": "https://github.com/tozd/gitlab-release.git", "gitlab": "git@gitlab.example.com:group/project.git"},
+			"group/project",
+		},
+		{
+			"https://gitlab.example.com",
+			map[string]string{"origin": "https://gitlab.example.com/group/origin.git", "another": "git@gitlab.example.com:group/another.git"},
+			"group/origin",
+		},
+		{"https://example.com/gitlab/", map[string]string{"origin": "https://example.com/gitlab/group/project.git"}, "group/project"},
+		// No remote matches, so "origin" is used.
+		{"https://gitlab.example.com", map[string]string{"origin": "https://github.com/tozd/gitlab-release.git"}, "tozd/gitlab-release"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			repository, err := git.PlainInit(tempDir, false)
+			require.NoError(t, err)
+			for name, u := range tt.remotes {
+				_, err = repository.CreateRemote(&gitconfig.RemoteConfig{
+					Name: name,
+					URLs: []string{u},
+				})
+				require.NoError(t, err)
+			}
+
+			projectID, errE := inferProjectID(tempDir, tt.baseURL, nil)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, tt.want, projectID)
+		})
+	}
+}
+
+func TestValidateProject(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		project string
+		valid   bool
+	}{
+		{"123", true},
+		{"group/project", true},
+		{"tozd/gitlab/release", true},
+		{"my_group/my-project.go", true},
+		{"", false},
+		{"0", false},
+		{"-1", false},
+		{"project", false},
+		{"group/", false},
+		{"/group/project", false},
+		{"group//project", false},
+		{"group/../project", false},
+		{"group/project.git/", false},
+		{"https://gitlab.com/group/project", false},
+		{"group/my project", false},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			errE := validateProject(tt.project)
+			if tt.valid {
+				assert.NoError(t, errE, "% -+#.1v", errE)
+			} else {
+				assert.EqualError(t, errE, "GitLab project should be a numeric project ID or <namespace/project_path>")
+			}
+		})
+	}
+}
+
+//nolint:paralleltest
+func TestResolveProject(t *testing.T) {
+	tempDir := t.TempDir()
+	repository, err := git.PlainInit(tempDir, false)
+	require.NoError(t, err)
+	_, err = repository.CreateRemote(&gitconfig.RemoteConfig{
+		Name: "origin",
+		URLs: []string{"https://gitlab.com/group/project.git"},
+	})
+	require.NoError(t, err)
+
+	t.Setenv("CI_PROJECT_ID", "")
+
+	project, errE := resolveProject("", tempDir, "https://gitlab.com", nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "group/project", project)
+
+	project, errE = resolveProject("other/project", tempDir, "https://gitlab.com", nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "other/project", project)
+
+	_, errE = resolveProject("project", tempDir, "https://gitlab.com", nil)
+	assert.EqualError(t, errE, "GitLab project should be a numeric project ID or <namespace/project_path>")
+
+	// Numeric project ID from the environment is preferred over inferred path.
+	t.Setenv("CI_PROJECT_ID", "123")
+
+	project, errE = resolveProject("", tempDir, "https://gitlab.com", nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "123", project)
+
+	project, errE = resolveProject("other/project", tempDir, "https://gitlab.com", nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "other/project", project)
+}
@@ -0,0 +1,272 @@
+package release
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/hashicorp/go-cleanhttp"
+	"gitlab.com/tozd/go/errors"
+)
+
+// registryUsername is sent together with the GitLab API token when authenticating
+// to the container registry. GitLab ignores it for personal and project access tokens.
+const registryUsername = "gitlab-release"
+
+// Media types of manifests we can parse.
+var registryManifestMediaTypes = []string{ //nolint:gochecknoglobals
+	"application/vnd.oci.image.index.v1+json",
+	"application/vnd.oci.image.manifest.v1+json",
+	"application/vnd.docker.distribution.manifest.list.v2+json",
+	"application/vnd.docker.distribution.manifest.v2+json",
+}
+
+// Matches parameters of the "WWW-Authenticate" header, e.g., `realm="https://gitlab.com/jwt/auth"`.
+var registryChallengeRegex = regexp.MustCompile(`(\w+)="([^"]*)"`)
+
+// registryManifest is the subset of OCI image index and image manifest
+// (and their Docker equivalents) we need.
+type registryManifest struct {
+	Manifests []struct {
+		Digest string `json:"digest"`
+	} `json:"manifests"`
+	Config struct {
+		Digest string `json:"digest"`
+	} `json:"config"`
+}
+
+// registryImageConfig is the subset of OCI image configuration we need.
+type registryImageConfig struct {
+	Config struct {
+		Labels map[string]string `json:"Labels"`
+	} `json:"config"`
+}
+
+// registryClient fetches Docker images' metadata from a container registry
+// which uses GitLab token authentication.
+type registryClient struct {
+	HTTPClient *http.Client
+	Token      string
+
+	// Bearer tokens obtained for scopes.
+	tokens map[string]string
+	// The last bearer token used for a registry host.
+	hostTokens map[string]string
+}
+
+func newRegistryClient(config *Config) *registryClient {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = cleanhttp.DefaultPooledClient()
+	}
+	return &registryClient{
+		HTTPClient: httpClient,
+		Token:      config.Token,
+		tokens:     map[string]string{},
+		hostTokens: map[string]string{},
+	}
+}
+
+// parseImageLocation splits Docker image location (e.g., "registry.gitlab.com/group/project:1.0.0")
+// into registry host, repository path, and tag.
+func parseImageLocation(location string) (string, string, string, errors.E) {
+	host, rest, ok := strings.Cut(location, "/")
+	if !ok {
+		errE := errors.New("invalid Docker image location")
+		errors.Details(errE)["image"] = location
+		return "", "", "", errE
+	}
+	i := strings.LastIndex(rest, ":")
+	if i <= 0 || i == len(rest)-1 {
+		errE := errors.New("invalid Docker image location")
+		errors.Details(errE)["image"] = location
+		return "", "", "", errE
+	}
+	return host, rest[:i], rest[i+1:], nil
+}
+
+// authenticate obtains a bearer token for the challenge in the "WWW-Authenticate" header.
+func (c *registryClient) authenticate(challenge string) (string, errors.E) {
+	if !strings.HasPrefix(challenge, "Bearer ") {
+		errE := errors.New("unsupported container registry authentication")
+		errors.Details(errE)["challenge"] = challenge
+		return "", errE
+	}
+	params := map[string]string{}
+	for _, match := range registryChallengeRegex.FindAllStringSubmatch(challenge, -1) {
+		params[match[1]] = match[2]
+	}
+	if params["realm"] == "" {
+		errE := errors.New("container registry authentication is missing realm")
+		errors.Details(errE)["challenge"] = challenge
+		return "", errE
+	}
+
+	key := params["realm"] + " " + params["service"] + " " + params["scope"]
+	if token, ok := c.tokens[key]; ok {
+		return token, nil
+	}
+
+	query := url.Values{}
+	if params["service"] != "" {
+		query.Set("service", params["service"])
+	}
+	if params["scope"] != "" {
+		query.Set("scope", params["scope"])
+	}
+	req, err := http.NewRequest(http.MethodGet, params["realm"]+"?"+query.Encode(), nil) //nolint:noctx
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	req.SetBasicAuth(registryUsername, c.Token)
+	res, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return "", errors.WithMessage(err, "failed to authenticate to container registry")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		errE := errors.New("failed to authenticate to container registry")
+		errors.Details(errE)["status"] = res.StatusCode
+		errors.Details(errE)["realm"] = params["realm"]
+		return "", errE
+	}
+	var response struct {
+		Token string `json:"token"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		return "", errors.WithMessage(err, "cannot decode container registry token")
+	}
+
+	c.tokens[key] = response.Token
+	return response.Token, nil
+}
+
+// get fetches JSON from the container registry at host and decodes it into v,
+// authenticating if the registry requires it.
+func (c *registryClient) get(host, path, accept string, v interface{}) errors.E {
+	u := "https://" + host + path
+	token := c.hostTokens[host]
+	for attempt := 0; attempt < 2; attempt++ {
+		req, err := http.NewRequest(http.MethodGet, u, nil) //nolint:noctx
+		if err != nil {
+			return errors.WithStack(err)
+		}
+		req.Header.Set("Accept", accept)
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		res, err := c.HTTPClient.Do(req)
+		if err != nil {
+			errE := errors.WithMessage(err, "failed to fetch from container registry")
+			errors.Details(errE)["url"] = u
+			return errE
+		}
+		if res.StatusCode == http.StatusUnauthorized && attempt == 0 {
+			challenge := res.Header.Get("Www-Authenticate")
+			_, _ = io.Copy(io.Discard, res.Body)
+			res.Body.Close()
+			var errE errors.E
+			token, errE = c.authenticate(challenge)
+			if errE != nil {
+				return errE
+			}
+			c.hostTokens[host] = token
+			continue
+		}
+		defer res.Body.Close()
+		if res.StatusCode != http.StatusOK {
+			errE := errors.New("failed to fetch from container registry")
+			errors.Details(errE)["url"] = u
+			errors.Details(errE)["status"] = res.StatusCode
+			return errE
+		}
+		err = json.NewDecoder(res.Body).Decode(v)
+		if err != nil {
+			errE := errors.WithMessage(err, "cannot decode container registry response")
+			errors.Details(errE)["url"] = u
+			return errE
+		}
+		return nil
+	}
+	errE := errors.New("failed to authenticate to container registry")
+	errors.Details(errE)["url"] = u
+	return errE
+}
+
+// imageLabels fetches labels of Docker image at location. For multi-platform
+// images, labels of the first platform's image are returned.
+func (c *registryClient) imageLabels(location string) (map[string]string, errors.E) {
+	host, repository, tag, errE := parseImageLocation(location)
+	if errE != nil {
+		return nil, errE
+	}
+
+	accept := strings.Join(registryManifestMediaTypes, ", ")
+	var manifest registryManifest
+	errE = c.get(host, fmt.Sprintf("/v2/%s/manifests/%s", repository, tag), accept, &manifest)
+	if errE != nil {
+		errors.Details(errE)["image"] = location
+		return nil, errE
+	}
+	if manifest.Config.Digest == "" && len(manifest.Manifests) > 0 {
+		digest := manifest.Manifests[0].Digest
+		manifest = registryManifest{}
+		errE = c.get(host, fmt.Sprintf("/v2/%s/manifests/%s", repository, digest), accept, &manifest)
+		if errE != nil {
+			errors.Details(errE)["image"] = location
+			return nil, errE
+		}
+	}
+	if manifest.Config.Digest == "" {
+		errE := errors.New("Docker image manifest is missing config")
+		errors.Details(errE)["image"] = location
+		return nil, errE
+	}
+
+	var config registryImageConfig
+	errE = c.get(host, fmt.Sprintf("/v2/%s/blobs/%s", repository, manifest.Config.Digest), "application/json", &config)
+	if errE != nil {
+		errors.Details(errE)["image"] = location
+		return nil, errE
+	}
+	return config.Config.Labels, nil
+}
+
+// imagesLabel fetches value of label for each of Docker images.
+// Images without the label are omitted from the returned map.
+func imagesLabel(c *registryClient, images []string, label string) (map[string]string, errors.E) {
+	values := map[string]string{}
+	for _, image := range images {
+		labels, errE := c.imageLabels(image)
+		if errE != nil {
+			return nil, errE
+		}
+		if value, ok := labels[label]; ok && value != "" {
+			values[image] = value
+		}
+	}
+	return values, nil
+}
+
+// mapImagesToTagsByLabel maps Docker images to releases' tags based on the version
+// in their label values, with or without "v" prefix. Values have to match exactly.
+func mapImagesToTagsByLabel(values map[string]string, releases []Release) map[string][]string {
+	tagsToImages := map[string][]string{}
+	for _, release := range releases {
+		for image, value := range values {
+			if removeVPrefix(value) == removeVPrefix(release.Tag) {
+				tagsToImages[release.Tag] = append(tagsToImages[release.Tag], image)
+			}
+		}
+	}
+	for _, images := range tagsToImages {
+		sort.Strings(images)
+	}
+	return tagsToImages
+}
@@ -0,0 +1,116 @@
+package release
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseImageLocation(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		location   string
+		host       string
+		repository string
+		tag        string
+	}{
+		{"registry.gitlab.com/group/project:1.0.0", "registry.gitlab.com", "group/project", "1.0.0"},
+		{"registry.example.com:5000/group/project/image:latest", "registry.example.com:5000", "group/project/image", "latest"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			host, repository, tag, errE := parseImageLocation(tt.location)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, tt.host, host)
+			assert.Equal(t, tt.repository, repository)
+			assert.Equal(t, tt.tag, tag)
+		})
+	}
+
+	for _, location := range []string{"image", "registry.example.com/image", "registry.example.com:5000/image:"} {
+		_, _, _, errE := parseImageLocation(location)
+		assert.EqualError(t, errE, "invalid Docker image location")
+	}
+}
+
+func TestImagesLabel(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	authentications := 0
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/jwt/auth" {
+			username, password, ok := r.BasicAuth()
+			assert.True(t, ok)
+			assert.Equal(t, registryUsername, username)
+			assert.Equal(t, "token", password)
+			assert.Equal(t, "container_registry", r.URL.Query().Get("service"))
+			assert.Equal(t, "repository:group/project:pull", r.URL.Query().Get("scope"))
+			mu.Lock()
+			authentications++
+			mu.Unlock()
+			_, _ = w.Write([]byte(`{"token": "bearer"}`))
+			return
+		}
+
+		if r.Header.Get("Authorization") != "Bearer bearer" {
+			w.Header().Set("Www-Authenticate", `Bearer realm="`+server.URL+`/jwt/auth",service="container_registry",scope="repository:group/project:pull"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		switch r.URL.Path {
+		case "/v2/group/project/manifests/latest":
+			assert.Contains(t, r.Header.Get("Accept"), "application/vnd.oci.image.index.v1+json")
+			_, _ = w.Write([]byte(`{"manifests": [{"digest": "sha256:amd64"}, {"digest": "sha256:arm64"}]}`))
+		case "/v2/group/project/manifests/sha256:amd64":
+			_, _ = w.Write([]byte(`{"config": {"digest": "sha256:config1"}}`))
+		case "/v2/group/project/manifests/1.0.0":
+			_, _ = w.Write([]byte(`{"config": {"digest": "sha256:config1"}}`))
+		case "/v2/group/project/manifests/dev":
+			_, _ = w.Write([]byte(`{"config": {"digest": "sha256:config2"}}`))
+		case "/v2/group/project/blobs/sha256:config1":
+			_, _ = w.Write([]byte(`{"config": {"Labels": {"org.opencontainers.image.version": "1.0.0"}}}`))
+		case "/v2/group/project/blobs/sha256:config2":
+			_, _ = w.Write([]byte(`{"config": {"Labels": {}}}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	host := strings.TrimPrefix(server.URL, "https://")
+	client := newRegistryClient(&Config{Token: "token", HTTPClient: server.Client()})
+
+	images := []string{host + "/group/project:latest", host + "/group/project:1.0.0", host + "/group/project:dev"}
+	values, errE := imagesLabel(client, images, "org.opencontainers.image.version")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, map[string]string{
+		host + "/group/project:latest": "1.0.0",
+		host + "/group/project:1.0.0":  "1.0.0",
+	}, values)
+	mu.Lock()
+	assert.Equal(t, 1, authentications)
+	mu.Unlock()
+
+	tagsToImages := mapImagesToTagsByLabel(values, []Release{{Tag: "v1.0.0"}, {Tag: "v1.0.0-rc"}})
+	assert.Equal(t, map[string][]string{
+		"v1.0.0": {host + "/group/project:1.0.0", host + "/group/project:latest"},
+	}, tagsToImages)
+
+	_, errE = imagesLabel(client, []string{host + "/group/project:missing"}, "org.opencontainers.image.version")
+	assert.EqualError(t, errE, "failed to fetch from container registry")
+}
@@ -4,32 +4,118 @@
 package release
 
 import (
+	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"net/http"
 	"os"
+	"path"
+	"path/filepath"
+	"regexp"
 	"slices"
 	"sort"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
 	"time"
+	"unicode/utf8"
 
 	mapset "github.com/deckarep/golang-set/v2"
 	"github.com/go-git/go-git/v5"
+	gitconfig "github.com/go-git/go-git/v5/config"
 	"github.com/go-git/go-git/v5/plumbing"
+	"github.com/go-git/go-git/v5/plumbing/object"
+	"github.com/hashicorp/go-cleanhttp"
 	"github.com/xanzy/go-gitlab"
 	changelog "github.com/xmidt-org/gokeepachangelog"
 	"gitlab.com/tozd/go/errors"
-	"gitlab.com/tozd/go/x"
+	"gopkg.in/yaml.v3"
 )
 
 // See: https://docs.gitlab.com/ee/api/#offset-based-pagination
 const maxGitLabPageSize = 100
 
+// pageSize returns the page size for GitLab API list calls from config,
+// clamped to the maximum page size GitLab supports.
+func pageSize(config *Config) int {
+	if config.PageSize < 1 || config.PageSize > maxGitLabPageSize {
+		return maxGitLabPageSize
+	}
+	return config.PageSize
+}
+
+// readOnlyMode returns true if config selects a mode which does not change
+// anything in the GitLab project.
+func readOnlyMode(config *Config) bool {
+	return config.Audit || config.Report || config.PrintMapping || config.Graph != "" || config.PreviewLinks ||
+		(config.ExportGitHubOnly && config.ExportGitHub != "")
+}
+
 // Release holds information about a release extracted from a
 // Keep a Changelog changelog.
 type Release struct {
 	Tag     string
 	Changes string
 	Yanked  bool
+
+	// Title is the release heading from the changelog, without leading "#" characters
+	// (e.g., "[1.0.0] - 2017-06-20").
+	Title string
+
+	// Date is the release date from the changelog. Changelog dates do not have
+	// timezone information, so it is midnight UTC of the calendar day, unless
+	// the changelog provides also the time of the release (see HasTime).
+	Date time.Time
+
+	// HasTime is true if the changelog provides the time of the release
+	// in addition to its date (e.g., "## [1.2.0] - 2023-01-01T14:30:00Z").
+	HasTime bool
+
+	// Meta is additional per-release metadata loaded from the metadata file.
+	Meta map[string]interface{}
+
+	// References are link reference definitions from the changelog,
+	// mapping lower-cased labels to URLs.
+	References map[string]string
+
+	// Ref is a commit from which GitLab creates the tag when creating
+	// the release, if the tag does not yet exist in the GitLab project.
+	Ref string
+
+	// Assets are links listed in the "Assets" section of the release
+	// in the changelog, if extracted.
+	Assets []Asset
+
+	// Category of the release (e.g., "feature", "bugfix", or "security"), if determined.
+	Category string
+
+	// Latest is true for the newest release, if determined.
+	Latest bool
+
+	// Issues are GitLab issues closed by milestones of the release, if fetched.
+	Issues []Issue
+
+	// GitTag is the name of the git tag of the release, if it differs from Tag
+	// (e.g., when a prefix has been stripped from it). GitLab release is
+	// made for this git tag.
+	GitTag string
+}
+
+// releaseGitTag returns the name of the git tag of the release.
+func releaseGitTag(release Release) string {
+	if release.GitTag != "" {
+		return release.GitTag
+	}
+	return release.Tag
+}
+
+// Asset is a release link listed in the changelog.
+type Asset struct {
+	Name string
+	URL  string
 }
 
 // Tag holds information about a git tag.
@@ -38,6 +124,17 @@ type Tag struct {
 	Date time.Time
 }
 
+// defaultDescriptionTemplate lists the release category and Docker images followed by
+// changes from the changelog.
+//
+// TODO: Improve with official links to Docker images, once they are available.
+//
+//	See: https://gitlab.com/gitlab-org/gitlab/-/issues/346982
+const defaultDescriptionTemplate = "{{if .Category}}**Category:** {{.Category}}\n\n{{end}}{{if .Images}}##### Docker images\n{{range .Images}}* `{{.}}`\n{{end}}\n{{end}}{{.Changes}}"
+
+// defaultNameTemplate names releases after their tags, marking yanked releases.
+const defaultNameTemplate = "{{.Tag}}{{if .Yanked}} [YANKED]{{end}}"
+
 // Package describes a GitLab project's package.
 // Generic packages have files which are listed directly,
 // while non-generic packages have a web path to which we just link.
@@ -52,6 +149,26 @@ type Package struct {
 	Name    string
 	Version string
 	Files   []string
+
+	// Project is GitLab project ID or path of the project the package belongs to.
+	Project string
+
+	// FilesInfo holds metadata of files of a generic package, keyed by file name.
+	FilesInfo map[string]FileInfo
+}
+
+// FileInfo holds metadata of a file of a generic package.
+type FileInfo struct {
+	Size   int
+	SHA256 string
+}
+
+// packageFile is the subset of the GitLab package file we need. We decode it ourselves
+// because the GitLab API client does not expose the SHA-256 digest.
+type packageFile struct {
+	FileName   string `json:"file_name"`
+	Size       int    `json:"size"`
+	FileSHA256 string `json:"file_sha256"`
 }
 
 type link struct {
@@ -59,51 +176,549 @@ type link struct {
 	ID      *int
 	Package *Package
 	File    *string
+	Asset   *Asset
+	// Existing is set only for links fetched from GitLab.
+	Existing *gitlab.ReleaseLink
+}
+
+// changelogLine returns the 1-based line number of the first line in data
+// which equals line, or 0 if there is no such line.
+func changelogLine(data []byte, line string) int {
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for i := 1; scanner.Scan(); i++ {
+		if scanner.Text() == line {
+			return i
+		}
+	}
+	return 0
+}
+
+// releaseHeading returns the heading line of the release and the remaining lines of its body.
+//
+// The body of the release usually starts with its heading, but we do not rely on that:
+// if the first line is not a heading with the release version, the whole body is returned
+// and the heading is reconstructed from the release title.
+func releaseHeading(release changelog.Release) (string, []string) {
+	if len(release.Body) > 0 && strings.HasPrefix(strings.TrimSpace(release.Body[0]), "#") && strings.Contains(release.Body[0], release.Version) {
+		return release.Body[0], release.Body[1:]
+	}
+	return "## " + release.Title, release.Body
+}
+
+// defaultUnreleasedHeadings are headings of the changelog section with unreleased changes.
+var defaultUnreleasedHeadings = []string{"Unreleased"} //nolint:gochecknoglobals
+
+// unreleasedHeadings returns unreleased headings from config or the default ones.
+func unreleasedHeadings(config *Config) []string {
+	if len(config.UnreleasedHeadings) > 0 {
+		return config.UnreleasedHeadings
+	}
+	return defaultUnreleasedHeadings
 }
 
 // changelogReleases extacts releases from a changelog file at path.
 // The changelog should be in the Keep a Changelog format.
-func changelogReleases(path string) ([]Release, errors.E) {
-	file, err := os.Open(path)
+//
+// The changelog can start with YAML front matter with configuration, which is returned as well.
+//
+// Releases with any of unreleased headings (compared case-insensitively) are skipped.
+func changelogReleases(path string, unreleased []string) ([]Release, *frontMatter, errors.E) {
+	data, err := os.ReadFile(path)
 	if err != nil {
 		errE := errors.WithMessage(err, "cannot read changelog")
 		errors.Details(errE)["path"] = path
+		return nil, nil, errE
+	}
+	return parseChangelogReleases(data, path, unreleased)
+}
+
+// isChangelogURL returns true if the changelog path is an HTTP(S) URL.
+func isChangelogURL(path string) bool {
+	lower := strings.ToLower(path)
+	return strings.HasPrefix(lower, "http://") || strings.HasPrefix(lower, "https://")
+}
+
+// fetchChangelog fetches the content of the changelog at url over HTTP.
+//
+// If config.HTTPClient is set, it is used, otherwise the default one is
+// used, which honors proxy environment variables.
+func fetchChangelog(config *Config, url string) ([]byte, errors.E) {
+	httpClient := config.HTTPClient
+	if httpClient == nil {
+		httpClient = cleanhttp.DefaultPooledClient()
+	}
+	req, err := http.NewRequest(http.MethodGet, url, nil) //nolint:noctx
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot fetch changelog")
+		errors.Details(errE)["url"] = url
 		return nil, errE
 	}
-	defer file.Close()
-	c, err := changelog.Parse(file)
+	res, err := httpClient.Do(req)
 	if err != nil {
-		errE := errors.WithMessage(err, "cannot parse changelog")
+		errE := errors.WithMessage(err, "cannot fetch changelog")
+		errors.Details(errE)["url"] = url
+		return nil, errE
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		_, _ = io.Copy(io.Discard, res.Body)
+		errE := errors.New("cannot fetch changelog: unexpected response status")
+		errors.Details(errE)["url"] = url
+		errors.Details(errE)["status"] = res.StatusCode
+		return nil, errE
+	}
+	data, err := io.ReadAll(res.Body)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot fetch changelog")
+		errors.Details(errE)["url"] = url
+		return nil, errE
+	}
+	return data, nil
+}
+
+// changelogAtRef reads the content of the changelog file at path as it exists
+// at git ref (a branch, a tag, or a commit) in the git repository containing path.
+func changelogAtRef(path, ref string) ([]byte, errors.E) {
+	absPath, err := filepath.Abs(path)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot determine absolute changelog path")
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+	repository, err := git.PlainOpenWithOptions(filepath.Dir(absPath), &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: false,
+	})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot open git repository")
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+	workTree, err := repository.Worktree()
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot obtain git worktree")
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+	relPath, err := filepath.Rel(workTree.Filesystem.Root(), absPath)
+	if err != nil || strings.HasPrefix(relPath, "..") {
+		errE := errors.New("changelog is not inside the git repository")
 		errors.Details(errE)["path"] = path
 		return nil, errE
 	}
+
+	hash, errE := resolveRef(repository, ref)
+	if errE != nil {
+		return nil, errE
+	}
+	commit, err := repository.CommitObject(*hash)
+	if err != nil {
+		errE := errors.WithMessage(err, "commit object")
+		errors.Details(errE)["ref"] = ref
+		return nil, errE
+	}
+	file, err := commit.File(filepath.ToSlash(relPath))
+	if err != nil {
+		errE := errors.WithMessage(err, "changelog not found at git ref")
+		errors.Details(errE)["ref"] = ref
+		errors.Details(errE)["path"] = filepath.ToSlash(relPath)
+		return nil, errE
+	}
+	contents, err := file.Contents()
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot read changelog at git ref")
+		errors.Details(errE)["ref"] = ref
+		errors.Details(errE)["path"] = filepath.ToSlash(relPath)
+		return nil, errE
+	}
+	return []byte(contents), nil
+}
+
+// changelogTimeRegex matches release headings with time after the date (e.g.,
+// "## [1.2.0] - 2023-01-01T14:30:00Z"), which Keep a Changelog parser does not support.
+var changelogTimeRegex = regexp.MustCompile(
+	`(?im)^([ \t]*##[ \t]+\[([^\]]*)\][ \t]*-?[ \t]*(\d{4}-\d\d-\d\d))` +
+		`(T\d\d:\d\d(?::\d\d(?:\.\d+)?)?(?:Z|[+-]\d\d:\d\d)?)([ \t]*(?:\[[ \t]*YANKED[ \t]*\])?[ \t]*)$`,
+)
+
+// changelogTimeLayouts are layouts of release times in the changelog. Times without
+// timezone are in UTC.
+var changelogTimeLayouts = []string{ //nolint:gochecknoglobals
+	"2006-01-02T15:04:05Z07:00",
+	"2006-01-02T15:04Z07:00",
+	"2006-01-02T15:04:05",
+	"2006-01-02T15:04",
+}
+
+// changelogHeadingTime is the time of the release from its heading in the changelog.
+type changelogHeadingTime struct {
+	Heading string
+	Time    time.Time
+}
+
+// extractChangelogTimes removes time from release headings in changelog body, so that
+// it can be parsed by Keep a Changelog parser, and returns it, keyed by release version.
+func extractChangelogTimes(body []byte) ([]byte, map[string]changelogHeadingTime, errors.E) {
+	times := map[string]changelogHeadingTime{}
+	var errE errors.E
+	body = changelogTimeRegex.ReplaceAllFunc(body, func(line []byte) []byte {
+		match := changelogTimeRegex.FindSubmatch(line)
+		value := string(match[3]) + string(match[4])
+		for _, layout := range changelogTimeLayouts {
+			t, err := time.Parse(layout, value)
+			if err == nil {
+				times[string(match[2])] = changelogHeadingTime{
+					Heading: string(line),
+					Time:    t,
+				}
+				return append(append([]byte{}, match[1]...), match[5]...)
+			}
+		}
+		if errE == nil {
+			errE = errors.New("invalid release time in the changelog")
+			errors.Details(errE)["release"] = string(match[2])
+			errors.Details(errE)["time"] = value
+		}
+		return line
+	})
+	if errE != nil {
+		return nil, nil, errE
+	}
+	return body, times, nil
+}
+
+// parseChangelogReleases extracts releases from changelog data read from path.
+// See changelogReleases for details.
+func parseChangelogReleases(data []byte, path string, unreleased []string) ([]Release, *frontMatter, errors.E) {
+	// Normalize Windows (CRLF) and old Mac (CR) line endings.
+	data = bytes.ReplaceAll(data, []byte("\r\n"), []byte("\n"))
+	data = bytes.ReplaceAll(data, []byte("\r"), []byte("\n"))
+	front, body, errE := splitFrontMatter(data)
+	if errE != nil {
+		errors.Details(errE)["path"] = path
+		return nil, nil, errE
+	}
+	f, errE := parseFrontMatter(front)
+	if errE != nil {
+		errors.Details(errE)["path"] = path
+		return nil, nil, errE
+	}
+	body, times, errE := extractChangelogTimes(body)
+	if errE != nil {
+		errors.Details(errE)["path"] = path
+		return nil, nil, errE
+	}
+	c, err := changelog.Parse(bytes.NewReader(body))
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot parse changelog")
+		errors.Details(errE)["path"] = path
+		return nil, nil, errE
+	}
+	references := map[string]string{}
+	for _, l := range c.Links {
+		references[strings.ToLower(l.Version)] = l.Url
+	}
 	releases := make([]Release, 0, len(c.Releases))
 	for _, release := range c.Releases {
-		if strings.ToLower(release.Version) == "unreleased" {
+		if slices.ContainsFunc(unreleased, func(heading string) bool {
+			return strings.EqualFold(strings.TrimSpace(heading), release.Version)
+		}) {
 			continue
 		}
+		heading, body := releaseHeading(release)
+		headingTime, hasTime := times[release.Version]
+		if hasTime {
+			heading = headingTime.Heading
+		}
 		if strings.HasPrefix(release.Version, "v") {
 			errE := errors.New(`release in the changelog starts with "v", but it should not`)
 			errors.Details(errE)["release"] = release.Version
-			return nil, errE
+			errors.Details(errE)["path"] = path
+			errors.Details(errE)["line"] = changelogLine(data, heading)
+			return nil, nil, errE
 		}
 		if release.Date == nil {
 			errE := errors.New("release in the changelog is missing date")
 			errors.Details(errE)["release"] = release.Version
-			return nil, errE
+			errors.Details(errE)["path"] = path
+			errors.Details(errE)["line"] = changelogLine(data, heading)
+			return nil, nil, errE
+		}
+
+		date := *release.Date
+		if hasTime {
+			date = headingTime.Time
 		}
 
 		releases = append(releases, Release{
-			Tag:     "v" + release.Version,
-			Changes: strings.Join(release.Body[1:], "\n"),
-			Yanked:  release.Yanked,
+			Tag:        "v" + release.Version,
+			Title:      strings.TrimSpace(strings.TrimLeft(heading, "#")),
+			Changes:    strings.Join(body, "\n"),
+			Yanked:     release.Yanked,
+			Date:       date,
+			HasTime:    hasTime,
+			Meta:       nil,
+			References: references,
 		})
 	}
-	return releases, nil
+	// Changelogs are usually ordered from the newest release to the oldest,
+	// but we do not depend on that.
+	sortReleases(releases)
+	return releases, f, nil
+}
+
+// selectRelease returns only the release with tag (with or without "v" prefix, or
+// its original git tag if a prefix has been stripped from it).
+func selectRelease(releases []Release, tag string) ([]Release, errors.E) {
+	for _, release := range releases {
+		if removeVPrefix(release.Tag) == removeVPrefix(tag) || releaseGitTag(release) == tag {
+			return []Release{release}, nil
+		}
+	}
+	errE := errors.New("tag is not among changelog releases")
+	errors.Details(errE)["tag"] = tag
+	return nil, errE
+}
+
+// reselectReleases returns releases with the same tags as selected, in the order of
+// selected. Selecting releases copies them, so this picks up changes made to releases
+// after they have been selected.
+func reselectReleases(releases, selected []Release) []Release {
+	tagsToReleases := map[string]Release{}
+	for _, release := range releases {
+		tagsToReleases[release.Tag] = release
+	}
+	reselected := make([]Release, 0, len(selected))
+	for _, release := range selected {
+		reselected = append(reselected, tagsToReleases[release.Tag])
+	}
+	return reselected
+}
+
+// latestBadgeReleases returns selected together with releases for which the latest
+// badge has to be updated, even if they have not been selected: the latest release
+// and releases whose existing GitLab release description still shows the badge.
+func latestBadgeReleases(releases, selected []Release, gitLabReleases []*gitlab.Release) []Release {
+	withBadge := mapset.NewThreadUnsafeSet[string]()
+	for _, rel := range gitLabReleases {
+		if strings.Contains(rel.Description, latestBadge) {
+			withBadge.Add(rel.TagName)
+		}
+	}
+	selectedTags := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range selected {
+		selectedTags.Add(release.Tag)
+	}
+
+	result := slices.Clone(selected)
+	for _, release := range releases {
+		if selectedTags.Contains(release.Tag) {
+			continue
+		}
+		if release.Latest || withBadge.Contains(releaseGitTag(release)) {
+			result = append(result, release)
+		}
+	}
+	return result
+}
+
+// releaseDateRange limits releases by their dates. After is inclusive and Before
+// is exclusive, so that consecutive ranges do not overlap (e.g., after 2023-01-01
+// and before 2024-01-01 are releases from 2023). Either can be nil.
+type releaseDateRange struct {
+	After  *time.Time
+	Before *time.Time
+}
+
+// parseDateRange parses the date range configured with AfterDate and BeforeDate.
+func parseDateRange(config *Config) (releaseDateRange, errors.E) {
+	r := releaseDateRange{After: nil, Before: nil}
+	for _, d := range []struct {
+		value  string
+		name   string
+		target **time.Time
+	}{
+		{config.AfterDate, "after", &r.After},
+		{config.BeforeDate, "before", &r.Before},
+	} {
+		if d.value == "" {
+			continue
+		}
+		t, err := time.Parse("2006-01-02", d.value)
+		if err != nil {
+			errE := errors.WithMessage(err, "invalid date")
+			errors.Details(errE)[d.name] = d.value
+			return releaseDateRange{}, errE //nolint:exhaustruct
+		}
+		*d.target = &t
+	}
+	if r.After != nil && r.Before != nil && !r.After.Before(*r.Before) {
+		errE := errors.New("after date should be before before date")
+		errors.Details(errE)["after"] = config.AfterDate
+		errors.Details(errE)["before"] = config.BeforeDate
+		return releaseDateRange{}, errE //nolint:exhaustruct
+	}
+	return r, nil
+}
+
+// IsSet returns true if the date range limits releases.
+func (r releaseDateRange) IsSet() bool {
+	return r.After != nil || r.Before != nil
+}
+
+// Contains returns true if the calendar day of t (in UTC) is inside the date range.
+func (r releaseDateRange) Contains(t time.Time) bool {
+	t = t.UTC()
+	day := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+	if r.After != nil && day.Before(*r.After) {
+		return false
+	}
+	if r.Before != nil && !day.Before(*r.Before) {
+		return false
+	}
+	return true
+}
+
+// filterReleasesByDate returns only releases with the changelog date inside the date range.
+func filterReleasesByDate(releases []Release, dateRange releaseDateRange) []Release {
+	filtered := []Release{}
+	for _, release := range releases {
+		if dateRange.Contains(release.Date) {
+			filtered = append(filtered, release)
+		}
+	}
+	return filtered
+}
+
+// changedReleaseTags returns tags of releases whose changelog sections differ from
+// those in the changelog at path as it exists at git ref base (e.g., the base of a merge
+// request). Releases which do not exist at base are changed as well.
+func changedReleaseTags(path, base string, releases []Release, unreleased []string) ([]string, errors.E) {
+	if base == "" {
+		return nil, errors.New("base git ref is required to sync only changed releases")
+	}
+	data, errE := changelogAtRef(path, base)
+	if errE != nil {
+		return nil, errE
+	}
+	baseReleases, _, errE := parseChangelogReleases(data, path, unreleased)
+	if errE != nil {
+		errors.Details(errE)["ref"] = base
+		return nil, errE
+	}
+	tagsToBase := map[string]Release{}
+	for _, release := range baseReleases {
+		tagsToBase[release.Tag] = release
+	}
+	changed := []string{}
+	for _, release := range releases {
+		b, ok := tagsToBase[release.Tag]
+		if !ok || b.Title != release.Title || b.Changes != release.Changes || b.Yanked != release.Yanked || !b.Date.Equal(release.Date) {
+			changed = append(changed, release.Tag)
+		}
+	}
+	return changed, nil
+}
+
+// checkNotes returns an error if any of releases has empty changes.
+func checkNotes(releases []Release) errors.E {
+	versions := []string{}
+	for _, release := range releases {
+		if strings.TrimSpace(release.Changes) == "" {
+			versions = append(versions, removeVPrefix(release.Tag))
+		}
+	}
+	if len(versions) > 0 {
+		errE := errors.New("found changelog releases without notes")
+		errors.Details(errE)["releases"] = versions
+		return errE
+	}
+	return nil
+}
+
+// assetsSectionTitle is the title of the release section in the changelog
+// from which assets are extracted.
+const assetsSectionTitle = "Assets"
+
+// extractAssets moves links listed in the "Assets" section of each release's
+// changes into the release's assets, removing the section from changes.
+func extractAssets(releases []Release) errors.E {
+	for i := range releases {
+		changes, section, ok := extractMarkdownSection(releases[i].Changes, assetsSectionTitle)
+		if !ok {
+			continue
+		}
+		assets := []Asset{}
+		for _, line := range section {
+			for _, match := range markdownLinks(line) {
+				name := strings.TrimSpace(match[0])
+				for _, asset := range assets {
+					if asset.Name == name {
+						errE := errors.New("duplicate asset name in the changelog")
+						errors.Details(errE)["release"] = releases[i].Tag
+						errors.Details(errE)["asset"] = name
+						return errE
+					}
+				}
+				assets = append(assets, Asset{
+					Name: name,
+					URL:  match[1],
+				})
+			}
+		}
+		releases[i].Changes = changes
+		releases[i].Assets = assets
+	}
+	return nil
+}
+
+// releasesMetadata reads per-release metadata from a YAML file at path.
+// The file should be a mapping from versions (without "v" prefix) to
+// mappings of arbitrary metadata.
+func releasesMetadata(path string) (map[string]map[string]interface{}, errors.E) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot read metadata")
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+	metadata := map[string]map[string]interface{}{}
+	err = yaml.Unmarshal(data, &metadata)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot parse metadata")
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+	return metadata, nil
+}
+
+// applyMetadata sets Meta of releases from metadata. It warns about any
+// versions in metadata which are not among releases.
+func applyMetadata(releases []Release, metadata map[string]map[string]interface{}, warnings *warnings) {
+	used := mapset.NewThreadUnsafeSet[string]()
+	for i := range releases {
+		version := removeVPrefix(releases[i].Tag)
+		meta, ok := metadata[version]
+		if ok {
+			releases[i].Meta = meta
+			used.Add(version)
+		}
+	}
+
+	versions := make([]string, 0, len(metadata))
+	for version := range metadata {
+		if !used.Contains(version) {
+			versions = append(versions, version)
+		}
+	}
+	slices.Sort(versions)
+	for _, version := range versions {
+		warnings.Warnf("metadata for version \"%s\" which is not among changelog releases.", version)
+	}
 }
 
 // gitTags obtains all tags from a git repository at path.
-func gitTags(path string) ([]Tag, errors.E) {
+func gitTags(path string, warnings *warnings) ([]Tag, errors.E) {
 	repository, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
 		DetectDotGit:          true,
 		EnableDotGitCommonDir: false,
@@ -125,12 +740,19 @@ func gitTags(path string) ([]Tag, errors.E) {
 	err = tagRefs.ForEach(func(ref *plumbing.Reference) error {
 		tag, err := repository.TagObject(ref.Hash()) //nolint:govet
 		if err != nil && errors.Is(err, plumbing.ErrObjectNotFound) {
-			commit, err := repository.CommitObject(ref.Hash()) //nolint:govet
+			obj, err := repository.Object(plumbing.AnyObject, ref.Hash()) //nolint:govet
 			if err != nil {
 				errE := errors.WithMessage(err, "commit object")
 				errors.Details(errE)["hash"] = ref.Hash()
 				return errE
 			}
+			// A lightweight tag can (rarely) point directly to a tree or a blob.
+			// Such a tag does not have a date, so we skip it.
+			commit, ok := obj.(*object.Commit)
+			if !ok {
+				warnings.Warnf("git tag \"%s\" points to a %s and not to a commit, skipping it.", ref.Name().Short(), obj.Type())
+				return nil
+			}
 			tags = append(tags, Tag{
 				Name: ref.Name().Short(),
 				Date: commit.Committer.When,
@@ -154,32 +776,207 @@ func gitTags(path string) ([]Tag, errors.E) {
 	return tags, nil
 }
 
-// compareReleasesTags returns an error if all releases do not exactly match all tags.
-func compareReleasesTags(releases []Release, tags []Tag) errors.E {
-	allReleases := mapset.NewThreadUnsafeSet[string]()
-	for _, release := range releases {
-		allReleases.Add(release.Tag)
+// resolveRef resolves ref (a branch, a tag, or a commit) in the git repository
+// to a commit. Branches which exist only on the "origin" remote are resolved as well.
+// Empty ref resolves to HEAD.
+func resolveRef(repository *git.Repository, ref string) (*plumbing.Hash, errors.E) {
+	if ref == "" {
+		ref = "HEAD"
 	}
+	hash, err := repository.ResolveRevision(plumbing.Revision(ref))
+	if errors.Is(err, plumbing.ErrReferenceNotFound) {
+		remoteHash, remoteErr := repository.ResolveRevision(plumbing.Revision("refs/remotes/origin/" + ref))
+		if remoteErr == nil {
+			return remoteHash, nil
+		}
+	}
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot resolve git ref")
+		errors.Details(errE)["ref"] = ref
+		return nil, errE
+	}
+	return hash, nil
+}
 
+// setMissingTagsRefs sets Ref of releases which do not have a corresponding tag
+// among tags to the commit ref resolves to (by default HEAD) in the git repository
+// at path. Tags themselves are created with createMissingTags.
+func setMissingTagsRefs(path, ref string, releases []Release, tags []Tag) errors.E {
 	allTags := mapset.NewThreadUnsafeSet[string]()
 	for _, tag := range tags {
 		allTags.Add(tag.Name)
 	}
 
-	extraReleases := allReleases.Difference(allTags)
-	if extraReleases.Cardinality() > 0 {
-		errE := errors.Errorf("found changelog releases not among git tags")
-		releases := extraReleases.ToSlice()
-		slices.Sort(releases)
-		errors.Details(errE)["releases"] = releases
+	repository, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: false,
+	})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot open git repository")
+		errors.Details(errE)["path"] = path
 		return errE
 	}
 
-	extraTags := allTags.Difference(allReleases)
-	if extraTags.Cardinality() > 0 {
-		errE := errors.Errorf("found git tags not among changelog releases")
-		tags := extraTags.ToSlice()
-		slices.Sort(tags)
+	var hash *plumbing.Hash
+	for i := range releases {
+		if allTags.Contains(releases[i].Tag) {
+			continue
+		}
+		// We resolve ref only if there are any missing tags.
+		if hash == nil {
+			var errE errors.E
+			hash, errE = resolveRef(repository, ref)
+			if errE != nil {
+				return errE
+			}
+		}
+		releases[i].Ref = hash.String()
+	}
+
+	return nil
+}
+
+// createMissingTags creates annotated git tags in the git repository at path for
+// releases which do not have a corresponding tag among tags, at commits set as their
+// Ref by setMissingTagsRefs. Existing tags are never moved. Created tags are named
+// as releases, without prefixes stripped from existing tags, and their messages are
+// rendered with the tag message template from config.
+//
+// It returns created tags.
+func createMissingTags(config *Config, path string, releases []Release, tags []Tag) ([]Tag, errors.E) {
+	allTags := mapset.NewThreadUnsafeSet[string]()
+	for _, tag := range tags {
+		allTags.Add(tag.Name)
+	}
+
+	repository, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: false,
+	})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot open git repository")
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+
+	cfg, err := repository.ConfigScoped(gitconfig.SystemScope)
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot read git config")
+	}
+
+	created := []Tag{}
+	for _, release := range releases {
+		if release.Ref == "" || allTags.Contains(release.Tag) {
+			continue
+		}
+
+		if cfg.User.Name == "" || cfg.User.Email == "" {
+			return nil, errors.New("git user name and email have to be configured to create tags")
+		}
+
+		// The message is the same as the message of the tag GitLab would create.
+		message, errE := releaseTagMessage(config, release)
+		if errE != nil {
+			return nil, errE
+		}
+
+		fmt.Printf("Creating git tag \"%s\".\n", release.Tag)
+		tagger := &object.Signature{
+			Name:  cfg.User.Name,
+			Email: cfg.User.Email,
+			When:  time.Now(),
+		}
+		_, err := repository.CreateTag(release.Tag, plumbing.NewHash(release.Ref), &git.CreateTagOptions{ //nolint:govet
+			Tagger:  tagger,
+			Message: message,
+			SignKey: nil,
+		})
+		if err != nil {
+			errE := errors.WithMessage(err, "cannot create git tag")
+			errors.Details(errE)["tag"] = release.Tag
+			return nil, errE
+		}
+		created = append(created, Tag{
+			Name: release.Tag,
+			Date: tagger.When,
+		})
+	}
+
+	return created, nil
+}
+
+// checkReleasesRefs returns an error if a commit set as Ref of any of releases
+// does not exist in GitLab projectID project. GitLab can create tags only from
+// commits which have already been pushed.
+func checkReleasesRefs(client *gitlab.Client, projectID string, releases []Release) errors.E {
+	checked := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range releases {
+		if release.Ref == "" || checked.Contains(release.Ref) {
+			continue
+		}
+		checked.Add(release.Ref)
+
+		_, response, err := client.Commits.GetCommit(projectID, release.Ref, nil)
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			errE := errors.New("git ref does not exist in GitLab project, push it first")
+			errors.Details(errE)["ref"] = release.Ref
+			errors.Details(errE)["tag"] = release.Tag
+			return errE
+		} else if err != nil {
+			errE := errors.WithMessage(err, "failed to get GitLab commit")
+			errors.Details(errE)["ref"] = release.Ref
+			return errE
+		}
+	}
+	return nil
+}
+
+// withoutMissingTags returns releases without those which do not have a corresponding
+// tag among tags, unless they are among selected.
+func withoutMissingTags(releases, selected []Release, tags []Tag) []Release {
+	allTags := mapset.NewThreadUnsafeSet[string]()
+	for _, tag := range tags {
+		allTags.Add(tag.Name)
+	}
+	selectedTags := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range selected {
+		selectedTags.Add(release.Tag)
+	}
+	result := []Release{}
+	for _, release := range releases {
+		if allTags.Contains(release.Tag) || selectedTags.Contains(release.Tag) {
+			result = append(result, release)
+		}
+	}
+	return result
+}
+
+// compareReleasesTags returns an error if all releases do not exactly match all tags.
+func compareReleasesTags(releases []Release, tags []Tag) errors.E {
+	allReleases := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range releases {
+		allReleases.Add(release.Tag)
+	}
+
+	allTags := mapset.NewThreadUnsafeSet[string]()
+	for _, tag := range tags {
+		allTags.Add(tag.Name)
+	}
+
+	extraReleases := allReleases.Difference(allTags)
+	if extraReleases.Cardinality() > 0 {
+		errE := errors.Errorf("found changelog releases not among git tags")
+		releases := extraReleases.ToSlice()
+		slices.Sort(releases)
+		errors.Details(errE)["releases"] = releases
+		return errE
+	}
+
+	extraTags := allTags.Difference(allReleases)
+	if extraTags.Cardinality() > 0 {
+		errE := errors.Errorf("found git tags not among changelog releases")
+		tags := extraTags.ToSlice()
+		slices.Sort(tags)
 		errors.Details(errE)["tags"] = tags
 		return errE
 	}
@@ -187,34 +984,212 @@ func compareReleasesTags(releases []Release, tags []Tag) errors.E {
 	return nil
 }
 
+// stripTagPrefixes strips the first matching of prefixes from names of git tags,
+// so that they can be compared with changelog releases. Because releases have
+// a "v" prefix, it is added to stripped names without it (e.g., both "release/1.2.0"
+// and "release/v1.2.0" become "v1.2.0"). It returns tags with stripped names
+// and a map from stripped names to original names of tags.
+func stripTagPrefixes(tags []Tag, prefixes []string) ([]Tag, map[string]string, errors.E) {
+	stripped := make([]Tag, 0, len(tags))
+	names := map[string]string{}
+	original := map[string]string{}
+	for _, tag := range tags {
+		name := tag.Name
+		for _, prefix := range prefixes {
+			if prefix != "" && strings.HasPrefix(name, prefix) {
+				name = strings.TrimPrefix(name, prefix)
+				if !strings.HasPrefix(name, "v") {
+					name = "v" + name
+				}
+				names[name] = tag.Name
+				break
+			}
+		}
+		if other, ok := original[name]; ok {
+			errE := errors.New("multiple git tags map to the same release")
+			errors.Details(errE)["tags"] = []string{other, tag.Name}
+			errors.Details(errE)["release"] = name
+			return nil, nil, errE
+		}
+		original[name] = tag.Name
+		stripped = append(stripped, Tag{Name: name, Date: tag.Date})
+	}
+	return stripped, names, nil
+}
+
+// semverRegex matches a valid semantic version (without "v" prefix).
+//
+// See: https://semver.org/#is-there-a-suggested-regular-expression-regex-to-check-a-semver-string
+var semverRegex = regexp.MustCompile(`^(0|[1-9]\d*)\.(0|[1-9]\d*)\.(0|[1-9]\d*)` +
+	`(?:-((?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*)(?:\.(?:0|[1-9]\d*|\d*[a-zA-Z-][0-9a-zA-Z-]*))*))?` +
+	`(?:\+([0-9a-zA-Z-]+(?:\.[0-9a-zA-Z-]+)*))?$`)
+
+// compareVersions compares versions a and b (without "v" prefix) by semantic versioning
+// precedence, returning -1, 0, or +1. If any of them is not a valid semantic version,
+// they are compared as strings.
+func compareVersions(a, b string) int {
+	ma := semverRegex.FindStringSubmatch(a)
+	mb := semverRegex.FindStringSubmatch(b)
+	if ma == nil || mb == nil {
+		return strings.Compare(a, b)
+	}
+	for i := 1; i <= 3; i++ { //nolint:gomnd
+		if c := compareNumeric(ma[i], mb[i]); c != 0 {
+			return c
+		}
+	}
+	// A version without pre-release has higher precedence.
+	switch {
+	case ma[4] == "" && mb[4] == "":
+		return 0
+	case ma[4] == "":
+		return 1
+	case mb[4] == "":
+		return -1
+	}
+	pa := strings.Split(ma[4], ".")
+	pb := strings.Split(mb[4], ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		_, errA := strconv.ParseUint(pa[i], 10, 64)
+		_, errB := strconv.ParseUint(pb[i], 10, 64)
+		var c int
+		switch {
+		case errA == nil && errB == nil:
+			c = compareNumeric(pa[i], pb[i])
+		case errA == nil:
+			// Numeric identifiers have lower precedence.
+			c = -1
+		case errB == nil:
+			c = 1
+		default:
+			c = strings.Compare(pa[i], pb[i])
+		}
+		if c != 0 {
+			return c
+		}
+	}
+	return compareNumeric(strconv.Itoa(len(pa)), strconv.Itoa(len(pb)))
+}
+
+// compareNumeric compares decimal numbers without leading zeros given as strings.
+func compareNumeric(a, b string) int {
+	if len(a) != len(b) {
+		if len(a) < len(b) {
+			return -1
+		}
+		return 1
+	}
+	return strings.Compare(a, b)
+}
+
+// sortReleases sorts releases from the newest to the oldest, by date
+// and then by version, independently of their order in the changelog.
+func sortReleases(releases []Release) {
+	sort.SliceStable(releases, func(i, j int) bool {
+		if !releases[i].Date.Equal(releases[j].Date) {
+			return releases[i].Date.After(releases[j].Date)
+		}
+		return compareVersions(removeVPrefix(releases[i].Tag), removeVPrefix(releases[j].Tag)) > 0
+	})
+}
+
+// markLatestRelease sets Latest of the newest release by semantic version and
+// clears it for all other releases. Yanked releases, pre-releases, and releases
+// with versions which are not valid semantic versions are not considered.
+func markLatestRelease(releases []Release) {
+	latest := -1
+	for i, release := range releases {
+		releases[i].Latest = false
+		match := semverRegex.FindStringSubmatch(removeVPrefix(release.Tag))
+		if release.Yanked || match == nil || match[4] != "" {
+			continue
+		}
+		if latest == -1 || compareVersions(removeVPrefix(release.Tag), removeVPrefix(releases[latest].Tag)) > 0 {
+			latest = i
+		}
+	}
+	if latest != -1 {
+		releases[latest].Latest = true
+	}
+}
+
+// checkSemver returns an error if any of releases or tags is not a valid
+// semantic version (with "v" prefix).
+func checkSemver(releases []Release, tags []Tag) errors.E {
+	invalidReleases := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range releases {
+		if !strings.HasPrefix(release.Tag, "v") || !semverRegex.MatchString(removeVPrefix(release.Tag)) {
+			invalidReleases.Add(release.Tag)
+		}
+	}
+
+	invalidTags := mapset.NewThreadUnsafeSet[string]()
+	for _, tag := range tags {
+		if !strings.HasPrefix(tag.Name, "v") || !semverRegex.MatchString(removeVPrefix(tag.Name)) {
+			invalidTags.Add(tag.Name)
+		}
+	}
+
+	if invalidReleases.Cardinality() > 0 || invalidTags.Cardinality() > 0 {
+		errE := errors.New("found versions which are not valid semantic versions")
+		if invalidReleases.Cardinality() > 0 {
+			releases := invalidReleases.ToSlice()
+			slices.Sort(releases)
+			errors.Details(errE)["releases"] = releases
+		}
+		if invalidTags.Cardinality() > 0 {
+			tags := invalidTags.ToSlice()
+			slices.Sort(tags)
+			errors.Details(errE)["tags"] = tags
+		}
+		return errE
+	}
+
+	return nil
+}
+
 // projectConfiguration fetches configuration of a GitLab projectID project
-// and returns if issues, packages, and Docker images are enabled.
+// and returns if issues, packages, and Docker images are enabled, and its web URL.
+//
+// It returns a descriptive error if the project does not exist or the token cannot access it.
 func projectConfiguration( //nolint:nonamedreturns
 	client *gitlab.Client, projectID string,
-) (hasIssues, hasPackages, hasImages bool, errE errors.E) {
-	project, _, err := client.Projects.GetProject(projectID, nil)
-	if err != nil {
+) (hasIssues, hasPackages, hasImages bool, webURL string, errE errors.E) {
+	project, response, err := client.Projects.GetProject(projectID, nil)
+	if response != nil && slices.Contains([]int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound}, response.StatusCode) {
+		errE = errors.Errorf(`cannot access GitLab project "%s"; check --project and token permissions`, projectID)
+		errors.Details(errE)["project"] = projectID
+		errors.Details(errE)["status"] = response.StatusCode
+		return
+	} else if err != nil {
 		errE = errors.WithMessage(err, "failed to get GitLab project")
+		errors.Details(errE)["project"] = projectID
 		return
 	}
 
 	hasIssues = project.IssuesAccessLevel != gitlab.DisabledAccessControl
 	hasPackages = project.RepositoryAccessLevel != gitlab.DisabledAccessControl && project.PackagesEnabled
 	hasImages = project.ContainerRegistryAccessLevel != gitlab.DisabledAccessControl
+	webURL = project.WebURL
 	return
 }
 
-// projectMilestones fetches all milestone titles for a GitLab projectID project.
+// projectMilestones fetches titles of all milestones in state ("all", "active",
+// or "closed") for a GitLab projectID project.
 //
 // GitLab milestones are uniquely identified by their titles.
-func projectMilestones(client *gitlab.Client, projectID string) ([]string, errors.E) {
+func projectMilestones(client *gitlab.Client, projectID, state string, perPage int) ([]string, errors.E) {
 	milestones := []string{}
 	options := &gitlab.ListMilestonesOptions{ //nolint:exhaustruct
 		ListOptions: gitlab.ListOptions{
-			PerPage: maxGitLabPageSize,
+			PerPage: perPage,
 			Page:    1,
 		},
 	}
+	// GitLab lists milestones in all states when state is not provided.
+	if state != "" && state != "all" {
+		options.State = gitlab.String(state)
+	}
 	for {
 		page, response, err := client.Milestones.ListMilestones(projectID, options)
 		if err != nil {
@@ -236,24 +1211,66 @@ func projectMilestones(client *gitlab.Client, projectID string) ([]string, error
 	return milestones, nil
 }
 
-// packageFiles fetches all file names for a packageName/packageID package for GitLab projectID project.
-func packageFiles(client *gitlab.Client, projectID, packageName string, packageID int) ([]string, errors.E) {
+// createMissingMilestones creates a milestone for every release which has no milestone
+// associated in tagsToMilestones and associates the new milestone with the release.
+//
+// Milestones are titled after the release version (i.e., tag without "v" prefix).
+func createMissingMilestones(
+	client *gitlab.Client, projectID string, releases []Release, tagsToMilestones map[string][]string,
+) errors.E {
+	for _, release := range releases {
+		if len(tagsToMilestones[release.Tag]) > 0 {
+			continue
+		}
+
+		title := removeVPrefix(release.Tag)
+		fmt.Printf("Creating GitLab milestone \"%s\".\n", title)
+		milestone, _, err := client.Milestones.CreateMilestone(projectID, &gitlab.CreateMilestoneOptions{ //nolint:exhaustruct
+			Title: &title,
+		})
+		if err != nil {
+			errE := errors.WithMessage(err, "failed to create GitLab milestone")
+			errors.Details(errE)["milestone"] = title
+			return errE
+		}
+
+		tagsToMilestones[release.Tag] = []string{milestone.Title}
+	}
+	return nil
+}
+
+// packageFiles fetches all file names and their metadata for a packageName/packageID package
+// for GitLab projectID project.
+func packageFiles(
+	client *gitlab.Client, projectID, packageName string, packageID, perPage int,
+) ([]string, map[string]FileInfo, errors.E) {
 	files := []string{}
+	filesInfo := map[string]FileInfo{}
 	options := &gitlab.ListPackageFilesOptions{
-		PerPage: maxGitLabPageSize,
+		PerPage: perPage,
 		Page:    1,
 	}
+	u := fmt.Sprintf("projects/%s/packages/%d/package_files", gitlab.PathEscape(projectID), packageID)
 	for {
-		page, response, err := client.Packages.ListPackageFiles(projectID, packageID, options)
+		page := []packageFile{}
+		req, err := client.NewRequest(http.MethodGet, u, options, nil)
+		if err != nil {
+			return nil, nil, errors.WithStack(err)
+		}
+		response, err := client.Do(req, &page)
 		if err != nil {
 			errE := errors.WithMessage(err, "failed to list GitLab files for package")
 			errors.Details(errE)["package"] = packageName
 			errors.Details(errE)["page"] = options.Page
-			return nil, errE
+			return nil, nil, errE
 		}
 
 		for _, file := range page {
 			files = append(files, file.FileName)
+			filesInfo[file.FileName] = FileInfo{
+				Size:   file.Size,
+				SHA256: file.FileSHA256,
+			}
 		}
 
 		if response.NextPage == 0 {
@@ -262,15 +1279,18 @@ func packageFiles(client *gitlab.Client, projectID, packageName string, packageI
 
 		options.Page = response.NextPage
 	}
-	return files, nil
+	return files, filesInfo, nil
 }
 
 // projectPackages fetches all packages for GitLab projectID project.
-func projectPackages(client *gitlab.Client, projectID string) ([]Package, errors.E) {
+//
+// Files of generic packages are fetched concurrently, with at most concurrency
+// requests at the same time. Returned packages are sorted by their IDs.
+func projectPackages(client *gitlab.Client, projectID string, concurrency, perPage int) ([]Package, errors.E) {
 	packages := []Package{}
 	options := &gitlab.ListProjectPackagesOptions{ //nolint:exhaustruct
 		ListOptions: gitlab.ListOptions{
-			PerPage: maxGitLabPageSize,
+			PerPage: perPage,
 			Page:    1,
 		},
 	}
@@ -284,17 +1304,14 @@ func projectPackages(client *gitlab.Client, projectID string) ([]Package, errors
 
 		for _, p := range page {
 			if p.PackageType == "generic" {
-				files, err := packageFiles(client, projectID, p.Name, p.ID)
-				if err != nil {
-					return nil, err
-				}
 				packages = append(packages, Package{
 					ID:      p.ID,
 					Generic: true,
 					WebPath: p.Links.WebPath,
 					Name:    p.Name,
 					Version: p.Version,
-					Files:   files,
+					Files:   nil,
+					Project: projectID,
 				})
 			} else {
 				packages = append(packages, Package{
@@ -304,6 +1321,7 @@ func projectPackages(client *gitlab.Client, projectID string) ([]Package, errors
 					Name:    p.PackageType + "/" + p.Name,
 					Version: p.Version,
 					Files:   nil,
+					Project: projectID,
 				})
 			}
 		}
@@ -314,15 +1332,74 @@ func projectPackages(client *gitlab.Client, projectID string) ([]Package, errors
 
 		options.Page = response.NextPage
 	}
+
+	errE := fetchPackagesFiles(client, projectID, packages, concurrency, perPage)
+	if errE != nil {
+		return nil, errE
+	}
+
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].ID < packages[j].ID
+	})
+
 	return packages, nil
 }
 
+// fetchPackagesFiles fetches files for all generic packages in packages using a pool
+// of at most concurrency workers. It returns the first error encountered, if any.
+func fetchPackagesFiles(client *gitlab.Client, projectID string, packages []Package, concurrency, perPage int) errors.E {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var firstErr errors.E
+	semaphore := make(chan struct{}, concurrency)
+
+	for i := range packages {
+		if !packages[i].Generic {
+			continue
+		}
+
+		mu.Lock()
+		failed := firstErr != nil
+		mu.Unlock()
+		if failed {
+			break
+		}
+
+		semaphore <- struct{}{}
+		wg.Add(1)
+		go func(p *Package) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+
+			files, filesInfo, errE := packageFiles(client, projectID, p.Name, p.ID, perPage)
+			if errE != nil {
+				mu.Lock()
+				defer mu.Unlock()
+				if firstErr == nil {
+					firstErr = errE
+				}
+				return
+			}
+			// Each goroutine writes only to its own package.
+			p.Files = files
+			p.FilesInfo = filesInfo
+		}(&packages[i])
+	}
+
+	wg.Wait()
+	return firstErr
+}
+
 // projectImages fetches all Docker images for all Docker registries for GitLab projectID project.
-func projectImages(client *gitlab.Client, projectID string) ([]string, errors.E) {
+func projectImages(client *gitlab.Client, projectID string, perPage int) ([]string, errors.E) {
 	images := []string{}
 	options := &gitlab.ListRegistryRepositoriesOptions{
 		ListOptions: gitlab.ListOptions{
-			PerPage: maxGitLabPageSize,
+			PerPage: perPage,
 			Page:    1,
 		},
 		Tags:      gitlab.Bool(true),
@@ -352,14 +1429,14 @@ func projectImages(client *gitlab.Client, projectID string) ([]string, errors.E)
 }
 
 // releaseLinks fetches existing release links for the release for GitLab projectID project.
-func releaseLinks(client *gitlab.Client, projectID string, release Release) ([]link, errors.E) {
+func releaseLinks(client *gitlab.Client, projectID string, release Release, perPage int) ([]link, errors.E) {
 	links := []link{}
 	options := &gitlab.ListReleaseLinksOptions{
-		PerPage: maxGitLabPageSize,
+		PerPage: perPage,
 		Page:    1,
 	}
 	for {
-		page, response, err := client.ReleaseLinks.ListReleaseLinks(projectID, release.Tag, options)
+		page, response, err := client.ReleaseLinks.ListReleaseLinks(projectID, releaseGitTag(release), options)
 		if err != nil {
 			errE := errors.WithMessage(err, "failed to list GitLab release links for tag")
 			errors.Details(errE)["tag"] = release.Tag
@@ -371,10 +1448,12 @@ func releaseLinks(client *gitlab.Client, projectID string, release Release) ([]l
 			l := l
 
 			links = append(links, link{
-				Name:    l.Name,
-				ID:      &l.ID,
-				Package: nil,
-				File:    nil,
+				Name:     l.Name,
+				ID:       &l.ID,
+				Package:  nil,
+				File:     nil,
+				Asset:    nil,
+				Existing: l,
 			})
 		}
 
@@ -387,38 +1466,184 @@ func releaseLinks(client *gitlab.Client, projectID string, release Release) ([]l
 	return links, nil
 }
 
+// genericPackageFileURL returns the download URL for a file of the generic package p,
+// using GitLab API at apiURL. The package's own project is used, if known, otherwise projectID.
+func genericPackageFileURL(apiURL, projectID string, p *Package, file string) string {
+	if p.Project != "" {
+		projectID = p.Project
+	}
+	return fmt.Sprintf(
+		"%s/projects/%s/packages/generic/%s/%s/%s",
+		apiURL,
+		gitlab.PathEscape(projectID),
+		gitlab.PathEscape(p.Name),
+		gitlab.PathEscape(p.Version),
+		gitlab.PathEscape(file),
+	)
+}
+
 type linkOptions = interface {
-	gitlab.CreateReleaseLinkOptions | gitlab.ReleaseAssetLinkOptions
+	gitlab.CreateReleaseLinkOptions | gitlab.UpdateReleaseLinkOptions | gitlab.ReleaseAssetLinkOptions
+}
+
+// linkFilePath returns the file path of the link with the name, under the
+// link file path prefix from config, if it is set.
+func linkFilePath(config *Config, name string) string {
+	return path.Join("/", config.LinkFilepathPrefix, name)
+}
+
+// downloadAPIURL returns the URL of GitLab API used in links to download files of
+// generic packages, at the download base URL from config, if it is set.
+func downloadAPIURL(config *Config) string {
+	downloadBaseURL := config.BaseURL
+	if config.DownloadBaseURL != "" {
+		downloadBaseURL = config.DownloadBaseURL
+	}
+	// We remove trailing "/", if it exists.
+	return apiURL(strings.TrimSuffix(downloadBaseURL, "/"), config.APIPrefix)
 }
 
-func createReleaseLinkOptions[T linkOptions](baseURL, projectID, name string, l link) T { //nolint:ireturn
+// createReleaseLinkOptions returns options for the link l. The same options are
+// used when creating and updating links so that existing links do not change
+// when nothing else changes.
+func createReleaseLinkOptions[T linkOptions](config *Config, name string, l link) T { //nolint:ireturn
+	// We remove trailing "/", if it exists.
+	baseURL := strings.TrimSuffix(config.BaseURL, "/")
 	// TODO: We create one struct and cast it to T for now.
 	//       See: https://github.com/golang/go/issues/48522
 	options := gitlab.CreateReleaseLinkOptions{ //nolint:exhaustruct
 		Name: &name,
 	}
-	if l.File == nil {
+	if l.Asset != nil {
+		options.URL = gitlab.String(l.Asset.URL)
+		options.FilePath = nil
+		options.LinkType = gitlab.LinkType(gitlab.OtherLinkType)
+	} else if l.File == nil {
 		options.URL = gitlab.String(baseURL + l.Package.WebPath)
 		options.FilePath = nil
 		options.LinkType = gitlab.LinkType(gitlab.PackageLinkType)
 	} else {
-		url := fmt.Sprintf(
-			"%s/api/v4/projects/%s/packages/generic/%s/%s/%s",
-			baseURL,
-			gitlab.PathEscape(projectID),
-			gitlab.PathEscape(l.Package.Name),
-			gitlab.PathEscape(l.Package.Version),
-			gitlab.PathEscape(*l.File),
-		)
-		options.URL = &url
-		options.FilePath = gitlab.String("/" + name)
+		options.URL = gitlab.String(genericPackageFileURL(downloadAPIURL(config), config.Project, l.Package, *l.File))
+		options.FilePath = gitlab.String(linkFilePath(config, name))
 		options.LinkType = gitlab.LinkType(gitlab.OtherLinkType)
 	}
 	return T(options)
 }
 
-func getExpectedLinks(packages []Package) map[string]link {
+// linkNameData is the data available to the link name template.
+type linkNameData struct {
+	PackageName string
+	File        string
+	Version     string
+	// Size (in bytes) and SHA256 are known only for files of generic packages.
+	Size   int
+	SHA256 string
+}
+
+// defaultLinkNameTemplate names file links "<package name>/<file>" and package links "<package name>".
+const defaultLinkNameTemplate = "{{.PackageName}}{{if .File}}/{{.File}}{{end}}"
+
+// linkFileTooLarge returns true if the file of generic package p is larger than
+// the maximum link file size from config, if it is set. Such files are not linked.
+func linkFileTooLarge(config *Config, p *Package, file string) bool {
+	return config.MaxLinkFileSize > 0 && p.FilesInfo[file].Size > config.MaxLinkFileSize
+}
+
+// printExcludedLinkFiles prints files of generic packages mapped to releases which
+// are not linked because they are too large.
+func printExcludedLinkFiles(w io.Writer, config *Config, releases []Release, tagsToPackages map[string][]Package) {
+	for _, release := range releases {
+		for i := range tagsToPackages[release.Tag] {
+			p := &tagsToPackages[release.Tag][i]
+			if !p.Generic {
+				continue
+			}
+			for _, file := range p.Files {
+				if linkFileTooLarge(config, p, file) {
+					fmt.Fprintf(
+						w, "Not linking file \"%s\" of package \"%s\" for tag \"%s\": its size %d bytes is larger than %d bytes.\n",
+						file, p.Name, release.Tag, p.FilesInfo[file].Size, config.MaxLinkFileSize,
+					)
+				}
+			}
+		}
+	}
+}
+
+// linkSource describes what the expected link points to, for error messages.
+func linkSource(l link) string {
+	switch {
+	case l.Asset != nil:
+		return fmt.Sprintf("changelog asset %s", l.Asset.URL)
+	case l.Package != nil && l.File != nil:
+		return fmt.Sprintf("file %s of package %s %s", *l.File, l.Package.Name, l.Package.Version)
+	case l.Package != nil:
+		return fmt.Sprintf("package %s %s", l.Package.Name, l.Package.Version)
+	default:
+		return l.Name
+	}
+}
+
+// getExpectedLinks returns links for packages, keyed by link name.
+//
+// Link names are rendered using the link name template from config (or the default one).
+// Because links are matched with existing links by their names, names must be unique
+// and an error is returned if the template renders the same name for different links.
+func getExpectedLinks(config *Config, packages []Package, assets []Asset) (map[string]link, errors.E) {
+	source := config.LinkNameTemplate
+	if source == "" {
+		source = defaultLinkNameTemplate
+	}
+	tmpl, err := template.New("link").Parse(source)
+	if err != nil {
+		return nil, errors.WithMessage(err, "cannot parse link name template")
+	}
+
 	expectedLinks := map[string]link{}
+	add := func(p *Package, file *string) errors.E {
+		data := linkNameData{
+			PackageName: p.Name,
+			File:        "",
+			Version:     p.Version,
+			Size:        0,
+			SHA256:      "",
+		}
+		if file != nil {
+			data.File = *file
+			data.Size = p.FilesInfo[*file].Size
+			data.SHA256 = p.FilesInfo[*file].SHA256
+		}
+		var name strings.Builder
+		err := tmpl.Execute(&name, data)
+		if err != nil {
+			errE := errors.WithMessage(err, "cannot render link name template")
+			errors.Details(errE)["package"] = p.Name
+			return errE
+		}
+		if name.Len() == 0 {
+			errE := errors.New("link name template rendered an empty name")
+			errors.Details(errE)["package"] = p.Name
+			return errE
+		}
+		l := link{
+			Name:     name.String(),
+			ID:       nil,
+			Package:  p,
+			File:     file,
+			Asset:    nil,
+			Existing: nil,
+		}
+		if existing, ok := expectedLinks[l.Name]; ok {
+			errE := errors.New("link name template rendered a duplicate name")
+			errors.Details(errE)["link"] = l.Name
+			errors.Details(errE)["package"] = p.Name
+			errors.Details(errE)["sources"] = []string{linkSource(existing), linkSource(l)}
+			return errE
+		}
+		expectedLinks[l.Name] = l
+		return nil
+	}
+
 	for i := range packages {
 		// We create our own p because later on we take an address of p
 		// and we do not want to have an implicit memory aliasing in for loop.
@@ -428,238 +1653,304 @@ func getExpectedLinks(packages []Package) map[string]link {
 				// We create our own file because later on we take an address of file
 				// and we do not want to have an implicit memory aliasing in for loop.
 				file := p.Files[j]
-				name := p.Name + "/" + file
-				expectedLinks[name] = link{
-					Name:    name,
-					ID:      nil,
-					Package: &p,
-					File:    &file,
+				if linkFileTooLarge(config, &p, file) {
+					continue
+				}
+				errE := add(&p, &file)
+				if errE != nil {
+					return nil, errE
 				}
 			}
 		} else {
-			expectedLinks[p.Name] = link{
-				Name:    p.Name,
-				ID:      nil,
-				Package: &p,
-				File:    nil,
+			errE := add(&p, nil)
+			if errE != nil {
+				return nil, errE
 			}
 		}
 	}
-	return expectedLinks
+	for i := range assets {
+		asset := assets[i]
+		l := link{
+			Name:     asset.Name,
+			ID:       nil,
+			Package:  nil,
+			File:     nil,
+			Asset:    &asset,
+			Existing: nil,
+		}
+		if existing, ok := expectedLinks[l.Name]; ok {
+			errE := errors.New("changelog asset has a duplicate link name")
+			errors.Details(errE)["link"] = l.Name
+			errors.Details(errE)["sources"] = []string{linkSource(existing), linkSource(l)}
+			return nil, errE
+		}
+		expectedLinks[l.Name] = l
+	}
+	return expectedLinks, nil
 }
 
-// syncLinks updates release links for the release for GitLab projectID project to match those provided in packages.
+// defaultLinkGroups orders links to source archives first, then links to binaries
+// (all other files), then links to checksums and signatures.
+var defaultLinkGroups = []string{ //nolint:gochecknoglobals
+	"*-src.*,*_src.*,*.src.*,*-source.*,*_source.*,source.*,src.*",
+	"*",
+	"*.md5,*.sha1,*.sha256,*.sha512,*SUMS,*sums,*SUMS.*,*sums.*,*.sig,*.asc,*.minisig,*.pem,*.crt",
+}
+
+// linkGroupIndex returns the index of the group into which a link with name belongs.
+// Each group is a comma-separated list of glob patterns matched against the name.
 //
-// For generic packages it makes links to all files for all generic packages. For non-generic packages it makes link
-// to each package's web page.
-func syncLinks(client *gitlab.Client, baseURL, projectID string, release Release, packages []Package) errors.E {
-	// We remove trailing "/", if it exists.
-	baseURL = strings.TrimSuffix(baseURL, "/")
-	links, err := releaseLinks(client, projectID, release)
-	if err != nil {
-		return err
+// A link belongs to the first group with a matching pattern. A group with "*" pattern
+// is a catch-all group for links which do not match any other group. Links which do
+// not belong to any group are put after all groups.
+func linkGroupIndex(name string, groups []string) int {
+	catchAll := len(groups)
+	for i, group := range groups {
+		for _, pattern := range strings.Split(group, ",") {
+			pattern = strings.TrimSpace(pattern)
+			if pattern == "*" {
+				if catchAll == len(groups) {
+					catchAll = i
+				}
+				continue
+			}
+			if ok, _ := path.Match(pattern, name); ok {
+				return i
+			}
+		}
+	}
+	return catchAll
+}
+
+// sortLinks sorts links by their groups and by name inside each group. Groups are
+// matched against file names for links to files and link names for other links.
+func sortLinks(links []link, groups []string) {
+	index := func(l link) int {
+		if l.File != nil {
+			return linkGroupIndex(*l.File, groups)
+		}
+		return linkGroupIndex(l.Name, groups)
+	}
+	sort.SliceStable(links, func(i, j int) bool {
+		gi, gj := index(links[i]), index(links[j])
+		if gi != gj {
+			return gi < gj
+		}
+		return links[i].Name < links[j].Name
+	})
+}
+
+// linkGroups returns link groups from config or the default ones.
+func linkGroups(config *Config) []string {
+	if len(config.LinkGroups) > 0 {
+		return config.LinkGroups
+	}
+	return defaultLinkGroups
+}
+
+// linksDiff describes changes needed to make existing release links match expected links.
+// Keep are existing links which already match expected links.
+type linksDiff struct {
+	Delete []link
+	Update []link
+	Keep   []link
+	Create []link
+}
+
+// linkChanged returns true if the existing link differs from the expected link
+// in its URL, file path, or link type.
+func linkChanged(config *Config, existing, expected link) bool {
+	if existing.Existing == nil {
+		return true
+	}
+	options := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, expected.Name, expected)
+	if existing.Existing.URL != *options.URL {
+		return true
+	}
+	linkType := existing.Existing.LinkType
+	if linkType == "" {
+		// This is GitLab's default link type.
+		linkType = gitlab.OtherLinkType
+	}
+	if linkType != *options.LinkType {
+		return true
+	}
+	// GitLab API does not return the file path of the link, but the direct asset URL
+	// ends with it. Without the file path, the direct asset URL is the link's URL.
+	if options.FilePath == nil {
+		return existing.Existing.DirectAssetURL != existing.Existing.URL
 	}
+	return !strings.HasSuffix(existing.Existing.DirectAssetURL, "/downloads"+*options.FilePath)
+}
+
+// diffLinks computes which existing links have to be deleted, updated, or created
+// to match expected links. Existing links which do not differ from expected links
+// are kept. Links to update or keep have ID set from the existing link.
+// All lists are sorted by link name.
+func diffLinks(config *Config, existing []link, expectedLinks map[string]link) linksDiff {
+	diff := linksDiff{
+		Delete: []link{},
+		Update: []link{},
+		Keep:   []link{},
+		Create: []link{},
+	}
+
 	existingLinks := map[string]link{}
-	for _, l := range links {
+	for _, l := range existing {
 		existingLinks[l.Name] = l
 	}
-	expectedLinks := getExpectedLinks(packages)
 
 	for name, l := range existingLinks {
 		_, ok := expectedLinks[name]
 		if !ok {
-			fmt.Printf("Deleting GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
-			_, _, err := client.ReleaseLinks.DeleteReleaseLink(projectID, release.Tag, *l.ID)
-			if err != nil {
-				errE := errors.WithMessage(err, "failed to delete GitLab link")
-				errors.Details(errE)["link"] = l.Name
-				errors.Details(errE)["release"] = release.Tag
-				return errE
-			}
+			diff.Delete = append(diff.Delete, l)
 		}
 	}
 
 	for name, l := range expectedLinks {
 		existingLink, ok := existingLinks[name]
-		if ok {
-			fmt.Printf("Updating GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
-			options := &gitlab.UpdateReleaseLinkOptions{ //nolint:exhaustruct
-				Name: &name,
-			}
-			if l.File == nil {
-				options.URL = gitlab.String(baseURL + l.Package.WebPath)
-				options.FilePath = nil
-				options.LinkType = gitlab.LinkType(gitlab.PackageLinkType)
-			} else {
-				url := fmt.Sprintf(
-					"%s/api/v4/projects/%s/packages/generic/%s/%s/%s",
-					baseURL,
-					gitlab.PathEscape(projectID),
-					gitlab.PathEscape(l.Package.Name),
-					gitlab.PathEscape(l.Package.Version),
-					gitlab.PathEscape(*l.File),
-				)
-				options.URL = &url
-				options.FilePath = gitlab.String("/" + name)
-				options.LinkType = gitlab.LinkType(gitlab.OtherLinkType)
-			}
-			_, _, err := client.ReleaseLinks.UpdateReleaseLink(projectID, release.Tag, *existingLink.ID, options)
-			if err != nil {
-				errE := errors.WithMessage(err, "failed to update GitLab link")
-				errors.Details(errE)["link"] = l.Name
-				errors.Details(errE)["release"] = release.Tag
-				return errE
-			}
+		if !ok {
+			diff.Create = append(diff.Create, l)
+		} else if linkChanged(config, existingLink, l) {
+			l.ID = existingLink.ID
+			diff.Update = append(diff.Update, l)
 		} else {
-			fmt.Printf("Creating GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
-			options := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](baseURL, projectID, name, l)
-			_, _, err := client.ReleaseLinks.CreateReleaseLink(projectID, release.Tag, &options)
-			if err != nil {
-				errE := errors.WithMessage(err, "failed to create GitLab link")
-				errors.Details(errE)["link"] = l.Name
-				errors.Details(errE)["release"] = release.Tag
-				return errE
-			}
+			l.ID = existingLink.ID
+			diff.Keep = append(diff.Keep, l)
 		}
 	}
 
-	return nil
-}
-
-// Upsert creates or updates a release for the GitLab project given release information,
-// milestones associated with the release, packages associated with the release, and
-// Docker images associated with the release.
-func Upsert(
-	config *Config, client *gitlab.Client, release Release, releasedAt *time.Time,
-	milestones []string, packages []Package, images []string,
-) errors.E {
-	name := release.Tag
-	if release.Yanked {
-		name += " [YANKED]"
+	for _, links := range [][]link{diff.Delete, diff.Update, diff.Keep, diff.Create} {
+		sort.Slice(links, func(i, j int) bool {
+			return links[i].Name < links[j].Name
+		})
 	}
 
-	description := "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\n"
+	return diff
+}
 
-	// TODO: Improve with official links to Docker images, once they are available.
-	//       See: https://gitlab.com/gitlab-org/gitlab/-/issues/346982
-	if len(images) > 0 {
-		description += "##### Docker images\n"
-		for _, image := range images {
-			description += "* `" + image + "`\n"
-		}
-		description += "\n"
+// orderLinks changes diff so that, after it is applied, links are in the order of
+// link groups. GitLab API does not support setting positions of links and lists
+// them in the order they are created, so existing links which are out of order
+// are deleted and created again, after links before them. Create is sorted in the
+// order in which links have to be created.
+func orderLinks(diff linksDiff, groups []string) linksDiff {
+	expected := make([]link, 0, len(diff.Update)+len(diff.Keep)+len(diff.Create))
+	expected = append(expected, diff.Update...)
+	expected = append(expected, diff.Keep...)
+	expected = append(expected, diff.Create...)
+	sortLinks(expected, groups)
+
+	keep := mapset.NewThreadUnsafeSet[string]()
+	for _, l := range diff.Keep {
+		keep.Add(l.Name)
 	}
 
-	description += release.Changes
-
-	rel, response, err := client.Releases.GetRelease(config.Project, release.Tag)
-	if response.StatusCode == http.StatusNotFound {
-		if config.NoCreate {
-			fmt.Printf("GitLab release for tag \"%s\" is missing, but not creating it per config.\n", release.Tag)
-			return nil
-		}
+	// Existing links in the order they were created.
+	existing := make([]link, 0, len(diff.Update)+len(diff.Keep))
+	existing = append(existing, diff.Update...)
+	existing = append(existing, diff.Keep...)
+	sort.SliceStable(existing, func(i, j int) bool {
+		return *existing[i].ID < *existing[j].ID
+	})
 
-		links := []*gitlab.ReleaseAssetLinkOptions{}
-		for name, l := range getExpectedLinks(packages) {
-			options := createReleaseLinkOptions[gitlab.ReleaseAssetLinkOptions](config.BaseURL, config.Project, name, l)
-			links = append(links, &options)
-		}
+	// Existing links which are already in order are kept.
+	kept := 0
+	for kept < len(existing) && existing[kept].Name == expected[kept].Name {
+		kept++
+	}
 
-		// Do not provide ReleasedAt field if the release has been done recently.
-		// This prevents GitLab from marking the release as a historical release.
-		if time.Since(*releasedAt).Abs() < 12*time.Hour {
-			releasedAt = nil
+	result := linksDiff{
+		Delete: slices.Clone(diff.Delete),
+		Update: []link{},
+		Keep:   []link{},
+		Create: []link{},
+	}
+	result.Delete = append(result.Delete, existing[kept:]...)
+	for _, l := range existing[:kept] {
+		if keep.Contains(l.Name) {
+			result.Keep = append(result.Keep, l)
+		} else {
+			result.Update = append(result.Update, l)
 		}
-
-		fmt.Printf("Creating GitLab release for tag \"%s\".\n", release.Tag)
-		_, _, err = client.Releases.CreateRelease(config.Project, &gitlab.CreateReleaseOptions{
-			Name:        &name,
-			TagName:     &release.Tag,
-			TagMessage:  nil,
-			Description: &description,
-			Ref:         nil,
-			Milestones:  &milestones,
-			Assets: &gitlab.ReleaseAssetsOptions{
-				Links: links,
-			},
-			ReleasedAt: releasedAt,
+	}
+	for _, links := range [][]link{result.Delete, result.Update, result.Keep} {
+		sort.Slice(links, func(i, j int) bool {
+			return links[i].Name < links[j].Name
 		})
-		if err != nil {
-			errE := errors.WithMessage(err, "failed to create GitLab release for tag")
-			errors.Details(errE)["tag"] = release.Tag
-			return errE
-		}
-		return nil
-	} else if err != nil {
-		errE := errors.WithMessage(err, "failed to get GitLab release for tag")
-		errors.Details(errE)["tag"] = release.Tag
-		return errE
 	}
-
-	// If GitLab release was made close to releasedAt, we set the releasedAt to CreatedAt
-	// to make sure that the release is not marked as a historical release.
-	if rel.CreatedAt.Sub(*releasedAt).Abs() < 12*time.Hour {
-		releasedAt = rel.CreatedAt
+	for _, l := range expected[kept:] {
+		l.ID = nil
+		result.Create = append(result.Create, l)
 	}
+	return result
+}
 
-	fmt.Printf("Updating GitLab release for tag \"%s\".\n", release.Tag)
-	_, _, err = client.Releases.UpdateRelease(config.Project, release.Tag, &gitlab.UpdateReleaseOptions{
-		Name:        &name,
-		Description: &description,
-		ReleasedAt:  releasedAt,
-		Milestones:  &milestones,
-	})
-	if err != nil {
-		errE := errors.WithMessage(err, "failed to update GitLab release for tag")
-		errors.Details(errE)["tag"] = release.Tag
-		return errE
+// diffLinksForConfig computes diff between existing and expected links,
+// ordering links as configured.
+func diffLinksForConfig(config *Config, existing []link, expectedLinks map[string]link) linksDiff {
+	diff := diffLinks(config, existing, expectedLinks)
+	if config.LinkOrder {
+		return orderLinks(diff, linkGroups(config))
 	}
-
-	return syncLinks(client, config.BaseURL, config.Project, release, packages)
+	// GitLab lists links in the order they are created.
+	sortLinks(diff.Create, linkGroups(config))
+	return diff
 }
 
-// DeleteAllExcept deletes all releases which exist in the GitLab project but
-// are not listed in releases.
-func DeleteAllExcept(config *Config, client *gitlab.Client, releases []Release) errors.E {
-	allReleases := mapset.NewThreadUnsafeSet[string]()
-	for _, release := range releases {
-		allReleases.Add(release.Tag)
+// syncLinks updates release links for the release for GitLab project to match those provided in packages.
+// Existing links are fetched with readClient.
+//
+// For generic packages it makes links to all files for all generic packages. For non-generic packages it makes link
+// to each package's web page.
+func syncLinks(config *Config, client, readClient *gitlab.Client, release Release, packages []Package) errors.E {
+	projectID := config.Project
+	links, errE := releaseLinks(readClient, projectID, release, pageSize(config))
+	if errE != nil {
+		return errE
 	}
-
-	allGitLabReleases := mapset.NewThreadUnsafeSet[string]()
-	options := &gitlab.ListReleasesOptions{ //nolint:exhaustruct
-		ListOptions: gitlab.ListOptions{
-			PerPage: maxGitLabPageSize,
-			Page:    1,
-		},
+	expectedLinks, errE := getExpectedLinks(config, packages, releaseAssets(config, release))
+	if errE != nil {
+		return errE
 	}
-	for {
-		page, response, err := client.Releases.ListReleases(config.Project, options)
+	diff := diffLinksForConfig(config, links, expectedLinks)
+
+	for _, l := range diff.Delete {
+		fmt.Printf("Deleting GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
+		_, _, err := client.ReleaseLinks.DeleteReleaseLink(projectID, releaseGitTag(release), *l.ID)
 		if err != nil {
-			errE := errors.WithMessage(err, "failed to list GitLab releases")
-			errors.Details(errE)["page"] = options.Page
+			errE := errors.WithMessage(err, "failed to delete GitLab link")
+			errors.Details(errE)["link"] = l.Name
+			errors.Details(errE)["release"] = release.Tag
 			return errE
 		}
+	}
 
-		for _, release := range page {
-			allGitLabReleases.Add(release.TagName)
-		}
-
-		if response.NextPage == 0 {
-			break
+	for _, l := range diff.Update {
+		fmt.Printf("Updating GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
+		options := createReleaseLinkOptions[gitlab.UpdateReleaseLinkOptions](config, l.Name, l)
+		_, _, err := client.ReleaseLinks.UpdateReleaseLink(projectID, releaseGitTag(release), *l.ID, &options)
+		if err != nil {
+			errE := errors.WithMessage(err, "failed to update GitLab link")
+			errors.Details(errE)["link"] = l.Name
+			errors.Details(errE)["release"] = release.Tag
+			return errE
 		}
-
-		options.Page = response.NextPage
 	}
 
-	extraGitLabReleases := allGitLabReleases.Difference(allReleases).ToSlice()
-	slices.Sort(extraGitLabReleases)
-	for _, tag := range extraGitLabReleases {
-		fmt.Printf("Deleting GitLab release for tag \"%s\".\n", tag)
-		_, _, err := client.Releases.DeleteRelease(config.Project, tag)
+	return createLinks(config, client, release, diff.Create)
+}
+
+// createLinks creates links for the release for GitLab project, in the order given.
+func createLinks(config *Config, client *gitlab.Client, release Release, links []link) errors.E {
+	for _, l := range links {
+		fmt.Printf("Creating GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
+		options := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, l.Name, l)
+		_, _, err := client.ReleaseLinks.CreateReleaseLink(config.Project, releaseGitTag(release), &options)
 		if err != nil {
-			errE := errors.WithMessage(err, "failed to delete GitLab release for tag")
-			errors.Details(errE)["tag"] = tag
+			errE := errors.WithMessage(err, "failed to create GitLab link")
+			errors.Details(errE)["link"] = l.Name
+			errors.Details(errE)["release"] = release.Tag
 			return errE
 		}
 	}
@@ -667,223 +1958,1827 @@ func DeleteAllExcept(config *Config, client *gitlab.Client, releases []Release)
 	return nil
 }
 
-// noChange is an identify function for strings.
-func noChange(s string) string {
-	return s
-}
+// createMissingLinks creates only those expected links for the release for GitLab project
+// which do not yet exist. Existing links are fetched with readClient and are not changed.
+func createMissingLinks(config *Config, client, readClient *gitlab.Client, release Release, packages []Package) errors.E {
+	links, errE := releaseLinks(readClient, config.Project, release, pageSize(config))
+	if errE != nil {
+		return errE
+	}
+	expectedLinks, errE := getExpectedLinks(config, packages, releaseAssets(config, release))
+	if errE != nil {
+		return errE
+	}
+	missing := diffLinks(config, links, expectedLinks).Create
+	// GitLab lists links in the order they are created.
+	sortLinks(missing, linkGroups(config))
 
-// removeVPrefix removes "v" from the beginning of the string.
-func removeVPrefix(s string) string {
-	return strings.TrimPrefix(s, "v")
+	return createLinks(config, client, release, missing)
 }
 
-// slugify makes a slug from the string, matching what is used in GitLab.
-// See: https://gitlab.com/gitlab-org/gitlab/-/blob/c61e4166/lib/gitlab/utils.rb#L73-84
-func slugify(s string) string {
-	return refSlug(s)
+// descriptionData is the data available to the description template.
+type descriptionData struct {
+	Tag      string
+	Version  string
+	Changes  string
+	Images   []string
+	Meta     map[string]interface{}
+	Category string
 }
 
-// removeVPrefixAndSlugify combines removeVPrefix and refSlug.
-func removeVPrefixAndSlugify(s string) string {
-	return refSlug(removeVPrefix(s))
-}
+// descriptionMarker marks release descriptions generated by this tool.
+const descriptionMarker = "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->"
 
-var tagTransformations = []func(string) string{noChange, removeVPrefix, slugify, removeVPrefixAndSlugify} //nolint:gochecknoglobals
+// latestBadge is shown at the top of the description of the latest release.
+const latestBadge = "⭐ Latest release"
 
-// mapStringsToTags attempts to map input strings to releases' tags by searching for
-// each release's tag (i.e., version with "v" prefix) or version (i.e., tag without
-// "v" prefix) in strings and those which match are associated with the tag/version.
-//
-// It starts with the longest tags so that more specific tags are mapped first.
-// This makes string "1.0.0-rc" be mapped to tag "1.0.0-rc" if such a tag exist
-// together with the "1.0.0" tag. On the other hand, if only "1.0.0" tag exists,
-// then "1.0.0-rc" is mapped to "1.0.0".
-func mapStringsToTags(inputs []string, releases []Release) map[string][]string {
-	tagsToInputs := map[string][]string{}
+// releaseDescription renders the description of the release using the description template
+// from config (or the default one) and prepends it with a marker that the description is
+// generated by this tool.
+func releaseDescription(config *Config, release Release, images []string) (string, errors.E) {
+	source := config.DescriptionTemplate
+	if source == "" {
+		source = defaultDescriptionTemplate
+	}
+	tmpl, err := template.New("description").Parse(source)
+	if err != nil {
+		return "", errors.WithMessage(err, "cannot parse description template")
+	}
 
-	tags := make([]string, len(releases))
-	for i := 0; i < len(releases); i++ {
-		tags[i] = releases[i].Tag
+	changes := release.Changes
+	if config.NormalizeMarkdown {
+		changes = normalizeMarkdown(changes, release.References)
 	}
 
-	// First we do a regular sort, so that we get deterministic results later on.
-	sort.Stable(sort.StringSlice(tags))
-	sort.Stable(sort.StringSlice(inputs))
-	// Then we sort by length, so that we can map longer tag names first
-	// (e.g., 1.0.0-rc before 1.0.0).
-	sort.SliceStable(tags, func(i, j int) bool {
-		return len(tags[i]) > len(tags[j])
+	var rendered strings.Builder
+	err = tmpl.Execute(&rendered, descriptionData{
+		Tag:      release.Tag,
+		Version:  removeVPrefix(release.Tag),
+		Changes:  changes,
+		Images:   images,
+		Meta:     release.Meta,
+		Category: release.Category,
 	})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot render description template")
+		errors.Details(errE)["tag"] = release.Tag
+		return "", errE
+	}
 
-	assignedInputs := mapset.NewThreadUnsafeSet[string]()
-	for _, transformation := range tagTransformations {
-		for _, tag := range tags {
-			t := transformation(tag)
+	// The header follows the marker, so that the marker always comes first.
+	marker := descriptionMarker + "\n\n"
+	description := ""
+	if config.LatestBadge && release.Latest {
+		description += latestBadge + "\n\n"
+	}
+	if header := strings.TrimSpace(config.DescriptionHeader); header != "" {
+		description += header + "\n\n"
+	}
+	description += trimBlankLines(rendered.String(), !config.KeepBlankLines)
+	if section := issuesSection(release.Issues); section != "" {
+		if description = strings.TrimRight(description, "\n"); description != "" {
+			description += "\n\n"
+		}
+		description += section
+	}
 
-			for _, input := range inputs {
-				if assignedInputs.Contains(input) {
-					continue
-				}
+	// The footer is appended after any truncation, so that it is always present.
+	footer := ""
+	if f := strings.TrimSpace(config.DescriptionFooter); f != "" {
+		footer = "\n\n" + f
+	}
 
-				if strings.Contains(input, t) {
-					if tagsToInputs[tag] == nil {
-						tagsToInputs[tag] = []string{}
-					}
-					tagsToInputs[tag] = append(tagsToInputs[tag], input)
-					assignedInputs.Add(input)
-				}
-			}
+	suffix := "\n\n… (truncated, see full changelog)"
+	if url, ok := release.References[strings.ToLower(removeVPrefix(release.Tag))]; ok {
+		suffix = "\n\n… (truncated, see [full changelog](" + url + "))"
+	}
+	if config.MaxDescriptionLength > 0 {
+		// The marker is never truncated, so that the release is still recognized
+		// as generated by this tool (e.g., with --only-managed).
+		maxLength := config.MaxDescriptionLength - utf8.RuneCountInString(marker) - utf8.RuneCountInString(footer)
+		if maxLength < 0 {
+			maxLength = 0
+		}
+		description = truncateMarkdown(description, maxLength, suffix)
+		if description == "" {
+			// There is no room left for anything but the marker.
+			marker = descriptionMarker
 		}
 	}
 
-	return tagsToInputs
+	return marker + description + footer, nil
 }
 
-// mapMilestonesToTags maps provided milestones to releases' tags.
-func mapMilestonesToTags(milestones []string, releases []Release) map[string][]string {
-	return mapStringsToTags(milestones, releases)
+// nameData is the data available to the release name template.
+type nameData struct {
+	Tag      string
+	Version  string
+	Title    string
+	Yanked   bool
+	Category string
 }
 
-// mapMilestonesToTags maps provided packages to releases' tags.
-//
-// Packages are mapped based on their version string.
-func mapPackagesToTags(packages []Package, releases []Release) map[string][]Package {
-	tagsToPackages := map[string][]Package{}
-
-	tags := make([]string, len(releases))
-	for i := 0; i < len(releases); i++ {
-		tags[i] = releases[i].Tag
+// releaseName renders the name of the release using the name template
+// from config (or the default one).
+func releaseName(config *Config, release Release) (string, errors.E) {
+	source := config.NameTemplate
+	if source == "" {
+		source = defaultNameTemplate
+	}
+	tmpl, err := template.New("name").Parse(source)
+	if err != nil {
+		return "", errors.WithMessage(err, "cannot parse name template")
 	}
 
-	// First we do a regular sort, so that we get deterministic results later on.
-	sort.Stable(sort.StringSlice(tags))
-	sort.SliceStable(packages, func(i, j int) bool {
-		return packages[i].Version < packages[j].Version
-	})
-	// Then we sort by length, so that we can map longer tag names first
-	// (e.g., 1.0.0-rc before 1.0.0).
-	sort.SliceStable(tags, func(i, j int) bool {
-		return len(tags[i]) > len(tags[j])
+	var name strings.Builder
+	err = tmpl.Execute(&name, nameData{
+		Tag:      release.Tag,
+		Version:  removeVPrefix(release.Tag),
+		Title:    release.Title,
+		Yanked:   release.Yanked,
+		Category: release.Category,
 	})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot render name template")
+		errors.Details(errE)["tag"] = release.Tag
+		return "", errE
+	}
+	if name.Len() == 0 {
+		errE := errors.New("name template rendered an empty name")
+		errors.Details(errE)["tag"] = release.Tag
+		return "", errE
+	}
 
-	assignedPackages := mapset.NewThreadUnsafeSet[int]()
-	for _, transformation := range tagTransformations {
-		for _, tag := range tags {
-			t := transformation(tag)
+	return name.String(), nil
+}
 
-			for _, p := range packages {
-				if assignedPackages.Contains(p.ID) {
-					continue
-				}
+// releaseTagMessage renders the message of the annotated tag GitLab creates for
+// the release using the tag message template from config. By default, it is
+// the release name.
+func releaseTagMessage(config *Config, release Release) (string, errors.E) {
+	if config.TagMessageTemplate == "" {
+		return releaseName(config, release)
+	}
+	tmpl, err := template.New("tagMessage").Parse(config.TagMessageTemplate)
+	if err != nil {
+		return "", errors.WithMessage(err, "cannot parse tag message template")
+	}
 
-				if strings.Contains(p.Version, t) {
-					if tagsToPackages[tag] == nil {
-						tagsToPackages[tag] = []Package{}
-					}
-					tagsToPackages[tag] = append(tagsToPackages[tag], p)
-					assignedPackages.Add(p.ID)
-				}
-			}
-		}
+	var message strings.Builder
+	err = tmpl.Execute(&message, nameData{
+		Tag:      release.Tag,
+		Version:  removeVPrefix(release.Tag),
+		Title:    release.Title,
+		Yanked:   release.Yanked,
+		Category: release.Category,
+	})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot render tag message template")
+		errors.Details(errE)["tag"] = release.Tag
+		return "", errE
 	}
 
-	return tagsToPackages
+	return message.String(), nil
 }
 
-// mapMilestonesToTags maps provided Docker images to releases' tags.
-func mapImagesToTags(images []string, releases []Release) map[string][]string {
-	return mapStringsToTags(images, releases)
+// releasePackages returns packages to associate with the release. No packages
+// are associated with yanked releases if so configured, so any existing links are removed.
+func releasePackages(config *Config, release Release, packages []Package) []Package {
+	if release.Yanked && config.AssetsExcludeYanked {
+		return nil
+	}
+	return packages
 }
 
-func mapTagsToDates(tags []Tag) map[string]*time.Time {
-	tagsToDates := map[string]*time.Time{}
-	for _, tag := range tags {
-		tag := tag
-		tagsToDates[tag.Name] = &tag.Date
+// releaseAssets returns changelog assets to associate with the release. No assets
+// are associated with yanked releases if so configured, so any existing links are removed.
+func releaseAssets(config *Config, release Release) []Asset {
+	if release.Yanked && config.AssetsExcludeYanked {
+		return nil
 	}
-	return tagsToDates
+	return release.Assets
 }
 
-// Sync syncs tags in a git repository and a changelog in Keep a Changelog format with
+// createRelease creates GitLab release for the release, together with its links.
+func createRelease(
+	config *Config, client *gitlab.Client, release Release, name, description string,
+	releasedAt *time.Time, milestones []string, packages []Package,
+) (*gitlab.Response, error) {
+	expectedLinks, errE := getExpectedLinks(config, packages, releaseAssets(config, release))
+	if errE != nil {
+		return nil, errE
+	}
+	sortedLinks := make([]link, 0, len(expectedLinks))
+	for _, l := range expectedLinks {
+		sortedLinks = append(sortedLinks, l)
+	}
+	sortLinks(sortedLinks, linkGroups(config))
+	links := []*gitlab.ReleaseAssetLinkOptions{}
+	for _, l := range sortedLinks {
+		options := createReleaseLinkOptions[gitlab.ReleaseAssetLinkOptions](config, l.Name, l)
+		links = append(links, &options)
+	}
+
+	// Do not provide ReleasedAt field if the release has been done recently.
+	// This prevents GitLab from marking the release as a historical release.
+	createReleasedAt := releasedAt
+	if recentlyReleased(*releasedAt, time.Now()) {
+		createReleasedAt = nil
+	}
+
+	// When Ref is set, GitLab creates the tag from it, if the tag is missing in the project.
+	var ref *string
+	var tagMessage *string
+	if release.Ref != "" {
+		ref = &release.Ref
+		message, errE := releaseTagMessage(config, release)
+		if errE != nil {
+			return nil, errE
+		}
+		tagMessage = &message
+	}
+
+	tagName := releaseGitTag(release)
+	fmt.Printf("Creating GitLab release for tag \"%s\".\n", release.Tag)
+	_, response, err := client.Releases.CreateRelease(config.Project, &gitlab.CreateReleaseOptions{
+		Name:        &name,
+		TagName:     &tagName,
+		TagMessage:  tagMessage,
+		Description: &description,
+		Ref:         ref,
+		Milestones:  &milestones,
+		Assets: &gitlab.ReleaseAssetsOptions{
+			Links: links,
+		},
+		ReleasedAt: createReleasedAt,
+	})
+	return response, err //nolint:wrapcheck
+}
+
+// releaseExists returns true if creating a release failed because the release already exists.
+// GitLab responds with 409 Conflict, but some versions respond with 400 Bad Request or
+// 422 Unprocessable Entity with a message that the release already exists.
+func releaseExists(response *gitlab.Response, err error) bool {
+	if response == nil || err == nil {
+		return false
+	}
+	switch response.StatusCode {
+	case http.StatusConflict:
+		return true
+	case http.StatusBadRequest, http.StatusUnprocessableEntity:
+		var errorResponse *gitlab.ErrorResponse
+		return errors.As(err, &errorResponse) && strings.Contains(strings.ToLower(errorResponse.Message), "already exists")
+	default:
+		return false
+	}
+}
+
+// defaultCreateConflictRetries is the default number of times a release which
+// already exists when creating it is fetched again to be updated.
+const defaultCreateConflictRetries = 3
+
+// createConflictRetries returns the number of retries for releases which already
+// exist when creating them from config, or the default one.
+func createConflictRetries(config *Config) int {
+	if config.CreateConflictRetries < 1 {
+		return defaultCreateConflictRetries
+	}
+	return config.CreateConflictRetries
+}
+
+// Upsert creates or updates a release for the GitLab project given release information,
+// milestones associated with the release, packages associated with the release, and
+// Docker images associated with the release. The existing release is fetched with readClient,
+// which can be the same as client.
+func Upsert(
+	config *Config, client, readClient *gitlab.Client, release Release, releasedAt *time.Time,
+	milestones []string, packages []Package, images []string,
+) errors.E {
+	name, errE := releaseName(config, release)
+	if errE != nil {
+		return errE
+	}
+
+	packages = releasePackages(config, release, packages)
+
+	description, errE := releaseDescription(config, release, images)
+	if errE != nil {
+		return errE
+	}
+
+	var rel *gitlab.Release
+	for attempt := 0; ; attempt++ {
+		var response *gitlab.Response
+		var err error
+		rel, response, err = readClient.Releases.GetRelease(config.Project, releaseGitTag(release))
+		if response != nil && response.StatusCode == http.StatusNotFound {
+			if config.NoCreate {
+				fmt.Printf("GitLab release for tag \"%s\" is missing, but not creating it per config.\n", release.Tag)
+				return nil
+			}
+
+			response, err = createRelease(config, client, release, name, description, releasedAt, milestones, packages)
+			if releaseExists(response, err) {
+				// The release has been created in the meantime (e.g., by another process syncing
+				// concurrently or by a previous run which failed before finishing), so we update
+				// it instead. We get it again, which might fail if it is not yet available.
+				if attempt >= createConflictRetries(config) {
+					errE := errors.WithMessage(err, "failed to create GitLab release for tag after retries")
+					errors.Details(errE)["tag"] = release.Tag
+					errors.Details(errE)["retries"] = attempt
+					return errE
+				}
+				fmt.Printf("GitLab release for tag \"%s\" already exists, updating it instead.\n", release.Tag)
+				continue
+			} else if err != nil {
+				errE := errors.WithMessage(err, "failed to create GitLab release for tag")
+				errors.Details(errE)["tag"] = release.Tag
+				return errE
+			}
+			// Links are created together with the release, but we check them
+			// anyway, so that any link which has not been created is created now.
+			return createMissingLinks(config, client, readClient, release, packages)
+		} else if err != nil {
+			errE := errors.WithMessage(err, "failed to get GitLab release for tag")
+			errors.Details(errE)["tag"] = release.Tag
+			return errE
+		}
+		break
+	}
+
+	// If GitLab release was made close to releasedAt, we set the releasedAt to CreatedAt
+	// to make sure that the release is not marked as a historical release.
+	if rel.CreatedAt != nil && recentlyReleased(*releasedAt, *rel.CreatedAt) {
+		releasedAt = rel.CreatedAt
+	}
+
+	fmt.Printf("Updating GitLab release for tag \"%s\".\n", release.Tag)
+	_, _, err := client.Releases.UpdateRelease(config.Project, releaseGitTag(release), &gitlab.UpdateReleaseOptions{
+		Name:        &name,
+		Description: &description,
+		ReleasedAt:  releasedAt,
+		Milestones:  &milestones,
+	})
+	if err != nil {
+		errE := errors.WithMessage(err, "failed to update GitLab release for tag")
+		errors.Details(errE)["tag"] = release.Tag
+		return errE
+	}
+
+	return syncLinks(config, client, readClient, release, packages)
+}
+
+// projectReleases fetches all releases for GitLab projectID project.
+func projectReleases(client *gitlab.Client, projectID string, perPage int) ([]*gitlab.Release, errors.E) {
+	releases := []*gitlab.Release{}
+	options := &gitlab.ListReleasesOptions{ //nolint:exhaustruct
+		ListOptions: gitlab.ListOptions{
+			PerPage: perPage,
+			Page:    1,
+		},
+	}
+	for {
+		page, response, err := client.Releases.ListReleases(projectID, options)
+		if err != nil {
+			errE := errors.WithMessage(err, "failed to list GitLab releases")
+			errors.Details(errE)["page"] = options.Page
+			return nil, errE
+		}
+
+		releases = append(releases, page...)
+
+		if response.NextPage == 0 {
+			break
+		}
+
+		options.Page = response.NextPage
+	}
+	return releases, nil
+}
+
+// previewLinks prints which release links would be deleted, updated, or created
+// for each release which already exists in the GitLab project, without changing them.
+func previewLinks(config *Config, client *gitlab.Client, releases []Release, tagsToPackages map[string][]Package) errors.E {
+	gitLabReleases, errE := projectReleases(client, config.Project, pageSize(config))
+	if errE != nil {
+		return errE
+	}
+
+	existingReleases := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range gitLabReleases {
+		existingReleases.Add(release.TagName)
+	}
+
+	for _, release := range releases {
+		if !existingReleases.Contains(releaseGitTag(release)) {
+			fmt.Printf("GitLab release for tag \"%s\" is missing, skipping links preview.\n", release.Tag)
+			continue
+		}
+
+		links, errE := releaseLinks(client, config.Project, release, pageSize(config)) //nolint:govet
+		if errE != nil {
+			return errE
+		}
+		expectedLinks, errE := getExpectedLinks(config, releasePackages(config, release, tagsToPackages[release.Tag]), releaseAssets(config, release)) //nolint:govet
+		if errE != nil {
+			return errE
+		}
+		diff := diffLinksForConfig(config, links, expectedLinks)
+
+		for _, l := range diff.Delete {
+			fmt.Printf("Would delete GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
+		}
+		for _, l := range diff.Update {
+			fmt.Printf("Would update GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
+		}
+		for _, l := range diff.Create {
+			fmt.Printf("Would create GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
+		}
+	}
+
+	return nil
+}
+
+// deleteOrphanedLinks deletes release links which are not expected for each release
+// which already exists in the GitLab project, without creating or updating anything.
+// Existing releases and links are fetched with readClient.
+func deleteOrphanedLinks(
+	config *Config, client, readClient *gitlab.Client, releases []Release, tagsToPackages map[string][]Package,
+) errors.E {
+	gitLabReleases, errE := projectReleases(readClient, config.Project, pageSize(config))
+	if errE != nil {
+		return errE
+	}
+
+	existingReleases := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range gitLabReleases {
+		existingReleases.Add(release.TagName)
+	}
+
+	for _, release := range releases {
+		if !existingReleases.Contains(releaseGitTag(release)) {
+			continue
+		}
+
+		links, errE := releaseLinks(readClient, config.Project, release, pageSize(config)) //nolint:govet
+		if errE != nil {
+			return errE
+		}
+		expectedLinks, errE := getExpectedLinks(config, releasePackages(config, release, tagsToPackages[release.Tag]), releaseAssets(config, release)) //nolint:govet
+		if errE != nil {
+			return errE
+		}
+
+		for _, l := range diffLinks(config, links, expectedLinks).Delete {
+			fmt.Printf("Deleting GitLab link \"%s\" for release \"%s\".\n", l.Name, release.Tag)
+			_, _, err := client.ReleaseLinks.DeleteReleaseLink(config.Project, releaseGitTag(release), *l.ID)
+			if err != nil {
+				errE := errors.WithMessage(err, "failed to delete GitLab link")
+				errors.Details(errE)["link"] = l.Name
+				errors.Details(errE)["release"] = release.Tag
+				return errE
+			}
+		}
+	}
+
+	return nil
+}
+
+// isManaged returns true if the description of the GitLab release
+// contains the marker that it has been generated by this tool.
+func isManaged(release *gitlab.Release) bool {
+	return strings.Contains(release.Description, descriptionMarker)
+}
+
+// extraReleases returns sorted tags of GitLab releases which are not listed in releases.
+// If OnlyManaged is set in config, GitLab releases not managed by this tool are skipped.
+func extraReleases(config *Config, releases []Release, gitLabReleases []*gitlab.Release) []string {
+	allReleases := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range releases {
+		allReleases.Add(releaseGitTag(release))
+	}
+
+	allGitLabReleases := mapset.NewThreadUnsafeSet[string]()
+	for _, release := range gitLabReleases {
+		if config.OnlyManaged && !isManaged(release) {
+			continue
+		}
+		allGitLabReleases.Add(release.TagName)
+	}
+
+	extra := allGitLabReleases.Difference(allReleases).ToSlice()
+	slices.Sort(extra)
+	return extra
+}
+
+// releasesDrift describes how releases in the GitLab project differ from the changelog.
+type releasesDrift struct {
+	// Missing are tags of releases which do not exist in the GitLab project.
+	Missing []string
+	// Extra are tags of GitLab releases which are not in the changelog.
+	Extra []string
+	// Changed are tags of GitLab releases with a name or description which does not match.
+	Changed []string
+}
+
+// Empty returns true if there is no drift.
+func (d releasesDrift) Empty() bool {
+	return len(d.Missing) == 0 && len(d.Extra) == 0 && len(d.Changed) == 0
+}
+
+// planReleases compares releases with gitLabReleases and returns the drift between them,
+// i.e., what Sync would have to change for GitLab releases to match the changelog.
+//
+// Release links and milestones are not compared.
+func planReleases(
+	config *Config, releases []Release, gitLabReleases []*gitlab.Release, tagsToImages map[string][]string,
+) (releasesDrift, errors.E) {
+	drift := releasesDrift{
+		Missing: []string{},
+		Extra:   extraReleases(config, releases, gitLabReleases),
+		Changed: []string{},
+	}
+
+	existing := map[string]*gitlab.Release{}
+	for _, release := range gitLabReleases {
+		existing[release.TagName] = release
+	}
+
+	for _, release := range releases {
+		rel, ok := existing[releaseGitTag(release)]
+		if !ok {
+			drift.Missing = append(drift.Missing, release.Tag)
+			continue
+		}
+
+		name, errE := releaseName(config, release)
+		if errE != nil {
+			return drift, errE
+		}
+		description, errE := releaseDescription(config, release, tagsToImages[release.Tag])
+		if errE != nil {
+			return drift, errE
+		}
+		if rel.Name != name || rel.Description != description {
+			drift.Changed = append(drift.Changed, release.Tag)
+		}
+	}
+
+	slices.Sort(drift.Missing)
+	slices.Sort(drift.Changed)
+
+	return drift, nil
+}
+
+// audit prints the drift between releases in the GitLab project and the changelog,
+// without changing anything. It returns an error if there is any drift.
+func audit(config *Config, client *gitlab.Client, releases []Release, tagsToImages map[string][]string) errors.E {
+	gitLabReleases, errE := projectReleases(client, config.Project, pageSize(config))
+	if errE != nil {
+		return errE
+	}
+
+	drift, errE := planReleases(config, releases, gitLabReleases, tagsToImages)
+	if errE != nil {
+		return errE
+	}
+
+	for _, tag := range drift.Missing {
+		fmt.Printf("GitLab release for tag \"%s\" is missing.\n", tag)
+	}
+	for _, tag := range drift.Changed {
+		fmt.Printf("GitLab release for tag \"%s\" is out of date.\n", tag)
+	}
+	for _, tag := range drift.Extra {
+		fmt.Printf("GitLab release for tag \"%s\" is not in the changelog.\n", tag)
+	}
+
+	if drift.Empty() {
+		fmt.Printf("GitLab releases are in sync with the changelog.\n")
+		return nil
+	}
+
+	errE = errors.New("GitLab releases are out of sync with the changelog")
+	errors.Details(errE)["missing"] = drift.Missing
+	errors.Details(errE)["changed"] = drift.Changed
+	errors.Details(errE)["extra"] = drift.Extra
+	return errE
+}
+
+// Statuses of releases in the reconciliation report.
+const (
+	reportUpToDate        = "up to date"
+	reportOutOfDate       = "out of date, would be updated"
+	reportMissing         = "missing, would be created"
+	reportMissingNoCreate = "missing, would not be created"
+	reportExtra           = "not in the changelog, would be deleted"
+)
+
+// writeReport writes a table with the status of every changelog release
+// and every GitLab release which is not in the changelog.
+func writeReport(w io.Writer, config *Config, releases []Release, drift releasesDrift) errors.E {
+	missing := mapset.NewThreadUnsafeSet(drift.Missing...)
+	changed := mapset.NewThreadUnsafeSet(drift.Changed...)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0) //nolint:gomnd
+	fmt.Fprintf(tw, "TAG\tSTATUS\n")
+	for _, release := range releases {
+		status := reportUpToDate
+		switch {
+		case missing.Contains(release.Tag) && config.NoCreate:
+			status = reportMissingNoCreate
+		case missing.Contains(release.Tag):
+			status = reportMissing
+		case changed.Contains(release.Tag):
+			status = reportOutOfDate
+		}
+		fmt.Fprintf(tw, "%s\t%s\n", release.Tag, status)
+	}
+	for _, tag := range drift.Extra {
+		fmt.Fprintf(tw, "%s\t%s\n", tag, reportExtra)
+	}
+	err := tw.Flush()
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	return nil
+}
+
+// report prints the reconciliation table between changelog releases and
+// releases in the GitLab project, without changing anything.
+func report(config *Config, client *gitlab.Client, releases []Release, tagsToImages map[string][]string) errors.E {
+	gitLabReleases, errE := projectReleases(client, config.Project, pageSize(config))
+	if errE != nil {
+		return errE
+	}
+
+	drift, errE := planReleases(config, releases, gitLabReleases, tagsToImages)
+	if errE != nil {
+		return errE
+	}
+
+	return writeReport(os.Stdout, config, releases, drift)
+}
+
+// writeMapping writes milestones, packages, and Docker images mapped to each release.
+func writeMapping(
+	w io.Writer, releases []Release, tagsToMilestones map[string][]string,
+	tagsToPackages map[string][]Package, tagsToImages map[string][]string,
+) {
+	for _, release := range releases {
+		fmt.Fprintf(w, "%s:\n", release.Tag)
+		fmt.Fprintf(w, "  milestones:\n")
+		for _, milestone := range tagsToMilestones[release.Tag] {
+			fmt.Fprintf(w, "    - %s\n", milestone)
+		}
+		fmt.Fprintf(w, "  packages:\n")
+		for _, p := range tagsToPackages[release.Tag] {
+			project := ""
+			if p.Project != "" {
+				project = fmt.Sprintf(" (project %s)", p.Project)
+			}
+			fmt.Fprintf(w, "    - %s %s%s\n", p.Name, p.Version, project)
+			for _, file := range p.Files {
+				fmt.Fprintf(w, "      - %s\n", file)
+			}
+		}
+		fmt.Fprintf(w, "  images:\n")
+		for _, image := range tagsToImages[release.Tag] {
+			fmt.Fprintf(w, "    - %s\n", image)
+		}
+	}
+}
+
+// DeleteAllExcept deletes all releases which exist in the GitLab project but
+// are not listed in releases. If a date range is configured, only GitLab releases
+// released inside it are deleted. Existing releases are fetched with readClient,
+// which can be the same as client.
+func DeleteAllExcept(config *Config, client, readClient *gitlab.Client, releases []Release) errors.E {
+	dateRange, errE := parseDateRange(config)
+	if errE != nil {
+		return errE
+	}
+
+	gitLabReleases, errE := projectReleases(readClient, config.Project, pageSize(config))
+	if errE != nil {
+		return errE
+	}
+
+	// With a date range, GitLab releases released outside of it are left as they are.
+	if dateRange.IsSet() {
+		inRange := []*gitlab.Release{}
+		for _, release := range gitLabReleases {
+			if release.ReleasedAt != nil && dateRange.Contains(*release.ReleasedAt) {
+				inRange = append(inRange, release)
+			}
+		}
+		gitLabReleases = inRange
+	}
+
+	for _, tag := range extraReleases(config, releases, gitLabReleases) {
+		fmt.Printf("Deleting GitLab release for tag \"%s\".\n", tag)
+		_, _, err := client.Releases.DeleteRelease(config.Project, tag)
+		if err != nil {
+			errE := errors.WithMessage(err, "failed to delete GitLab release for tag")
+			errors.Details(errE)["tag"] = tag
+			return errE
+		}
+	}
+
+	return nil
+}
+
+// noChange is an identify function for strings.
+func noChange(s string) string {
+	return s
+}
+
+// removeVPrefix removes "v" from the beginning of the string.
+func removeVPrefix(s string) string {
+	return strings.TrimPrefix(s, "v")
+}
+
+// slugify makes a slug from the string, matching what is used in GitLab.
+// See: https://gitlab.com/gitlab-org/gitlab/-/blob/c61e4166/lib/gitlab/utils.rb#L73-84
+func slugify(s string) string {
+	return refSlug(s)
+}
+
+// removeVPrefixAndSlugify combines removeVPrefix and refSlug.
+func removeVPrefixAndSlugify(s string) string {
+	return refSlug(removeVPrefix(s))
+}
+
+// underscoreSeparators replaces "." version separators with "_" (e.g., "1.2.3" becomes "1_2_3"),
+// which is sometimes used in Docker image tags and package versions.
+func underscoreSeparators(s string) string {
+	return strings.ReplaceAll(s, ".", "_")
+}
+
+// removeVPrefixAndUnderscoreSeparators combines removeVPrefix and underscoreSeparators.
+func removeVPrefixAndUnderscoreSeparators(s string) string {
+	return underscoreSeparators(removeVPrefix(s))
+}
+
+var tagTransformations = []func(string) string{ //nolint:gochecknoglobals
+	noChange, removeVPrefix, slugify, removeVPrefixAndSlugify,
+	underscoreSeparators, removeVPrefixAndUnderscoreSeparators,
+}
+
+// exactTagTransformations are used with exact matching. Tags always have the "v" prefix,
+// so it is still removed to match versions.
+var exactTagTransformations = []func(string) string{ //nolint:gochecknoglobals
+	noChange, removeVPrefix,
+}
+
+// configTagTransformations returns tag transformations to use for mapping. If config
+// has any prefixes to strip or strings to replace, a transformation which strips prefixes
+// and then replaces strings is prepended to the built-in tagTransformations (or
+// exactTagTransformations if ExactMatchOnly is set).
+func configTagTransformations(config *Config) ([]func(string) string, errors.E) {
+	builtIn := tagTransformations
+	if config.ExactMatchOnly {
+		builtIn = exactTagTransformations
+	}
+	if len(config.StripPrefixes) == 0 && len(config.Replacements) == 0 {
+		return builtIn, nil
+	}
+	replacements := []string{}
+	for _, replacement := range config.Replacements {
+		old, replacement, ok := strings.Cut(replacement, ":")
+		if !ok || old == "" {
+			errE := errors.New(`replacement should be in "old:new" format`)
+			errors.Details(errE)["replacement"] = old
+			return nil, errE
+		}
+		replacements = append(replacements, old, replacement)
+	}
+	replacer := strings.NewReplacer(replacements...)
+	stripPrefixes := config.StripPrefixes
+	custom := func(s string) string {
+		for _, prefix := range stripPrefixes {
+			s = strings.TrimPrefix(s, prefix)
+		}
+		return replacer.Replace(s)
+	}
+	return append([]func(string) string{custom}, builtIn...), nil
+}
+
+// isVersionPrefix returns true if s is a prefix of version which ends at the
+// boundary of a version component (e.g., "1.0" is a version prefix of "1.0.1").
+func isVersionPrefix(s, version string) bool {
+	if s == "" || len(s) >= len(version) || !strings.HasPrefix(version, s) {
+		return false
+	}
+	return version[len(s)] == '.'
+}
+
+// mapStringsToTags attempts to map input strings to releases' tags by searching for
+// each release's tag (i.e., version with "v" prefix) or version (i.e., tag without
+// "v" prefix) in strings and those which match are associated with the tag/version.
+//
+// It starts with the longest tags so that more specific tags are mapped first.
+// This makes string "1.0.0-rc" be mapped to tag "1.0.0-rc" if such a tag exist
+// together with the "1.0.0" tag. On the other hand, if only "1.0.0" tag exists,
+// then "1.0.0-rc" is mapped to "1.0.0".
+//
+// If multi is true, each input is mapped to all tags it matches and not just to the
+// first one. Moreover, an input also matches tags for which it is a version prefix
+// (e.g., "1.0" matches "1.0.0" and "1.0.1"). In this mode the longest-match-first
+// ordering does not make mapping exclusive anymore, so "1.0.0-rc" is mapped to both
+// "1.0.0-rc" and "1.0.0" tags, if both exist.
+//
+// If foldCase is true, strings are matched case-insensitively.
+//
+// If exact is true, a string matches a tag only if it is equal to the transformed tag
+// and not if it just contains it (nor if it is its version prefix).
+//
+// Tags are transformed with each of transformations in order.
+func mapStringsToTags(
+	inputs []string, releases []Release, multi, foldCase, exact bool, transformations []func(string) string,
+) map[string][]string {
+	tagsToInputs := map[string][]string{}
+
+	tags := make([]string, len(releases))
+	for i := 0; i < len(releases); i++ {
+		tags[i] = releases[i].Tag
+	}
+
+	// First we do a regular sort, so that we get deterministic results later on.
+	sort.Stable(sort.StringSlice(tags))
+	sort.Stable(sort.StringSlice(inputs))
+	// Then we sort by length, so that we can map longer tag names first
+	// (e.g., 1.0.0-rc before 1.0.0).
+	sort.SliceStable(tags, func(i, j int) bool {
+		return len(tags[i]) > len(tags[j])
+	})
+
+	assignedInputs := mapset.NewThreadUnsafeSet[string]()
+	assignedPairs := mapset.NewThreadUnsafeSet[[2]string]()
+	for _, transformation := range transformations {
+		for _, tag := range tags {
+			t := transformation(tag)
+			if foldCase {
+				t = strings.ToLower(t)
+			}
+
+			for _, input := range inputs {
+				if !multi && assignedInputs.Contains(input) {
+					continue
+				}
+				if assignedPairs.Contains([2]string{tag, input}) {
+					continue
+				}
+
+				i := input
+				if foldCase {
+					i = strings.ToLower(i)
+				}
+				if matchesTag(i, t, multi, exact) {
+					if tagsToInputs[tag] == nil {
+						tagsToInputs[tag] = []string{}
+					}
+					tagsToInputs[tag] = append(tagsToInputs[tag], input)
+					assignedInputs.Add(input)
+					assignedPairs.Add([2]string{tag, input})
+				}
+			}
+		}
+	}
+
+	return tagsToInputs
+}
+
+// matchesTag returns true if input s matches transformed tag t.
+func matchesTag(s, t string, multi, exact bool) bool {
+	if exact {
+		return s == t
+	}
+	return strings.Contains(s, t) || (multi && isVersionPrefix(s, t))
+}
+
+// mapMilestonesToTags maps provided milestones to releases' tags.
+// Milestone titles are free-form text, so they are matched case-insensitively.
+//
+// If multi is true, one milestone can be mapped to multiple releases.
+// If exact is true, milestone titles have to equal tags or versions.
+func mapMilestonesToTags(
+	milestones []string, releases []Release, multi, exact bool, transformations []func(string) string,
+) map[string][]string {
+	return mapStringsToTags(milestones, releases, multi, true, exact, transformations)
+}
+
+// removeBuildMetadata removes semver build metadata (e.g., "+build.45") from the version.
+func removeBuildMetadata(version string) string {
+	version, _, _ = strings.Cut(version, "+")
+	return version
+}
+
+// mapMilestonesToTags maps provided packages to releases' tags.
+//
+// Packages are mapped based on their version string, ignoring semver build metadata
+// (e.g., package version "1.2.0+build.45" is mapped to tag "v1.2.0").
+//
+// If exact is true, package versions have to equal tags or versions.
+func mapPackagesToTags(packages []Package, releases []Release, exact bool, transformations []func(string) string) map[string][]Package {
+	tagsToPackages := map[string][]Package{}
+
+	tags := make([]string, len(releases))
+	for i := 0; i < len(releases); i++ {
+		tags[i] = releases[i].Tag
+	}
+
+	// First we do a regular sort, so that we get deterministic results later on.
+	sort.Stable(sort.StringSlice(tags))
+	sort.SliceStable(packages, func(i, j int) bool {
+		return packages[i].Version < packages[j].Version
+	})
+	// Then we sort by length, so that we can map longer tag names first
+	// (e.g., 1.0.0-rc before 1.0.0).
+	sort.SliceStable(tags, func(i, j int) bool {
+		return len(tags[i]) > len(tags[j])
+	})
+
+	assignedPackages := mapset.NewThreadUnsafeSet[int]()
+	for _, transformation := range transformations {
+		for _, tag := range tags {
+			t := transformation(removeBuildMetadata(tag))
+
+			for _, p := range packages {
+				if assignedPackages.Contains(p.ID) {
+					continue
+				}
+
+				if matchesTag(removeBuildMetadata(p.Version), t, false, exact) {
+					if tagsToPackages[tag] == nil {
+						tagsToPackages[tag] = []Package{}
+					}
+					tagsToPackages[tag] = append(tagsToPackages[tag], p)
+					assignedPackages.Add(p.ID)
+				}
+			}
+		}
+	}
+
+	return tagsToPackages
+}
+
+// mapMilestonesToTags maps provided Docker images to releases' tags.
+//
+// If exact is true, Docker image tags (the part of the image location after ":")
+// have to equal tags or versions.
+func mapImagesToTags(images []string, releases []Release, exact bool, transformations []func(string) string) map[string][]string {
+	if !exact {
+		return mapStringsToTags(images, releases, false, false, false, transformations)
+	}
+
+	imageTags := []string{}
+	tagsToImages := map[string][]string{}
+	for _, image := range images {
+		t := dockerImageTag(image)
+		if tagsToImages[t] == nil {
+			imageTags = append(imageTags, t)
+		}
+		tagsToImages[t] = append(tagsToImages[t], image)
+	}
+	result := map[string][]string{}
+	for tag, imageTags := range mapStringsToTags(imageTags, releases, false, false, true, transformations) {
+		for _, t := range imageTags {
+			result[tag] = append(result[tag], tagsToImages[t]...)
+		}
+	}
+	return result
+}
+
+// dockerImageTag returns the tag of the Docker image location
+// (e.g., "1.0.0" for "registry.example.com/project:1.0.0").
+func dockerImageTag(image string) string {
+	i := strings.LastIndex(image, ":")
+	if i == -1 || strings.Contains(image[i:], "/") {
+		return ""
+	}
+	return image[i+1:]
+}
+
+// calendarDaysBetween returns the number of calendar days from changelogDate to tagDate.
+// Because changelog dates do not have timezone information, they are interpreted as
+// calendar days in the timezone of the tag date (i.e., where the tag was made) and
+// not in UTC, so a tag made late in the evening matches its changelog date.
+func calendarDaysBetween(changelogDate, tagDate time.Time) int {
+	c := time.Date(changelogDate.Year(), changelogDate.Month(), changelogDate.Day(), 0, 0, 0, 0, time.UTC)
+	t := time.Date(tagDate.Year(), tagDate.Month(), tagDate.Day(), 0, 0, 0, 0, time.UTC)
+	return int(t.Sub(c).Hours() / 24) //nolint:gomnd
+}
+
+// recentWindow is how close to now a release time has to be for the release
+// to be considered a recent release and not a historical one.
+const recentWindow = 12 * time.Hour
+
+// recentlyReleased returns true if releasedAt is close to now, or on the same
+// calendar day as now (each in its own timezone). Comparing by calendar day
+// makes changelog dates without time (which are at midnight UTC) match
+// releases made later that day, even west of UTC.
+func recentlyReleased(releasedAt, now time.Time) bool {
+	if now.Sub(releasedAt).Abs() < recentWindow {
+		return true
+	}
+	return calendarDaysBetween(releasedAt, now) == 0
+}
+
+// releaseTime returns the time of the release. This is the time from the changelog,
+// if it is provided there, otherwise the date of the git tag, if known, otherwise
+// the changelog date of the release.
+func releaseTime(release Release, tagDate *time.Time) *time.Time {
+	if release.HasTime {
+		date := release.Date
+		return &date
+	}
+	if tagDate != nil {
+		return tagDate
+	}
+	date := release.Date
+	return &date
+}
+
+// releasesDatesDrift returns the number of calendar days between the changelog date and
+// the git tag date for releases for which they differ by more than threshold days, keyed by tag.
+func releasesDatesDrift(releases []Release, tagsToDates map[string]*time.Time, threshold int) map[string]int {
+	drift := map[string]int{}
+	for _, release := range releases {
+		tagDate := tagsToDates[release.Tag]
+		if tagDate == nil {
+			continue
+		}
+		days := calendarDaysBetween(release.Date, *tagDate)
+		if days > threshold || -days > threshold {
+			drift[release.Tag] = days
+		}
+	}
+	return drift
+}
+
+// warnReleasesDates warns for releases for which the changelog date and the git tag
+// date differ by more than threshold days, e.g., because of a typo in the changelog.
+func warnReleasesDates(releases []Release, tagsToDates map[string]*time.Time, threshold int, warnings *warnings) {
+	drift := releasesDatesDrift(releases, tagsToDates, threshold)
+	for _, release := range releases {
+		days, ok := drift[release.Tag]
+		if !ok {
+			continue
+		}
+		warnings.Warnf(
+			"changelog date %s for release \"%s\" differs from git tag date %s by %d days.",
+			release.Date.Format("2006-01-02"), release.Tag, tagsToDates[release.Tag].Format("2006-01-02"), days,
+		)
+	}
+}
+
+// movedTagTolerance is how much the git tag date can differ from the GitLab release date
+// before the tag is considered moved. GitLab sets the date of releases created without
+// it (which we do for recent releases) to the time of creation, which is close to the tag date.
+const movedTagTolerance = 12 * time.Hour
+
+// movedTags returns dates of existing GitLab releases for which the release date (the git tag
+// date or, if the changelog heading has it, the time from the heading) differs from them, which
+// happens when the tag has been moved, keyed by tag.
+func movedTags(releases []Release, gitLabReleases []*gitlab.Release, tagsToDates map[string]*time.Time) map[string]*time.Time {
+	releasedAt := map[string]*time.Time{}
+	for _, rel := range gitLabReleases {
+		releasedAt[rel.TagName] = rel.ReleasedAt
+	}
+	moved := map[string]*time.Time{}
+	for _, release := range releases {
+		tagDate, gitLabDate := tagsToDates[release.Tag], releasedAt[releaseGitTag(release)]
+		if tagDate == nil || gitLabDate == nil {
+			continue
+		}
+		if releaseTime(release, tagDate).Sub(*gitLabDate).Abs() > movedTagTolerance {
+			moved[release.Tag] = gitLabDate
+		}
+	}
+	return moved
+}
+
+// warnMovedTags warns for releases for which the git tag has been moved.
+// The date of their GitLab releases is updated when they are synced.
+func warnMovedTags(
+	config *Config, client *gitlab.Client, releases []Release, tagsToDates map[string]*time.Time, warnings *warnings,
+) errors.E {
+	gitLabReleases, errE := projectReleases(client, config.Project, pageSize(config))
+	if errE != nil {
+		return errE
+	}
+	moved := movedTags(releases, gitLabReleases, tagsToDates)
+	for _, release := range releases {
+		gitLabDate, ok := moved[release.Tag]
+		if !ok {
+			continue
+		}
+		warnings.Warnf(
+			"git tag \"%s\" seems to have been moved: its date %s differs from GitLab release date %s.",
+			release.Tag, releaseTime(release, tagsToDates[release.Tag]).UTC().Format(time.RFC3339), gitLabDate.UTC().Format(time.RFC3339),
+		)
+	}
+	return nil
+}
+
+func mapTagsToDates(tags []Tag) map[string]*time.Time {
+	tagsToDates := map[string]*time.Time{}
+	for _, tag := range tags {
+		tag := tag
+		tagsToDates[tag.Name] = &tag.Date
+	}
+	return tagsToDates
+}
+
+// workDir returns the directory in which the git repository is expected to be,
+// as configured with ChangeTo, or the current working directory.
+func workDir(config *Config) string {
+	if config.ChangeTo != "" {
+		return string(config.ChangeTo)
+	}
+	return "."
+}
+
+// defaultChangelog is the changelog path used when none is configured nor discovered.
+const defaultChangelog = "CHANGELOG.md"
+
+// discoverChangelog returns the path of the first of locations (relative to the root
+// of the git repository at path) which exists.
+func discoverChangelog(path string, locations []string) (string, errors.E) {
+	repository, err := git.PlainOpenWithOptions(path, &git.PlainOpenOptions{
+		DetectDotGit:          true,
+		EnableDotGitCommonDir: false,
+	})
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot open git repository")
+		errors.Details(errE)["path"] = path
+		return "", errE
+	}
+	workTree, err := repository.Worktree()
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot obtain git worktree")
+		errors.Details(errE)["path"] = path
+		return "", errE
+	}
+	root := workTree.Filesystem.Root()
+
+	for _, location := range locations {
+		p := filepath.Join(root, location)
+		info, err := os.Stat(p)
+		if err == nil && !info.IsDir() {
+			return p, nil
+		}
+	}
+
+	errE := errors.New("changelog not found")
+	errors.Details(errE)["root"] = root
+	errors.Details(errE)["locations"] = locations
+	return "", errE
+}
+
+// resolveChangelogPath returns the path of the changelog file. Configured changelog
+// path (or URL) is used if set (relative to dir), otherwise it is discovered if enabled,
+// otherwise the default changelog path is used.
+func resolveChangelogPath(config *Config, dir string) (string, errors.E) {
+	changelogPath := config.Changelog
+	if changelogPath == "" {
+		if config.DiscoverChangelog {
+			return discoverChangelog(dir, config.ChangelogLocations)
+		}
+		changelogPath = defaultChangelog
+	}
+	if isChangelogURL(changelogPath) {
+		return changelogPath, nil
+	}
+	if !filepath.IsAbs(changelogPath) {
+		changelogPath = filepath.Join(dir, changelogPath)
+	}
+	return changelogPath, nil
+}
+
+// Sync syncs tags in a git repository and a changelog in Keep a Changelog format with
 // releases of a GitLab project. It creates any missing release, it updates existing
 // releases, and it deletes and releases which do not exist anymore.
-func Sync(config *Config) errors.E {
-	releases, errE := changelogReleases(config.Changelog)
+//
+// Releases are synced to the primary GitLab instance and then to all configured mirrors.
+func Sync(config *Config) (errE errors.E) { //nolint:nonamedreturns
+	if config.PrintConfig {
+		return printConfig(os.Stdout, config)
+	}
+
+	if config.LintFormat != "" {
+		return lint(config)
+	}
+
+	if config.ExportGitHubOnly && config.ExportGitHub == "" {
+		return errors.New("exporting only to GitHub requires a path to export to")
+	}
+
+	warnings := newWarnings(os.Stderr)
+	defer func() {
+		if errE == nil && config.FailOnWarnings {
+			errE = warnings.Error()
+		}
+	}()
+
+	// We parse mirrors before syncing anything to fail early.
+	mirrors, errE := parseMirrors(config.Mirrors)
+	if errE != nil {
+		return errE
+	}
+
+	// Syncing changes config (e.g., it resolves the project), so we
+	// base configuration for mirrors on the original configuration.
+	original := *config
+
+	errE = syncTarget(config, warnings)
+	if errE != nil {
+		return errE
+	}
+	// When only exporting or printing the graph, nothing is synced to mirrors either.
+	if len(mirrors) == 0 || config.ExportGitHubOnly || config.Graph != "" {
+		return nil
+	}
+
+	results := []string{fmt.Sprintf("%s: synced", config.BaseURL)}
+	for _, m := range mirrors {
+		fmt.Printf("Syncing GitLab releases to mirror \"%s\".\n", m.BaseURL)
+		mirrorConfig, errE := m.config(&original) //nolint:govet
+		if errE == nil {
+			errE = syncTarget(mirrorConfig, warnings)
+		}
+		if errE != nil {
+			errors.Details(errE)["mirror"] = m.BaseURL
+			if config.MirrorFailuresFatal {
+				return errE
+			}
+			warnings.Warnf("syncing to mirror \"%s\" failed: %s.", m.BaseURL, errE.Error())
+			results = append(results, fmt.Sprintf("%s: failed", m.BaseURL))
+			continue
+		}
+		results = append(results, fmt.Sprintf("%s: synced", m.BaseURL))
+	}
+
+	fmt.Printf("Sync results:\n")
+	for _, result := range results {
+		fmt.Printf("  %s\n", result)
+	}
+
+	return nil
+}
+
+// loadReleases returns releases from the changelog or, if configured, generated from commits.
+// In changed-only mode it also returns tags of releases changed since the base ref.
+func loadReleases(config *Config, dir string, tags []Tag) ([]Release, []string, errors.E) {
+	if config.FromCommits {
+		if config.ChangedOnly {
+			return nil, nil, errors.New("syncing only changed releases requires a changelog")
+		}
+		releases, errE := commitsReleases(dir, tags)
+		return releases, nil, errE
+	}
+
+	changelogPath, errE := resolveChangelogPath(config, dir)
+	if errE != nil {
+		return nil, nil, errE
+	}
+
+	var releases []Release
+	var front *frontMatter
+	if isChangelogURL(changelogPath) {
+		if config.ChangelogRef != "" {
+			errE = errors.New("changelog ref cannot be used with changelog URL")
+			errors.Details(errE)["url"] = changelogPath
+			return nil, nil, errE
+		}
+		data, errE := fetchChangelog(config, changelogPath) //nolint:govet
+		if errE != nil {
+			return nil, nil, errE
+		}
+		releases, front, errE = parseChangelogReleases(data, changelogPath, unreleasedHeadings(config))
+		if errE != nil {
+			return nil, nil, errE
+		}
+	} else if config.ChangelogRef != "" {
+		data, errE := changelogAtRef(changelogPath, config.ChangelogRef) //nolint:govet
+		if errE != nil {
+			return nil, nil, errE
+		}
+		releases, front, errE = parseChangelogReleases(data, changelogPath, unreleasedHeadings(config))
+		if errE != nil {
+			return nil, nil, errE
+		}
+	} else {
+		releases, front, errE = changelogReleases(changelogPath, unreleasedHeadings(config))
+		if errE != nil {
+			return nil, nil, errE
+		}
+	}
+	front.apply(config)
+
+	// We determine changed releases before releases are updated (e.g., with metadata),
+	// so that they are compared with releases at base as they are in the changelog.
+	if config.ChangedOnly {
+		if isChangelogURL(changelogPath) {
+			errE = errors.New("syncing only changed releases cannot be used with changelog URL")
+			errors.Details(errE)["url"] = changelogPath
+			return nil, nil, errE
+		}
+		changed, errE := changedReleaseTags(changelogPath, config.ChangedBase, releases, unreleasedHeadings(config)) //nolint:govet
+		if errE != nil {
+			return nil, nil, errE
+		}
+		return releases, changed, nil
+	}
+
+	return releases, nil, nil
+}
+
+// syncTarget syncs releases to the GitLab instance at config.BaseURL.
+func syncTarget(config *Config, warnings *warnings) (errE errors.E) { //nolint:nonamedreturns
+	dir := workDir(config)
+
+	// We first check that the GitLab project is accessible, before doing any other work.
+	project, errE := resolveProject(config.Project, dir, config.BaseURL, warnings)
+	if errE != nil {
+		return errE
+	}
+	config.Project = project
+
+	// We load tags and releases only once, but we return any error only after
+	// we checked that the GitLab project is accessible.
+	var releases []Release
+	var changed []string
+	tags, loadErrE := gitTags(dir, warnings)
+	if loadErrE == nil {
+		releases, changed, loadErrE = loadReleases(config, dir, tags)
+	}
+
+	// In a repository without tags and releases there is nothing to sync,
+	// so we do not even access GitLab API.
+	if loadErrE == nil && len(tags) == 0 && len(releases) == 0 {
+		fmt.Printf("Nothing to sync: there are no git tags and no releases in the changelog.\n")
+		return nil
+	}
+
+	token, errE := resolveToken(config)
 	if errE != nil {
 		return errE
 	}
+	config.Token = token
 
-	tags, errE := gitTags(".")
+	counter := newRequestCounter()
+	client, errE := newClient(config, counter)
 	if errE != nil {
 		return errE
 	}
 
-	errE = compareReleasesTags(releases, tags)
+	// Requests which only read use the read token, if it is configured.
+	readClient := client
+	if rc := readConfig(config); rc != config {
+		readClient, errE = newClient(rc, counter)
+		if errE != nil {
+			return errE
+		}
+	}
+
+	var linksClient, gitLabLinksClient *http.Client
+	if config.VerifyLinks {
+		// Links can point outside of GitLab, so requests are not counted as GitLab API requests
+		// and credentials are used only for links to GitLab.
+		linksClient, errE = newPlainHTTPClient(config)
+		if errE != nil {
+			return errE
+		}
+		gitLabLinksClient, errE = newHTTPClient(config, nil)
+		if errE != nil {
+			return errE
+		}
+	}
+	defer counter.Print(config.Verbose)
+
+	hasIssues, hasPackages, hasImages, projectURL, errE := projectConfiguration(readClient, config.Project)
 	if errE != nil {
 		return errE
 	}
 
-	if config.Project == "" {
-		projectID, errE := x.InferGitLabProjectID(".") //nolint:govet
+	if loadErrE != nil {
+		return loadErrE
+	}
+
+	// Modes which do not change anything can run concurrently with other runs.
+	if config.Lock && !readOnlyMode(config) {
+		holder, errE := acquireLock(client, config.Project, config.LockTimeout, warnings)
 		if errE != nil {
 			return errE
 		}
-		config.Project = projectID
+		defer func() {
+			e := releaseLock(client, config.Project, holder)
+			if errE == nil {
+				errE = e
+			}
+		}()
 	}
 
-	client, err := gitlab.NewClient(config.Token, gitlab.WithBaseURL(config.BaseURL))
-	if err != nil {
-		return errors.WithMessage(err, "failed to create GitLab API client instance")
+	dateRange, errE := parseDateRange(config)
+	if errE != nil {
+		return errE
+	}
+
+	// Releases generated from commits use git tag names as they are.
+	if !config.FromCommits && len(config.TagStripPrefixes) > 0 {
+		var gitTagNames map[string]string
+		tags, gitTagNames, errE = stripTagPrefixes(tags, config.TagStripPrefixes)
+		if errE != nil {
+			return errE
+		}
+		for i := range releases {
+			releases[i].GitTag = gitTagNames[releases[i].Tag]
+		}
+	}
+
+	if config.ChangelogAssets {
+		errE = extractAssets(releases)
+		if errE != nil {
+			return errE
+		}
+	}
+
+	if config.RequireNotes {
+		errE = checkNotes(releases)
+		if errE != nil {
+			return errE
+		}
+	}
+
+	if config.Metadata != "" {
+		metadataPath := config.Metadata
+		if !filepath.IsAbs(metadataPath) {
+			metadataPath = filepath.Join(dir, metadataPath)
+		}
+		metadata, errE := releasesMetadata(metadataPath) //nolint:govet
+		if errE != nil {
+			return errE
+		}
+		applyMetadata(releases, metadata, warnings)
+	}
+
+	errE = applyCategories(config, releases)
+	if errE != nil {
+		return errE
+	}
+
+	if config.LinkifyReferences {
+		if projectURL == "" {
+			projectURL = strings.TrimSuffix(config.BaseURL, "/") + "/" + config.Project
+		}
+		for i := range releases {
+			releases[i].Changes = linkifyReferences(releases[i].Changes, projectURL)
+		}
+	}
+
+	if config.LatestBadge {
+		markLatestRelease(releases)
+	}
+
+	// We select the release only after releases have been updated (e.g., with metadata)
+	// because selecting copies the release.
+	selected := releases
+	if config.Tag != "" {
+		selected, errE = selectRelease(releases, config.Tag)
+		if errE != nil {
+			return errE
+		}
+	}
+	if config.ChangedOnly {
+		changedReleases := []Release{}
+		for _, release := range selected {
+			if slices.Contains(changed, release.Tag) {
+				changedReleases = append(changedReleases, release)
+			}
+		}
+		selected = changedReleases
+		fmt.Printf("Syncing only releases changed since \"%s\": %d.\n", config.ChangedBase, len(selected))
+	}
+	if dateRange.IsSet() {
+		selected = filterReleasesByDate(selected, dateRange)
+		fmt.Printf("Syncing only releases in the date range: %d.\n", len(selected))
+	}
+
+	// With a tag, only the tag of the selected release is created.
+	tagged := releases
+	if config.Tag != "" {
+		tagged = selected
+	}
+
+	// Releases generated from commits match tags by construction.
+	if !config.FromCommits {
+		compared := releases
+		// Modes which do not change anything do not create missing tags.
+		if config.CreateMissingTags && !readOnlyMode(config) {
+			errE = setMissingTagsRefs(dir, config.Ref, tagged, tags)
+			if errE != nil {
+				return errE
+			}
+
+			// We check that GitLab can create tags from the commits before we create
+			// local tags, so that they are not left behind if the check fails.
+			errE = checkReleasesRefs(readClient, config.Project, tagged)
+			if errE != nil {
+				return errE
+			}
+
+			created, errE := createMissingTags(config, dir, tagged, tags) //nolint:govet
+			if errE != nil {
+				return errE
+			}
+			tags = append(tags, created...)
+
+			// Missing tags of other releases are created when those releases are synced.
+			if config.Tag != "" {
+				compared = withoutMissingTags(releases, selected, tags)
+			}
+		}
+
+		errE = compareReleasesTags(compared, tags)
+		if errE != nil {
+			return errE
+		}
+	}
+
+	if config.RequireSemver {
+		errE = checkSemver(releases, tags)
+		if errE != nil {
+			return errE
+		}
+	}
+
+	// Releases are selected before missing tags are created so that an unknown tag
+	// fails early. We select them again to pick up refs set for missing tags.
+	selected = reselectReleases(tagged, selected)
+
+	// When only some releases are synced, the latest badge has to be moved as well.
+	if config.LatestBadge && len(selected) < len(releases) && !readOnlyMode(config) {
+		gitLabReleases, errE := projectReleases(readClient, config.Project, pageSize(config)) //nolint:govet
+		if errE != nil {
+			return errE
+		}
+		count := len(selected)
+		selected = latestBadgeReleases(releases, selected, gitLabReleases)
+		if len(selected) > count {
+			fmt.Printf("Syncing also releases to update the latest badge: %d.\n", len(selected)-count)
+		}
 	}
 
-	hasIssues, hasPackages, hasImages, errE := projectConfiguration(client, config.Project)
+	transformations, errE := configTagTransformations(config)
 	if errE != nil {
 		return errE
 	}
 
 	tagsToMilestones := map[string][]string{}
-	if hasIssues {
-		milestones, errE := projectMilestones(client, config.Project) //nolint:govet
+	if hasIssues && !config.NoMilestones {
+		milestones, errE := projectMilestones(readClient, config.Project, config.MilestoneState, pageSize(config)) //nolint:govet
 		if errE != nil {
 			return errE
 		}
 
-		tagsToMilestones = mapMilestonesToTags(milestones, releases)
+		tagsToMilestones = mapMilestonesToTags(milestones, releases, config.MilestoneMulti, config.ExactMatchOnly, transformations)
+
+		// Modes which do not change anything do not create missing milestones.
+		if config.CreateMilestones && !readOnlyMode(config) {
+			errE = createMissingMilestones(client, config.Project, releases, tagsToMilestones)
+			if errE != nil {
+				return errE
+			}
+		}
 	}
 
 	tagsToPackages := map[string][]Package{}
-	if hasPackages {
-		packages, errE := projectPackages(client, config.Project) //nolint:govet
+	if !config.NoPackages {
+		packagesProjects := []string{}
+		if hasPackages {
+			packagesProjects = append(packagesProjects, config.Project)
+		}
+		packagesProjects = append(packagesProjects, config.PackagesProjects...)
+
+		packages := []Package{}
+		for _, projectID := range packagesProjects {
+			ps, errE := projectPackages(readClient, projectID, config.Concurrency, pageSize(config)) //nolint:govet
+			if errE != nil {
+				errors.Details(errE)["project"] = projectID
+				return errE
+			}
+			packages = append(packages, ps...)
+		}
+
+		tagsToPackages = mapPackagesToTags(packages, releases, config.ExactMatchOnly, transformations)
+		printExcludedLinkFiles(os.Stdout, config, releases, tagsToPackages)
+	}
+
+	tagsToImages := map[string][]string{}
+	if hasImages && !config.NoImages {
+		images, errE := projectImages(readClient, config.Project, pageSize(config)) //nolint:govet
 		if errE != nil {
 			return errE
 		}
 
-		tagsToPackages = mapPackagesToTags(packages, releases)
+		if config.ImageLabel != "" {
+			values, errE := imagesLabel(newRegistryClient(readConfig(config)), images, config.ImageLabel) //nolint:govet
+			if errE != nil {
+				return errE
+			}
+			tagsToImages = mapImagesToTagsByLabel(values, releases)
+		} else {
+			tagsToImages = mapImagesToTags(images, releases, config.ExactMatchOnly, transformations)
+		}
 	}
 
-	tagsToImages := map[string][]string{}
-	if hasImages {
-		images, errE := projectImages(client, config.Project) //nolint:govet
+	// Only releases which are synced (or audited) need issues in their descriptions,
+	// so we fetch issues only for them to limit the number of API requests.
+	if config.IncludeIssues && !config.PrintMapping && config.Graph == "" && !config.PreviewLinks && !config.DeleteOrphanedLinks {
+		issuesReleases := selected
+		if config.Audit || config.Report {
+			issuesReleases = releases
+		}
+		tagsToIssues, errE := mapIssuesToTags(readClient, config.Project, issuesReleases, tagsToMilestones, pageSize(config)) //nolint:govet
 		if errE != nil {
 			return errE
 		}
+		applyIssues(releases, tagsToIssues)
+		applyIssues(selected, tagsToIssues)
+	}
+
+	if config.PrintMapping {
+		writeMapping(os.Stdout, releases, tagsToMilestones, tagsToPackages, tagsToImages)
+		return nil
+	}
+
+	if config.Graph != "" {
+		return graph(config, dir, releases, tagsToMilestones, tagsToPackages, tagsToImages)
+	}
+
+	if config.PreviewLinks {
+		return previewLinks(config, readClient, releases, tagsToPackages)
+	}
+
+	if config.Report {
+		return report(config, readClient, releases, tagsToImages)
+	}
+
+	if config.Audit {
+		return audit(config, readClient, releases, tagsToImages)
+	}
 
-		tagsToImages = mapImagesToTags(images, releases)
+	if config.DeleteOrphanedLinks {
+		return deleteOrphanedLinks(config, client, readClient, releases, tagsToPackages)
+	}
+
+	if config.ExportGitHub != "" {
+		exportPath := config.ExportGitHub
+		if !filepath.IsAbs(exportPath) {
+			exportPath = filepath.Join(dir, exportPath)
+		}
+		errE = exportGitHub(config, exportPath, selected, tagsToImages)
+		if errE != nil {
+			return errE
+		}
+		if config.ExportGitHubOnly {
+			return nil
+		}
 	}
 
 	tagsToDates := mapTagsToDates(tags)
 
-	for _, release := range releases {
-		errE = Upsert(
-			config, client, release, tagsToDates[release.Tag],
-			tagsToMilestones[release.Tag], tagsToPackages[release.Tag], tagsToImages[release.Tag],
-		)
+	if config.DateThreshold >= 0 {
+		warnReleasesDates(releases, tagsToDates, config.DateThreshold, warnings)
+	}
+
+	if config.DetectMovedTags {
+		errE = warnMovedTags(config, readClient, selected, tagsToDates, warnings)
+		if errE != nil {
+			return errE
+		}
+	}
+
+	var state *syncState
+	statePath := config.StateFile
+	if statePath != "" {
+		if !filepath.IsAbs(statePath) {
+			statePath = filepath.Join(dir, statePath)
+		}
+		state, errE = readSyncState(statePath)
+		if errE != nil {
+			return errE
+		}
+		state.prune(releases)
+	}
+
+	var syncManifest *manifest
+	manifestPath := config.Manifest
+	if manifestPath != "" {
+		if !filepath.IsAbs(manifestPath) {
+			manifestPath = filepath.Join(dir, manifestPath)
+		}
+		syncManifest = &manifest{
+			Releases: []manifestRelease{},
+		}
+	}
+
+	// Unless failing fast, we continue syncing other releases when syncing a release fails.
+	failed := []error{}
+	failedTags := []string{}
+	for i, release := range selected {
+		fmt.Printf("[%d/%d] Syncing GitLab release for tag \"%s\".\n", i+1, len(selected), release.Tag)
+
+		releasedAt := releaseTime(release, tagsToDates[release.Tag])
+		milestones, packages, images := tagsToMilestones[release.Tag], tagsToPackages[release.Tag], tagsToImages[release.Tag]
+
+		var manifestEntry manifestRelease
+		if syncManifest != nil {
+			manifestEntry, errE = newManifestRelease(config, release, releasedAt, milestones, packages, images)
+			if errE != nil {
+				return errE
+			}
+		}
+
+		var hash string
+		if state != nil {
+			hash, errE = releaseHash(config, release, releasedAt, milestones, packages, images)
+			if errE != nil {
+				return errE
+			}
+			if state.Releases[release.Tag] == hash {
+				fmt.Printf("GitLab release for tag \"%s\" has already been synced, skipping.\n", release.Tag)
+				if syncManifest != nil {
+					syncManifest.Releases = append(syncManifest.Releases, manifestEntry)
+				}
+				continue
+			}
+		}
+
+		errE = nil
+		if linksClient != nil {
+			errE = verifyLinks(config, linksClient, gitLabLinksClient, release, packages, warnings)
+		}
+		if errE == nil {
+			errE = runReleaseHook(config, "pre-release", config.PreReleaseCommand, dir, release, images, warnings)
+		}
+		if errE == nil {
+			errE = Upsert(config, client, readClient, release, releasedAt, milestones, packages, images)
+		}
+		if errE == nil && config.CheckLinkCount {
+			errE = checkLinkCount(config, readClient, release, packages, warnings)
+		}
+		if errE == nil {
+			errE = runReleaseHook(config, "post-release", config.PostReleaseCommand, dir, release, images, warnings)
+		}
+		if errE != nil {
+			if config.FailFast {
+				return errE
+			}
+			errors.Details(errE)["tag"] = release.Tag
+			fmt.Fprintf(os.Stderr, "Syncing GitLab release for tag \"%s\" failed: %s\n", release.Tag, errE.Error())
+			failed = append(failed, errE)
+			failedTags = append(failedTags, release.Tag)
+			continue
+		}
+
+		if syncManifest != nil {
+			syncManifest.Releases = append(syncManifest.Releases, manifestEntry)
+		}
+
+		// With NoCreate missing releases are not created, so we cannot record them as synced.
+		if state != nil && !config.NoCreate {
+			state.Releases[release.Tag] = hash
+			errE = state.write(statePath)
+			if errE != nil {
+				return errE
+			}
+		}
+	}
+
+	// The manifest is written even if syncing some releases failed and lists only those which succeeded.
+	if syncManifest != nil {
+		errE = syncManifest.write(manifestPath)
 		if errE != nil {
 			return errE
 		}
 	}
 
-	errE = DeleteAllExcept(config, client, releases)
+	// We do not delete any releases if syncing some releases failed.
+	if len(failed) > 0 {
+		errE = errors.WithMessage(errors.Join(failed...), "failed to sync GitLab releases")
+		errors.Details(errE)["tags"] = failedTags
+		return errE
+	}
+
+	// When syncing only one release or only changed releases, other releases are left as they are.
+	if config.Tag != "" || config.ChangedOnly {
+		return nil
+	}
+
+	errE = DeleteAllExcept(config, client, readClient, releases)
 	if errE != nil {
 		return errE
 	}
@@ -2,16 +2,26 @@ package release
 
 import (
 	_ "embed"
+	"encoding/json"
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync"
 	"testing"
 	"time"
+	"unicode/utf8"
 
+	"github.com/alecthomas/kong"
 	"github.com/go-git/go-git/v5"
+	"github.com/go-git/go-git/v5/plumbing"
 	"github.com/go-git/go-git/v5/plumbing/object"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	"github.com/xanzy/go-gitlab"
+	changelog "github.com/xmidt-org/gokeepachangelog"
 	"gitlab.com/tozd/go/errors"
 )
 
@@ -20,6 +30,12 @@ import (
 //go:embed testdata/changelog.md
 var testChangelog []byte
 
+//go:embed testdata/changelog-custom.md
+var testChangelogCustom []byte
+
+//go:embed testdata/changelog-sections.md
+var testChangelogSections []byte
+
 func mustParse(s string) time.Time {
 	t, err := time.Parse("2006-01-02 15:04:05 -0700 MST", s)
 	if err != nil {
@@ -35,27 +51,614 @@ func TestChangelogReleases(t *testing.T) {
 	changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
 	err := os.WriteFile(changelogPath, testChangelog, 0o600)
 	require.NoError(t, err)
-	releases, err := changelogReleases(changelogPath)
+	releases, front, err := changelogReleases(changelogPath, []string{"Unreleased"})
 	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, &frontMatter{}, front)
+	assert.Equal(t, "[1.0.0] - 2017-06-20", releases[0].Title)
 	for i := range releases {
 		releases[i].Changes = ""
+		releases[i].References = nil
+		releases[i].Title = ""
+	}
+	assert.Equal(t, []Release{
+		{Tag: "v1.0.0", Date: mustParse("2017-06-20 00:00:00 +0000 UTC")},
+		{Tag: "v0.3.0", Date: mustParse("2015-12-03 00:00:00 +0000 UTC")},
+		{Tag: "v0.2.0", Date: mustParse("2015-10-06 00:00:00 +0000 UTC")},
+		{Tag: "v0.1.0", Date: mustParse("2015-10-06 00:00:00 +0000 UTC")},
+		{Tag: "v0.0.8", Date: mustParse("2015-02-17 00:00:00 +0000 UTC")},
+		{Tag: "v0.0.7", Date: mustParse("2015-02-16 00:00:00 +0000 UTC")},
+		{Tag: "v0.0.6", Date: mustParse("2014-12-12 00:00:00 +0000 UTC")},
+		{Tag: "v0.0.5", Date: mustParse("2014-08-09 00:00:00 +0000 UTC")},
+		{Tag: "v0.0.4", Date: mustParse("2014-08-09 00:00:00 +0000 UTC")},
+		{Tag: "v0.0.3", Date: mustParse("2014-08-09 00:00:00 +0000 UTC")},
+		{Tag: "v0.0.2", Date: mustParse("2014-07-10 00:00:00 +0000 UTC")},
+		{Tag: "v0.0.1", Date: mustParse("2014-05-31 00:00:00 +0000 UTC")},
+	}, releases)
+}
+
+func TestChangelogReleasesCustomSections(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+	err := os.WriteFile(changelogPath, testChangelogCustom, 0o600)
+	require.NoError(t, err)
+	releases, _, err := changelogReleases(changelogPath, []string{"Unreleased"})
+	require.NoError(t, err, "% -+#.1v", err)
+	require.Len(t, releases, 2)
+	assert.Equal(t, "### Migration Notes\nConfiguration file has been renamed.\n- Rename `config.yml` to `settings.yml`.\n"+
+		"### Changed\n- Configuration file name.\n### Known Issues\n- Slow startup on Windows.", releases[0].Changes)
+	assert.Equal(t, "### Added\n- Initial release.\n### Acknowledgements\n- Thanks to all contributors.", releases[1].Changes)
+}
+
+func TestChangelogAtRef(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	repository, err := git.PlainInit(tempDir, false)
+	require.NoError(t, err)
+	workTree, err := repository.Worktree()
+	require.NoError(t, err)
+	err = os.Mkdir(filepath.Join(tempDir, "docs"), 0o700)
+	require.NoError(t, err)
+	changelogPath := filepath.Join(tempDir, "docs", "CHANGELOG.md")
+	author := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+
+	err = os.WriteFile(changelogPath, []byte("# Changelog\n\n## [1.0.0] - 2023-01-01\n### Added\n- Feature.\n"), 0o600)
+	require.NoError(t, err)
+	_, err = workTree.Add("docs/CHANGELOG.md")
+	require.NoError(t, err)
+	commit, err := workTree.Commit("First.", &git.CommitOptions{Author: author}) //nolint:exhaustruct
+	require.NoError(t, err)
+	_, err = repository.CreateTag("v1.0.0", commit, nil)
+	require.NoError(t, err)
+
+	err = os.WriteFile(changelogPath, []byte("# Changelog\n\n## [1.1.0] - 2023-02-01\n### Fixed\n- Bug.\n"), 0o600)
+	require.NoError(t, err)
+
+	data, errE := changelogAtRef(changelogPath, "v1.0.0")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	releases, _, errE := parseChangelogReleases(data, changelogPath, []string{"Unreleased"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	require.Len(t, releases, 1)
+	assert.Equal(t, "v1.0.0", releases[0].Tag)
+
+	_, errE = changelogAtRef(changelogPath, "v2.0.0")
+	assert.EqualError(t, errE, "cannot resolve git ref: reference not found")
+
+	_, errE = changelogAtRef(filepath.Join(tempDir, "CHANGES.md"), "v1.0.0")
+	assert.ErrorContains(t, errE, "changelog not found at git ref")
+	assert.Equal(t, "CHANGES.md", errors.AllDetails(errE)["path"])
+}
+
+func TestChangedReleaseTags(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	repository, err := git.PlainInit(tempDir, false)
+	require.NoError(t, err)
+	workTree, err := repository.Worktree()
+	require.NoError(t, err)
+	changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+	author := &object.Signature{Name: "John Doe", Email: "john@doe.org", When: time.Now()}
+
+	err = os.WriteFile(changelogPath, []byte("# Changelog\n\n## [Unreleased]\n\n"+
+		"## [1.0.0] - 2023-01-01\n### Added\n- Feature.\n\n"+
+		"## [0.2.0] - 2022-12-01\n### Added\n- Another.\n\n"+
+		"## [0.1.0] - 2022-11-01\n### Added\n- Initial.\n"), 0o600)
+	require.NoError(t, err)
+	_, err = workTree.Add("CHANGELOG.md")
+	require.NoError(t, err)
+	base, err := workTree.Commit("First.", &git.CommitOptions{Author: author}) //nolint:exhaustruct
+	require.NoError(t, err)
+
+	// A new release is added, an existing one is changed and another one is yanked.
+	err = os.WriteFile(changelogPath, []byte("# Changelog\n\n## [Unreleased]\n- Work in progress.\n\n"+
+		"## [1.1.0] - 2023-02-01\n### Fixed\n- Bug.\n\n"+
+		"## [1.0.0] - 2023-01-01\n### Added\n- Feature, now documented.\n\n"+
+		"## [0.2.0] - 2022-12-01 [YANKED]\n### Added\n- Another.\n\n"+
+		"## [0.1.0] - 2022-11-01\n### Added\n- Initial.\n"), 0o600)
+	require.NoError(t, err)
+
+	releases, _, errE := changelogReleases(changelogPath, []string{"Unreleased"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	changed, errE := changedReleaseTags(changelogPath, base.String(), releases, []string{"Unreleased"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []string{"v1.1.0", "v1.0.0", "v0.2.0"}, changed)
+
+	// Nothing changed compared to the working tree itself.
+	_, err = workTree.Add("CHANGELOG.md")
+	require.NoError(t, err)
+	head, err := workTree.Commit("Second.", &git.CommitOptions{Author: author}) //nolint:exhaustruct
+	require.NoError(t, err)
+	changed, errE = changedReleaseTags(changelogPath, head.String(), releases, []string{"Unreleased"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Empty(t, changed)
+
+	_, errE = changedReleaseTags(changelogPath, "", releases, []string{"Unreleased"})
+	assert.EqualError(t, errE, "base git ref is required to sync only changed releases")
+}
+
+func TestChangelogReleasesAllSections(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+	err := os.WriteFile(changelogPath, testChangelogSections, 0o600)
+	require.NoError(t, err)
+	releases, _, err := changelogReleases(changelogPath, []string{"Unreleased"})
+	require.NoError(t, err, "% -+#.1v", err)
+	require.Len(t, releases, 2)
+	// All standard sections are preserved in their order, together with nested lists.
+	assert.Equal(t, "### Security\n- Fix XSS in the release description.\n"+
+		"### Added\n- New `--flag` option.\n  - With a nested item.\n"+
+		"### Changed\n- Default timeout is now 30 seconds.\n"+
+		"### Deprecated\n- The `--old-flag` option.\n"+
+		"### Removed\n- Support for Go 1.19.\n"+
+		"### Fixed\n- Crash on empty changelog.", releases[0].Changes)
+	assert.Equal(t, "### Added\n- Initial release.", releases[1].Changes)
+}
+
+func TestReleaseHeading(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		release changelog.Release
+		heading string
+		body    []string
+	}{
+		{
+			// Body starts with the heading.
+			changelog.Release{ //nolint:exhaustruct
+				Title:   "[1.0.0] - 2017-06-20",
+				Version: "1.0.0",
+				Body:    []string{"## [1.0.0] - 2017-06-20", "### Added", "- Feature."},
+			},
+			"## [1.0.0] - 2017-06-20",
+			[]string{"### Added", "- Feature."},
+		},
+		{
+			// Body does not contain the heading.
+			changelog.Release{ //nolint:exhaustruct
+				Title:   "[1.0.0] - 2017-06-20",
+				Version: "1.0.0",
+				Body:    []string{"### Added", "- Feature."},
+			},
+			"## [1.0.0] - 2017-06-20",
+			[]string{"### Added", "- Feature."},
+		},
+		{
+			// The first content line is a heading, but not one with the version.
+			changelog.Release{ //nolint:exhaustruct
+				Title:   "[1.0.0] - 2017-06-20",
+				Version: "1.0.0",
+				Body:    []string{"### Fixed", "- Bug."},
+			},
+			"## [1.0.0] - 2017-06-20",
+			[]string{"### Fixed", "- Bug."},
+		},
+		{
+			changelog.Release{ //nolint:exhaustruct
+				Title:   "[1.0.0] - 2017-06-20",
+				Version: "1.0.0",
+				Body:    []string{},
+			},
+			"## [1.0.0] - 2017-06-20",
+			[]string{},
+		},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			heading, body := releaseHeading(tt.release)
+			assert.Equal(t, tt.heading, heading)
+			assert.Equal(t, tt.body, body)
+		})
+	}
+}
+
+func TestChangelogReleasesUnreleasedHeadings(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+	err := os.WriteFile(changelogPath, []byte("# Changelog\n\n## [Sin publicar]\n\n## [1.0.0] - 2017-06-20\n### Added\n- Feature.\n"), 0o600)
+	require.NoError(t, err)
+
+	_, _, errE := changelogReleases(changelogPath, []string{"Unreleased"})
+	assert.EqualError(t, errE, "release in the changelog is missing date")
+
+	releases, _, errE := changelogReleases(changelogPath, []string{"Unreleased", "sin publicar"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	require.Len(t, releases, 1)
+	assert.Equal(t, "v1.0.0", releases[0].Tag)
+}
+
+func TestChangelogReleasesAscending(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+	err := os.WriteFile(changelogPath, []byte("# Changelog\n\n"+
+		"## [0.1.0] - 2017-06-01\n### Added\n- Initial release.\n\n"+
+		"## [1.0.0-rc.1] - 2017-06-20\n### Added\n- Candidate.\n\n"+
+		"## [0.2.0] - 2017-06-10\n### Added\n- Feature.\n\n"+
+		"## [1.0.0] - 2017-06-20\n### Added\n- Final.\n"), 0o600)
+	require.NoError(t, err)
+
+	releases, _, errE := changelogReleases(changelogPath, []string{"Unreleased"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	tags := []string{}
+	for _, release := range releases {
+		tags = append(tags, release.Tag)
+	}
+	assert.Equal(t, []string{"v1.0.0", "v1.0.0-rc.1", "v0.2.0", "v0.1.0"}, tags)
+	assert.Equal(t, "### Added\n- Final.", releases[0].Changes)
+	assert.Equal(t, "### Added\n- Initial release.", releases[3].Changes)
+}
+
+func TestLatestBadge(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{LatestBadge: true}
+	descriptions := func(releases []Release) map[string]string {
+		result := map[string]string{}
+		for _, release := range releases {
+			description, errE := releaseDescription(config, release, nil)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			result[release.Tag] = description
+		}
+		return result
+	}
+
+	releases := []Release{
+		{Tag: "v1.0.0", Changes: "- Feature."},
+		{Tag: "v0.1.0", Changes: "- Initial release."},
+	}
+	markLatestRelease(releases)
+	assert.Equal(t, map[string]string{
+		"v1.0.0": descriptionMarker + "\n\n" + latestBadge + "\n\n- Feature.",
+		"v0.1.0": descriptionMarker + "\n\n- Initial release.",
+	}, descriptions(releases))
+
+	// The badge moves when a newer release is added. Pre-releases and yanked releases are ignored.
+	releases = append([]Release{
+		{Tag: "v2.0.0-rc.1", Changes: "- Candidate."},
+		{Tag: "v1.2.0", Changes: "- Broken.", Yanked: true},
+		{Tag: "v1.1.0", Changes: "- Another feature."},
+	}, releases...)
+	markLatestRelease(releases)
+	assert.Equal(t, map[string]string{
+		"v2.0.0-rc.1": descriptionMarker + "\n\n- Candidate.",
+		"v1.2.0":      descriptionMarker + "\n\n- Broken.",
+		"v1.1.0":      descriptionMarker + "\n\n" + latestBadge + "\n\n- Another feature.",
+		"v1.0.0":      descriptionMarker + "\n\n- Feature.",
+		"v0.1.0":      descriptionMarker + "\n\n- Initial release.",
+	}, descriptions(releases))
+
+	// Without the option, the badge is not shown.
+	description, errE := releaseDescription(&Config{}, releases[2], nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, descriptionMarker+"\n\n- Another feature.", description)
+}
+
+func TestCompareVersions(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		a      string
+		b      string
+		result int
+	}{
+		{"1.0.0", "1.0.0", 0},
+		{"1.0.0", "2.0.0", -1},
+		{"1.10.0", "1.9.0", 1},
+		{"1.0.0-alpha", "1.0.0", -1},
+		{"1.0.0-alpha", "1.0.0-alpha.1", -1},
+		{"1.0.0-alpha.1", "1.0.0-alpha.beta", -1},
+		{"1.0.0-beta.11", "1.0.0-beta.2", 1},
+		{"1.0.0-rc.1", "1.0.0-beta", 1},
+		{"1.0.0+build", "1.0.0", 0},
+		{"foo", "bar", 1},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.result, compareVersions(tt.a, tt.b))
+			assert.Equal(t, -tt.result, compareVersions(tt.b, tt.a))
+		})
+	}
+}
+
+func TestChangelogReleasesTime(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+	err := os.WriteFile(changelogPath, []byte("# Changelog\n\n"+
+		"## [1.3.0] - 2023-03-01T08:00:00+02:00 [YANKED]\n### Fixed\n- Another bug.\n\n"+
+		"## [1.2.0] - 2023-02-01T14:30\n### Fixed\n- Bug.\n\n"+
+		"## [1.1.0] - 2023-01-15T14:30:00Z\n### Added\n- Feature.\n\n"+
+		"## [1.0.0] - 2023-01-01\n### Added\n- Initial release.\n"), 0o600)
+	require.NoError(t, err)
+
+	releases, _, errE := changelogReleases(changelogPath, []string{"Unreleased"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	require.Len(t, releases, 4)
+
+	assert.Equal(t, "[1.3.0] - 2023-03-01T08:00:00+02:00 [YANKED]", releases[0].Title)
+	assert.True(t, releases[0].Yanked)
+	assert.True(t, releases[0].HasTime)
+	assert.True(t, mustParse("2023-03-01 06:00:00 +0000 UTC").Equal(releases[0].Date))
+	assert.Equal(t, "### Fixed\n- Another bug.", releases[0].Changes)
+
+	assert.True(t, releases[1].HasTime)
+	assert.Equal(t, mustParse("2023-02-01 14:30:00 +0000 UTC"), releases[1].Date)
+
+	assert.Equal(t, "[1.1.0] - 2023-01-15T14:30:00Z", releases[2].Title)
+	assert.True(t, releases[2].HasTime)
+	assert.Equal(t, mustParse("2023-01-15 14:30:00 +0000 UTC"), releases[2].Date)
+
+	// Without time, it is midnight of the date.
+	assert.Equal(t, "[1.0.0] - 2023-01-01", releases[3].Title)
+	assert.False(t, releases[3].HasTime)
+	assert.Equal(t, mustParse("2023-01-01 00:00:00 +0000 UTC"), releases[3].Date)
+
+	// Time from the changelog takes precedence over the git tag date, the date alone does not.
+	tagDate := mustParse("2023-01-16 10:00:00 +0000 UTC")
+	assert.Equal(t, releases[2].Date, *releaseTime(releases[2], &tagDate))
+	assert.Equal(t, tagDate, *releaseTime(releases[3], &tagDate))
+	assert.Equal(t, releases[3].Date, *releaseTime(releases[3], nil))
+
+	err = os.WriteFile(changelogPath, []byte("# Changelog\n\n## [1.0.0] - 2023-01-01T25:00:00Z\n### Added\n- Initial release.\n"), 0o600)
+	require.NoError(t, err)
+	_, _, errE = changelogReleases(changelogPath, []string{"Unreleased"})
+	assert.EqualError(t, errE, "invalid release time in the changelog")
+	assert.Equal(t, "1.0.0", errors.AllDetails(errE)["release"])
+}
+
+func TestChangelogReleasesLineEndings(t *testing.T) {
+	t.Parallel()
+
+	for _, lineEnding := range []string{"\r\n", "\r"} {
+		lineEnding := lineEnding
+
+		t.Run(fmt.Sprintf("%q", lineEnding), func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+			data := "---\nnormalize-markdown: true\n---\n" + string(testChangelogCustom)
+			err := os.WriteFile(changelogPath, []byte(strings.ReplaceAll(data, "\n", lineEnding)), 0o600)
+			require.NoError(t, err)
+			releases, front, err := changelogReleases(changelogPath, []string{"Unreleased"})
+			require.NoError(t, err, "% -+#.1v", err)
+			assert.Equal(t, gitlab.Bool(true), front.NormalizeMarkdown)
+			require.Len(t, releases, 2)
+			assert.Equal(t, "### Added\n- Initial release.\n### Acknowledgements\n- Thanks to all contributors.", releases[1].Changes)
+			assert.Equal(t, "[1.0.0] - 2023-01-01", releases[1].Title)
+			for _, release := range releases {
+				assert.NotContains(t, release.Changes, "\r")
+			}
+		})
+	}
+}
+
+func TestChangelogReleasesErrors(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name      string
+		changelog string
+		err       string
+		line      int
+	}{
+		{
+			"v prefix",
+			"# Changelog\n\n## [Unreleased]\n\n## [1.0.0] - 2017-06-20\n### Added\n- Feature.\n\n## [v0.1.0] - 2017-06-19\n### Added\n- Feature.\n",
+			`release in the changelog starts with "v", but it should not`,
+			9,
+		},
+		{
+			"missing date",
+			"# Changelog\n\n## [1.0.0] - 2017-06-20\n### Added\n- Feature.\n\n## [0.1.0]\n### Added\n- Feature.\n",
+			"release in the changelog is missing date",
+			7,
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%s", tt.name), func(t *testing.T) {
+			t.Parallel()
+
+			tempDir := t.TempDir()
+			changelogPath := filepath.Join(tempDir, "CHANGELOG.md")
+			err := os.WriteFile(changelogPath, []byte(tt.changelog), 0o600)
+			require.NoError(t, err)
+			_, _, errE := changelogReleases(changelogPath, []string{"Unreleased"})
+			assert.EqualError(t, errE, tt.err)
+			assert.Equal(t, tt.line, errors.AllDetails(errE)["line"])
+			assert.Equal(t, changelogPath, errors.AllDetails(errE)["path"])
+		})
 	}
+}
+
+func TestCheckNotes(t *testing.T) {
+	t.Parallel()
+
+	err := checkNotes([]Release{{Tag: "v1.0.0", Changes: "### Added\n- Feature."}})
+	assert.NoError(t, err, "% -+#.1v", err)
+
+	err = checkNotes([]Release{
+		{Tag: "v1.0.0", Changes: "### Added\n- Feature."},
+		{Tag: "v0.2.0", Changes: ""},
+		{Tag: "v0.1.0", Changes: " \n"},
+	})
+	assert.EqualError(t, err, "found changelog releases without notes")
+	assert.Equal(t, []string{"0.2.0", "0.1.0"}, errors.AllDetails(err)["releases"])
+}
+
+func TestReleasesMetadata(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	metadataPath := filepath.Join(tempDir, "metadata.yml")
+	err := os.WriteFile(metadataPath, []byte("1.0.0:\n  author: John Doe\n0.3.0:\n  ticket: 123\n"), 0o600)
+	require.NoError(t, err)
+	metadata, err := releasesMetadata(metadataPath)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, map[string]map[string]interface{}{
+		"1.0.0": {"author": "John Doe"},
+		"0.3.0": {"ticket": 123},
+	}, metadata)
+
+	releases := []Release{{Tag: "v1.0.0"}, {Tag: "v0.2.0"}}
+	applyMetadata(releases, metadata, nil)
 	assert.Equal(t, []Release{
-		{"v1.0.0", "", false},
-		{"v0.3.0", "", false},
-		{"v0.2.0", "", false},
-		{"v0.1.0", "", false},
-		{"v0.0.8", "", false},
-		{"v0.0.7", "", false},
-		{"v0.0.6", "", false},
-		{"v0.0.5", "", false},
-		{"v0.0.4", "", false},
-		{"v0.0.3", "", false},
-		{"v0.0.2", "", false},
-		{"v0.0.1", "", false},
+		{Tag: "v1.0.0", Meta: map[string]interface{}{"author": "John Doe"}},
+		{Tag: "v0.2.0"},
 	}, releases)
 }
 
+func TestReleaseDescription(t *testing.T) {
+	t.Parallel()
+
+	release := Release{
+		Tag:     "v1.0.0",
+		Changes: "### Added\n- Feature.\n",
+		Meta:    map[string]interface{}{"author": "John Doe"},
+	}
+
+	description, err := releaseDescription(&Config{}, release, nil)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\n### Added\n- Feature.", description)
+
+	description, err = releaseDescription(&Config{}, release, []string{"registry.example.com/foo:1.0.0"})
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\n"+
+		"##### Docker images\n* `registry.example.com/foo:1.0.0`\n\n### Added\n- Feature.", description)
+
+	description, err = releaseDescription(&Config{DescriptionTemplate: "Release {{.Version}} by {{.Meta.author}}.\n\n{{.Changes}}"}, release, nil)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\nRelease 1.0.0 by John Doe.\n\n### Added\n- Feature.", description)
+
+	// Whitespace around the description is trimmed and blank lines are collapsed, unless configured otherwise.
+	source := "\n\n{{.Version}}\n\n\n\n{{.Changes}}\n\n"
+	description, err = releaseDescription(&Config{DescriptionTemplate: source}, release, nil)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\n1.0.0\n\n### Added\n- Feature.", description)
+	description, err = releaseDescription(&Config{DescriptionTemplate: source, KeepBlankLines: true}, release, nil)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\n1.0.0\n\n\n\n### Added\n- Feature.", description)
+
+	release.Changes = "### Added\n- Feature.\n" + strings.Repeat("- Another feature.\n", 10)
+	release.References = map[string]string{"1.0.0": "https://example.com/compare/v0.1.0...v1.0.0"}
+	description, err = releaseDescription(&Config{MaxDescriptionLength: 200}, release, nil)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\n### Added\n- Feature.\n\n"+
+		"… (truncated, see [full changelog](https://example.com/compare/v0.1.0...v1.0.0))", description)
+
+	// The header follows the marker and the footer survives truncation.
+	description, err = releaseDescription(&Config{DescriptionHeader: "Header.\n", DescriptionFooter: "Questions? Email us.", MaxDescriptionLength: 250}, release, nil)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\nHeader.\n\n### Added\n- Feature.\n- Another feature.\n\n"+
+		"… (truncated, see [full changelog](https://example.com/compare/v0.1.0...v1.0.0))\n\nQuestions? Email us.", description)
+	assert.LessOrEqual(t, utf8.RuneCountInString(description), 250)
+
+	// Lengths are counted in characters, also for the footer.
+	description, err = releaseDescription(&Config{DescriptionFooter: "Vprašanja? Pišite nam. ✉", MaxDescriptionLength: 250}, release, nil)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\n### Added\n- Feature.\n- Another feature.\n\n"+
+		"… (truncated, see [full changelog](https://example.com/compare/v0.1.0...v1.0.0))\n\nVprašanja? Pišite nam. ✉", description)
+	assert.LessOrEqual(t, utf8.RuneCountInString(description), 250)
+
+	// The marker is kept even if there is no room left for it.
+	description, err = releaseDescription(&Config{DescriptionFooter: "Questions? Email us.", MaxDescriptionLength: 10}, release, nil)
+	require.NoError(t, err, "% -+#.1v", err)
+	assert.Equal(t, "<!-- Automatically generated by gitlab.com/tozd/gitlab/release tool. DO NOT EDIT. -->\n\nQuestions? Email us.", description)
+	assert.True(t, isManaged(&gitlab.Release{Description: description})) //nolint:exhaustruct
+}
+
+func TestReleaseName(t *testing.T) {
+	t.Parallel()
+
+	release := Release{
+		Tag:   "v1.0.0",
+		Title: "[1.0.0] - 2017-06-20",
+	}
+	yanked := release
+	yanked.Yanked = true
+
+	tests := []struct {
+		template string
+		release  Release
+		expected string
+	}{
+		{"", release, "v1.0.0"},
+		{"", yanked, "v1.0.0 [YANKED]"},
+		{"Release {{.Version}}", release, "Release 1.0.0"},
+		{"{{.Title}}", release, "[1.0.0] - 2017-06-20"},
+		{"Release {{.Version}}{{if .Yanked}} (yanked){{end}}", yanked, "Release 1.0.0 (yanked)"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			name, errE := releaseName(&Config{NameTemplate: tt.template}, tt.release)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, tt.expected, name)
+		})
+	}
+
+	_, errE := releaseName(&Config{NameTemplate: "{{if .Yanked}}yanked{{end}}"}, release)
+	assert.EqualError(t, errE, "name template rendered an empty name")
+}
+
+func TestReleaseTagMessage(t *testing.T) {
+	t.Parallel()
+
+	release := Release{
+		Tag:    "v1.0.0",
+		Title:  "[1.0.0] - 2017-06-20",
+		Yanked: true,
+	}
+
+	tests := []struct {
+		config   Config
+		expected string
+	}{
+		{Config{}, "v1.0.0 [YANKED]"},
+		{Config{NameTemplate: "Release {{.Version}}"}, "Release 1.0.0"},
+		{Config{TagMessageTemplate: "{{.Title}}"}, "[1.0.0] - 2017-06-20"},
+		{Config{NameTemplate: "Release {{.Version}}", TagMessageTemplate: "Version {{.Version}}"}, "Version 1.0.0"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			message, errE := releaseTagMessage(&tt.config, release)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, tt.expected, message)
+		})
+	}
+}
+
 func TestGitTags(t *testing.T) {
 	t.Parallel()
 
@@ -95,7 +698,18 @@ func TestGitTags(t *testing.T) {
 		_, err = repository.CreateTag(tag.Name, commit, opts)
 		require.NoError(t, err)
 	}
-	tags, err := gitTags(tempDir)
+	// Lightweight tags pointing to a tree and a blob are skipped.
+	head, err := repository.Head()
+	require.NoError(t, err)
+	commit, err := repository.CommitObject(head.Hash())
+	require.NoError(t, err)
+	file, err := commit.File("file.txt")
+	require.NoError(t, err)
+	err = repository.Storer.SetReference(plumbing.NewHashReference("refs/tags/tree", commit.TreeHash))
+	require.NoError(t, err)
+	err = repository.Storer.SetReference(plumbing.NewHashReference("refs/tags/blob", file.Hash))
+	require.NoError(t, err)
+	tags, err := gitTags(tempDir, nil)
 	require.NoError(t, err, "% -+#.1v", err)
 	for i, tag := range tags {
 		// We change dates so that assert does not fail on different location representation.
@@ -104,23 +718,150 @@ func TestGitTags(t *testing.T) {
 	assert.ElementsMatch(t, expectedTags, tags)
 }
 
-func TestCompareReleasesTags(t *testing.T) {
+func TestCreateMissingTags(t *testing.T) {
 	t.Parallel()
 
-	err := compareReleasesTags(
-		[]Release{},
-		[]Tag{},
-	)
-	assert.NoError(t, err, "% -+#.1v", err)
-
-	err = compareReleasesTags(
-		[]Release{{Tag: "v1.0.0"}},
-		[]Tag{{Name: "v1.0.0"}},
-	)
-	assert.NoError(t, err, "% -+#.1v", err)
-
-	err = compareReleasesTags(
-		[]Release{{Tag: "v1.0.0"}},
+	tempDir := t.TempDir()
+	repository, err := git.PlainInit(tempDir, false)
+	require.NoError(t, err)
+	cfg, err := repository.Config()
+	require.NoError(t, err)
+	cfg.User.Name = "John Doe"
+	cfg.User.Email = "john@doe.org"
+	err = repository.SetConfig(cfg)
+	require.NoError(t, err)
+	workTree, err := repository.Worktree()
+	require.NoError(t, err)
+	filename := filepath.Join(tempDir, "file.txt")
+	commits := []plumbing.Hash{}
+	for _, data := range []string{"first", "second"} {
+		err = os.WriteFile(filename, []byte(data), 0o600)
+		require.NoError(t, err)
+		_, err = workTree.Add("file.txt")
+		require.NoError(t, err)
+		commit, err := workTree.Commit(data, &git.CommitOptions{ //nolint:govet
+			Author: &object.Signature{
+				Name:  "John Doe",
+				Email: "john@doe.org",
+				When:  time.Now(),
+			},
+		})
+		require.NoError(t, err)
+		commits = append(commits, commit)
+	}
+	_, err = repository.CreateTag("v1.0.0", commits[0], nil)
+	require.NoError(t, err)
+
+	tags, errE := gitTags(tempDir, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	releases := []Release{{Tag: "v1.0.0"}, {Tag: "v2.0.0"}}
+	errE = setMissingTagsRefs(tempDir, "HEAD", releases, tags)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "", releases[0].Ref)
+	assert.Equal(t, commits[1].String(), releases[1].Ref)
+
+	// Only refs are set, tags are not yet created.
+	_, err = repository.Tag("v2.0.0")
+	assert.ErrorIs(t, err, git.ErrTagNotFound)
+
+	created, errE := createMissingTags(&Config{}, tempDir, releases, tags)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	require.Len(t, created, 1)
+	assert.Equal(t, "v2.0.0", created[0].Name)
+
+	// Existing tag has not been moved.
+	ref, err := repository.Tag("v1.0.0")
+	require.NoError(t, err)
+	assert.Equal(t, commits[0], ref.Hash())
+
+	// New tag is annotated and points to HEAD.
+	ref, err = repository.Tag("v2.0.0")
+	require.NoError(t, err)
+	tag, err := repository.TagObject(ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, commits[1], tag.Target)
+	assert.Equal(t, "John Doe", tag.Tagger.Name)
+	// The message is the release name by default.
+	assert.Equal(t, "v2.0.0\n", tag.Message)
+
+	releases = []Release{{Tag: "v1.0.0"}, {Tag: "v2.0.0"}, {Tag: "v3.0.0"}}
+	tags, errE = gitTags(tempDir, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	errE = setMissingTagsRefs(tempDir, commits[0].String(), releases, tags)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	created, errE = createMissingTags(&Config{TagMessageTemplate: "Release {{.Version}}"}, tempDir, releases, tags)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	require.Len(t, created, 1)
+	assert.Equal(t, "v3.0.0", created[0].Name)
+	assert.Equal(t, commits[0].String(), releases[2].Ref)
+	ref, err = repository.Tag("v3.0.0")
+	require.NoError(t, err)
+	tag, err = repository.TagObject(ref.Hash())
+	require.NoError(t, err)
+	assert.Equal(t, "Release 3.0.0\n", tag.Message)
+
+	// Branch which exists only on the remote.
+	err = repository.Storer.SetReference(plumbing.NewHashReference("refs/remotes/origin/release", commits[0]))
+	require.NoError(t, err)
+	releases = []Release{{Tag: "v4.0.0"}}
+	errE = setMissingTagsRefs(tempDir, "release", releases, tags)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, commits[0].String(), releases[0].Ref)
+
+	errE = setMissingTagsRefs(tempDir, "unknown", []Release{{Tag: "v5.0.0"}}, tags)
+	assert.EqualError(t, errE, "cannot resolve git ref: reference not found")
+
+	// Unknown ref does not matter when there are no missing tags.
+	errE = setMissingTagsRefs(tempDir, "unknown", []Release{{Tag: "v1.0.0"}}, tags)
+	assert.NoError(t, errE, "% -+#.1v", errE)
+}
+
+func TestCheckReleasesRefs(t *testing.T) {
+	t.Parallel()
+
+	server, requests := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v4/projects/1/repository/commits/pushed":
+			_, _ = w.Write([]byte(`{"id": "pushed"}`))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "404 Commit Not Found"}`))
+		}
+	})
+
+	client, errE := newClient(&Config{BaseURL: server.URL}, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	errE = checkReleasesRefs(client, "1", []Release{{Tag: "v1.0.0"}, {Tag: "v2.0.0", Ref: "pushed"}, {Tag: "v3.0.0", Ref: "pushed"}})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	// Releases without refs are skipped and each ref is checked only once.
+	assert.Equal(t, []string{"GET /api/v4/projects/1/repository/commits/pushed"}, requests())
+
+	errE = checkReleasesRefs(client, "1", []Release{{Tag: "v2.0.0", Ref: "pushed"}, {Tag: "v3.0.0", Ref: "local"}})
+	assert.EqualError(t, errE, "git ref does not exist in GitLab project, push it first")
+	assert.Equal(t, "local", errors.AllDetails(errE)["ref"])
+	assert.Equal(t, "v3.0.0", errors.AllDetails(errE)["tag"])
+}
+
+func TestCompareReleasesTags(t *testing.T) {
+	t.Parallel()
+
+	err := compareReleasesTags(
+		[]Release{},
+		[]Tag{},
+	)
+	assert.NoError(t, err, "% -+#.1v", err)
+
+	err = compareReleasesTags(
+		[]Release{{Tag: "v1.0.0"}},
+		[]Tag{{Name: "v1.0.0"}},
+	)
+	assert.NoError(t, err, "% -+#.1v", err)
+
+	err = compareReleasesTags(
+		[]Release{{Tag: "v1.0.0"}},
 		[]Tag{{Name: "v2.0.0"}},
 	)
 	assert.EqualError(t, err, "found changelog releases not among git tags")
@@ -134,12 +875,67 @@ func TestCompareReleasesTags(t *testing.T) {
 	assert.Equal(t, []string{"v2.0.0"}, errors.AllDetails(err)["tags"])
 }
 
+func TestStripTagPrefixes(t *testing.T) {
+	t.Parallel()
+
+	tags, names, errE := stripTagPrefixes(
+		[]Tag{{Name: "release/1.2.0"}, {Name: "stable/v1.1.0"}, {Name: "v1.0.0"}, {Name: "other/0.1.0"}},
+		[]string{"release/", "stable/"},
+	)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []Tag{{Name: "v1.2.0"}, {Name: "v1.1.0"}, {Name: "v1.0.0"}, {Name: "other/0.1.0"}}, tags)
+	assert.Equal(t, map[string]string{"v1.2.0": "release/1.2.0", "v1.1.0": "stable/v1.1.0"}, names)
+
+	errE = compareReleasesTags([]Release{{Tag: "v1.2.0"}, {Tag: "v1.1.0"}, {Tag: "v1.0.0"}}, tags[:3])
+	assert.NoError(t, errE, "% -+#.1v", errE)
+
+	_, _, errE = stripTagPrefixes(
+		[]Tag{{Name: "v1.0.0"}, {Name: "release/1.0.0"}},
+		[]string{"release/"},
+	)
+	assert.EqualError(t, errE, "multiple git tags map to the same release")
+	assert.Equal(t, []string{"v1.0.0", "release/1.0.0"}, errors.AllDetails(errE)["tags"])
+}
+
+func TestReleaseGitTag(t *testing.T) {
+	t.Parallel()
+
+	assert.Equal(t, "v1.0.0", releaseGitTag(Release{Tag: "v1.0.0"}))
+	assert.Equal(t, "release/1.0.0", releaseGitTag(Release{Tag: "v1.0.0", GitTag: "release/1.0.0"}))
+
+	// GitLab releases are compared by their git tags.
+	extra := extraReleases(
+		&Config{},
+		[]Release{{Tag: "v1.0.0", GitTag: "release/1.0.0"}},
+		[]*gitlab.Release{{TagName: "release/1.0.0"}, {TagName: "v1.0.0"}},
+	)
+	assert.Equal(t, []string{"v1.0.0"}, extra)
+}
+
+func TestCheckSemver(t *testing.T) {
+	t.Parallel()
+
+	err := checkSemver(
+		[]Release{{Tag: "v1.0.0"}, {Tag: "v1.1.0-rc.1+build.5"}},
+		[]Tag{{Name: "v1.0.0"}, {Name: "v1.1.0-rc.1+build.5"}},
+	)
+	assert.NoError(t, err, "% -+#.1v", err)
+
+	err = checkSemver(
+		[]Release{{Tag: "v1.2..0"}, {Tag: "v1.0.0"}},
+		[]Tag{{Name: "v1.02.0"}, {Name: "1.0.0"}, {Name: "v1.0"}, {Name: "v1.0.0"}},
+	)
+	assert.EqualError(t, err, "found versions which are not valid semantic versions")
+	assert.Equal(t, []string{"v1.2..0"}, errors.AllDetails(err)["releases"])
+	assert.Equal(t, []string{"1.0.0", "v1.0", "v1.02.0"}, errors.AllDetails(err)["tags"])
+}
+
 func toStringsMap(inputs []string, tags []string) map[string][]string {
 	releases := make([]Release, len(tags))
 	for i, tag := range tags {
 		releases[i] = Release{Tag: tag}
 	}
-	return mapStringsToTags(inputs, releases)
+	return mapStringsToTags(inputs, releases, false, false, false, tagTransformations)
 }
 
 func toPackagesMap(inputs []string, tags []string) map[string][]string {
@@ -152,7 +948,7 @@ func toPackagesMap(inputs []string, tags []string) map[string][]string {
 		releases[i] = Release{Tag: tag}
 	}
 	result := map[string][]string{}
-	for tag, packages := range mapPackagesToTags(packages, releases) {
+	for tag, packages := range mapPackagesToTags(packages, releases, false, tagTransformations) {
 		result[tag] = make([]string, len(packages))
 		for i, p := range packages {
 			result[tag][i] = p.Version
@@ -196,6 +992,23 @@ func TestMappingToTags(t *testing.T) {
 				"v2.0.0":    {"2.0.0"},
 			},
 		},
+		{
+			[]string{"1_0_0", "1_2_3", "1_2_3-rc", "2.0.0"},
+			[]string{"v1.0.0", "v1.2.3", "v1.2.3-rc", "v2.0.0"},
+			map[string][]string{
+				"v1.0.0":    {"1_0_0"},
+				"v1.2.3":    {"1_2_3"},
+				"v1.2.3-rc": {"1_2_3-rc"},
+				"v2.0.0":    {"2.0.0"},
+			},
+		},
+		{
+			[]string{"v1_2_3", "1_2_4"},
+			[]string{"v1.2.3"},
+			map[string][]string{
+				"v1.2.3": {"v1_2_3"},
+			},
+		},
 	}
 
 	for _, ff := range mappingFuncs {
@@ -216,3 +1029,1698 @@ func TestMappingToTags(t *testing.T) {
 		})
 	}
 }
+
+func TestConfigTagTransformations(t *testing.T) {
+	t.Parallel()
+
+	transformations, errE := configTagTransformations(&Config{}) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Len(t, transformations, len(tagTransformations))
+
+	transformations, errE = configTagTransformations(&Config{ //nolint:exhaustruct
+		StripPrefixes: []string{"release-"},
+		Replacements:  []string{"_:."},
+	})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Len(t, transformations, len(tagTransformations)+1)
+	assert.Equal(t, "1.2.3", transformations[0]("release-1_2_3"))
+
+	releases := []Release{{Tag: "release-1_2_3"}, {Tag: "release-1_3_0"}} //nolint:exhaustruct
+	assert.Equal(t, map[string][]string{
+		"release-1_2_3": {"registry.example.com/foo:1.2.3"},
+		"release-1_3_0": {"registry.example.com/foo:1.3.0"},
+	}, mapImagesToTags([]string{"registry.example.com/foo:1.2.3", "registry.example.com/foo:1.3.0"}, releases, false, transformations))
+	tagsToPackages := mapPackagesToTags([]Package{{ID: 1, Version: "1.2.3"}}, releases, false, transformations) //nolint:exhaustruct
+	assert.Len(t, tagsToPackages["release-1_2_3"], 1)
+
+	_, errE = configTagTransformations(&Config{Replacements: []string{"_"}}) //nolint:exhaustruct
+	assert.EqualError(t, errE, `replacement should be in "old:new" format`)
+}
+
+func TestExactMatchOnly(t *testing.T) {
+	t.Parallel()
+
+	transformations, errE := configTagTransformations(&Config{ExactMatchOnly: true}) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Len(t, transformations, len(exactTagTransformations))
+
+	releases := []Release{{Tag: "v1.0.0"}, {Tag: "v1.0.0-rc.1"}} //nolint:exhaustruct
+	images := []string{
+		"registry.example.com/foo:1.0.0",
+		"registry.example.com/bar:v1.0.0",
+		"registry.example.com/foo:1.0.0-debug",
+		"registry.example.com/foo:1-0-0-rc-1",
+		"registry.example.com:5000/foo",
+	}
+	packages := []Package{
+		{ID: 1, Version: "1.0.0"},              //nolint:exhaustruct
+		{ID: 2, Version: "11.0.0"},             //nolint:exhaustruct
+		{ID: 3, Version: "1.0.0-rc.1+build.1"}, //nolint:exhaustruct
+	}
+	milestones := []string{"V1.0.0", "Release 1.0.0", "1.0"}
+
+	assert.Equal(t, map[string][]string{
+		"v1.0.0": {"registry.example.com/bar:v1.0.0", "registry.example.com/foo:1.0.0"},
+	}, mapImagesToTags(images, releases, true, transformations))
+
+	tagsToPackages := mapPackagesToTags(packages, releases, true, transformations)
+	assert.Equal(t, []Package{{ID: 1, Version: "1.0.0"}}, tagsToPackages["v1.0.0"])                   //nolint:exhaustruct
+	assert.Equal(t, []Package{{ID: 3, Version: "1.0.0-rc.1+build.1"}}, tagsToPackages["v1.0.0-rc.1"]) //nolint:exhaustruct
+
+	assert.Equal(t, map[string][]string{
+		"v1.0.0": {"V1.0.0"},
+	}, mapMilestonesToTags(milestones, releases, true, true, transformations))
+
+	// Without exact matching, substrings and transformed tags match as well.
+	tagsToPackages = mapPackagesToTags(packages, releases, false, tagTransformations)
+	assert.Len(t, tagsToPackages["v1.0.0"], 2)
+	assert.Len(t, mapImagesToTags(images, releases, false, tagTransformations)["v1.0.0"], 3)
+
+	assert.Equal(t, "1.0.0", dockerImageTag("registry.example.com/foo:1.0.0"))
+	assert.Equal(t, "", dockerImageTag("registry.example.com:5000/foo"))
+}
+
+func TestMapPackagesToTagsBuildMetadata(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		inputs  []string
+		tags    []string
+		mapping map[string][]string
+	}{
+		{
+			[]string{"1.2.0+build", "1.2.0-rc.1", "1.2.0"},
+			[]string{"v1.2.0", "v1.2.0-rc.1"},
+			map[string][]string{
+				"v1.2.0":      {"1.2.0", "1.2.0+build"},
+				"v1.2.0-rc.1": {"1.2.0-rc.1"},
+			},
+		},
+		{
+			[]string{"1.2.0-rc.1+build.45", "1.2.0+build.45"},
+			[]string{"v1.2.0", "v1.2.0-rc.1"},
+			map[string][]string{
+				"v1.2.0":      {"1.2.0+build.45"},
+				"v1.2.0-rc.1": {"1.2.0-rc.1+build.45"},
+			},
+		},
+		{
+			[]string{"1.2.0", "1.2.0+build.1.3.0"},
+			[]string{"v1.2.0+build.1", "v1.3.0"},
+			map[string][]string{
+				"v1.2.0+build.1": {"1.2.0", "1.2.0+build.1.3.0"},
+			},
+		},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.mapping, toPackagesMap(tt.inputs, tt.tags))
+		})
+	}
+}
+
+func TestMapStringsToTagsMulti(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		inputs  []string
+		tags    []string
+		mapping map[string][]string
+	}{
+		{[]string{}, []string{}, map[string][]string{}},
+		{
+			[]string{"1.0", "1.0.1", "2.0.0"},
+			[]string{"v1.0.0", "v1.0.1", "v1.0.10", "v2.0.0"},
+			map[string][]string{
+				"v1.0.0":  {"1.0"},
+				"v1.0.1":  {"1.0", "1.0.1"},
+				"v1.0.10": {"1.0"},
+				"v2.0.0":  {"2.0.0"},
+			},
+		},
+		{
+			[]string{"1.0.0-rc", "1.0.0"},
+			[]string{"v1.0.0", "v1.0.0-rc"},
+			map[string][]string{
+				"v1.0.0":    {"1.0.0", "1.0.0-rc"},
+				"v1.0.0-rc": {"1.0.0-rc"},
+			},
+		},
+		{
+			// Nested milestones are all associated, each only once.
+			[]string{"1.2", "1.2.3", "v1.2.3"},
+			[]string{"v1.2.3", "v1.2.4"},
+			map[string][]string{
+				"v1.2.3": {"v1.2.3", "1.2", "1.2.3"},
+				"v1.2.4": {"1.2"},
+			},
+		},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			releases := make([]Release, len(tt.tags))
+			for i, tag := range tt.tags {
+				releases[i] = Release{Tag: tag}
+			}
+			assert.Equal(t, tt.mapping, mapStringsToTags(tt.inputs, releases, true, false, false, tagTransformations))
+		})
+	}
+}
+
+func TestMapMilestonesToTagsCase(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		milestones []string
+		tags       []string
+		multi      bool
+		mapping    map[string][]string
+	}{
+		{
+			[]string{"V1.0.0", "Release 2.0.0-RC", "3.0.0"},
+			[]string{"v1.0.0", "v2.0.0-rc", "v3.0.0"},
+			false,
+			map[string][]string{
+				"v1.0.0":    {"V1.0.0"},
+				"v2.0.0-rc": {"Release 2.0.0-RC"},
+				"v3.0.0":    {"3.0.0"},
+			},
+		},
+		{
+			// Slugified versions are lowercase.
+			[]string{"Sprint 1-0-0-BETA"},
+			[]string{"v1.0.0-beta"},
+			false,
+			map[string][]string{
+				"v1.0.0-beta": {"Sprint 1-0-0-BETA"},
+			},
+		},
+		{
+			[]string{"V1.0"},
+			[]string{"v1.0.0", "v1.0.1"},
+			true,
+			map[string][]string{
+				"v1.0.0": {"V1.0"},
+				"v1.0.1": {"V1.0"},
+			},
+		},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			releases := make([]Release, len(tt.tags))
+			for i, tag := range tt.tags {
+				releases[i] = Release{Tag: tag}
+			}
+			assert.Equal(t, tt.mapping, mapMilestonesToTags(tt.milestones, releases, tt.multi, false, tagTransformations))
+		})
+	}
+}
+
+func TestDiffLinks(t *testing.T) {
+	t.Parallel()
+
+	id1 := 1
+	id2 := 2
+	id3 := 3
+	id4 := 4
+	existing := []link{
+		{Name: "foo/a.txt", ID: &id1},
+		{Name: "bar", ID: &id2},
+		{Name: "foo/c.txt", ID: &id3, Existing: &gitlab.ReleaseLink{
+			ID:             id3,
+			Name:           "foo/c.txt",
+			URL:            "https://gitlab.example.com/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/c%2Etxt",
+			DirectAssetURL: "https://gitlab.example.com/group/project/-/releases/v1.0.0/downloads/foo/c.txt",
+			LinkType:       gitlab.OtherLinkType,
+		}},
+		{Name: "foo/d.txt", ID: &id4, Existing: &gitlab.ReleaseLink{
+			ID:             id4,
+			Name:           "foo/d.txt",
+			URL:            "https://gitlab.example.com/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/d%2Etxt",
+			DirectAssetURL: "https://gitlab.example.com/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/d%2Etxt",
+			LinkType:       gitlab.OtherLinkType,
+		}},
+	}
+	packages := []Package{
+		{ID: 1, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"b.txt", "a.txt", "c.txt", "d.txt"}},
+	}
+
+	config := &Config{BaseURL: "https://gitlab.example.com", Project: "1"}
+	expectedLinks, errE := getExpectedLinks(config, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	diff := diffLinks(config, existing, expectedLinks)
+	names := func(links []link) []string {
+		result := []string{}
+		for _, l := range links {
+			result = append(result, l.Name)
+		}
+		return result
+	}
+	assert.Equal(t, []string{"bar"}, names(diff.Delete))
+	// Link "foo/d.txt" is missing its file path.
+	assert.Equal(t, []string{"foo/a.txt", "foo/d.txt"}, names(diff.Update))
+	assert.Equal(t, []string{"foo/c.txt"}, names(diff.Keep))
+	assert.Equal(t, []string{"foo/b.txt"}, names(diff.Create))
+	assert.Equal(t, &id1, diff.Update[0].ID)
+	assert.Equal(t, "a.txt", *diff.Update[0].File)
+	assert.Equal(t, &id3, diff.Keep[0].ID)
+	assert.Nil(t, diff.Create[0].ID)
+}
+
+func TestCalendarDaysBetween(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		changelogDate string
+		tagDate       string
+		days          int
+	}{
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-01-01 12:00:00 +0000 UTC", 0},
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-01-01 00:00:00 +0000 UTC", 0},
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-01-01 23:59:59 +0000 UTC", 0},
+		// Late in the evening west of UTC it is already the next day in UTC.
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-01-01 23:30:00 -0800 PST", 0},
+		// Early in the morning east of UTC it is still the previous day in UTC.
+		{"2023-01-02 00:00:00 +0000 UTC", "2023-01-02 00:30:00 +0200 EET", 0},
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-01-02 00:00:00 +0000 UTC", 1},
+		{"2023-01-02 00:00:00 +0000 UTC", "2023-01-01 23:59:59 +0000 UTC", -1},
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-06-01 10:00:00 +0200 CEST", 151},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.days, calendarDaysBetween(mustParse(tt.changelogDate), mustParse(tt.tagDate)))
+		})
+	}
+}
+
+func TestRecentlyReleased(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		releasedAt string
+		now        string
+		recent     bool
+	}{
+		{"2023-01-01 12:00:00 +0000 UTC", "2023-01-01 13:00:00 +0000 UTC", true},
+		// Changelog date without time is at midnight UTC, but the release is done later that day.
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-01-01 20:00:00 +0000 UTC", true},
+		// Late in the evening west of UTC it is already the next day in UTC.
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-01-01 20:00:00 -0800 PST", true},
+		// Close in time, but on different calendar days.
+		{"2023-01-01 23:00:00 +0000 UTC", "2023-01-02 01:00:00 +0000 UTC", true},
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-01-02 13:00:00 +0000 UTC", false},
+		{"2023-01-01 00:00:00 +0000 UTC", "2023-06-01 10:00:00 +0200 CEST", false},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.recent, recentlyReleased(mustParse(tt.releasedAt), mustParse(tt.now)))
+		})
+	}
+}
+
+func TestMovedTags(t *testing.T) {
+	t.Parallel()
+
+	date := func(s string) *time.Time {
+		d := mustParse(s)
+		return &d
+	}
+
+	releases := []Release{
+		{Tag: "v1.0.0"}, //nolint:exhaustruct
+		{Tag: "v1.1.0"}, //nolint:exhaustruct
+		{Tag: "v1.2.0"}, //nolint:exhaustruct
+		{Tag: "v1.3.0"}, //nolint:exhaustruct
+		{Tag: "v1.4.0", Date: *date("2023-05-01 18:00:00 +0000 UTC"), HasTime: true}, //nolint:exhaustruct
+		{Tag: "v1.5.0", Date: *date("2023-06-01 18:00:00 +0000 UTC"), HasTime: true}, //nolint:exhaustruct
+	}
+	gitLabReleases := []*gitlab.Release{
+		{TagName: "v1.0.0", ReleasedAt: date("2023-01-01 12:00:00 +0000 UTC")}, //nolint:exhaustruct
+		{TagName: "v1.1.0", ReleasedAt: date("2023-02-01 12:05:00 +0000 UTC")}, //nolint:exhaustruct
+		{TagName: "v1.2.0", ReleasedAt: date("2023-03-01 12:00:00 +0000 UTC")}, //nolint:exhaustruct
+		{TagName: "v1.4.0", ReleasedAt: date("2023-05-01 18:00:00 +0000 UTC")}, //nolint:exhaustruct
+		{TagName: "v1.5.0", ReleasedAt: date("2023-06-01 18:00:00 +0000 UTC")}, //nolint:exhaustruct
+	}
+	tagsToDates := map[string]*time.Time{
+		// Moved.
+		"v1.0.0": date("2023-01-05 12:00:00 +0000 UTC"),
+		// Release created shortly after the tag.
+		"v1.1.0": date("2023-02-01 12:00:00 +0000 UTC"),
+		// Same date in a different timezone.
+		"v1.2.0": date("2023-03-01 13:00:00 +0100 CET"),
+		// No GitLab release yet.
+		"v1.3.0": date("2023-04-01 12:00:00 +0000 UTC"),
+		// The release date is the time from the changelog heading, not the tag date.
+		"v1.4.0": date("2023-05-01 09:00:00 +0000 UTC"),
+		// The release date from the changelog heading does not change when the tag is moved.
+		"v1.5.0": date("2023-06-05 09:00:00 +0000 UTC"),
+	}
+
+	assert.Equal(t, map[string]*time.Time{
+		"v1.0.0": date("2023-01-01 12:00:00 +0000 UTC"),
+	}, movedTags(releases, gitLabReleases, tagsToDates))
+}
+
+func TestReleasesDatesDrift(t *testing.T) {
+	t.Parallel()
+
+	date := func(s string) *time.Time {
+		d := mustParse(s)
+		return &d
+	}
+
+	releases := []Release{
+		{Tag: "v1.0.0", Date: mustParse("2023-01-01 00:00:00 +0000 UTC")}, //nolint:exhaustruct
+		{Tag: "v1.1.0", Date: mustParse("2023-02-01 00:00:00 +0000 UTC")}, //nolint:exhaustruct
+		{Tag: "v1.2.0", Date: mustParse("2023-03-10 00:00:00 +0000 UTC")}, //nolint:exhaustruct
+		{Tag: "v1.3.0", Date: mustParse("2023-04-01 00:00:00 +0000 UTC")}, //nolint:exhaustruct
+		{Tag: "v1.4.0", Date: mustParse("2023-05-01 00:00:00 +0000 UTC")}, //nolint:exhaustruct
+	}
+	tagsToDates := map[string]*time.Time{
+		"v1.0.0": date("2023-06-01 10:00:00 +0200 CEST"),
+		"v1.1.0": date("2023-02-08 23:00:00 +0000 UTC"),
+		"v1.2.0": date("2023-03-01 12:00:00 +0000 UTC"),
+		"v1.3.0": date("2023-04-01 12:00:00 +0000 UTC"),
+	}
+
+	assert.Equal(t, map[string]int{
+		"v1.0.0": 151,
+		"v1.2.0": -9,
+	}, releasesDatesDrift(releases, tagsToDates, 7))
+	assert.Equal(t, map[string]int{
+		"v1.0.0": 151,
+		"v1.1.0": 7,
+		"v1.2.0": -9,
+	}, releasesDatesDrift(releases, tagsToDates, 0))
+}
+
+func TestGenericPackageFileURL(t *testing.T) {
+	t.Parallel()
+
+	p := &Package{Generic: true, Name: "foo", Version: "1.0.0"}
+	assert.Equal(t, "https://gitlab.com/api/v4/projects/group%2Fproject/packages/generic/foo/1%2E0%2E0/a%2Etxt",
+		genericPackageFileURL("https://gitlab.com/api/v4", "group/project", p, "a.txt"))
+
+	p.Project = "group/artifacts"
+	assert.Equal(t, "https://gitlab.com/api/v4/projects/group%2Fartifacts/packages/generic/foo/1%2E0%2E0/a%2Etxt",
+		genericPackageFileURL("https://gitlab.com/api/v4", "group/project", p, "a.txt"))
+}
+
+func TestGetExpectedLinks(t *testing.T) {
+	t.Parallel()
+
+	packages := []Package{
+		{
+			ID: 1, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"a.txt", "b.txt"},
+			FilesInfo: map[string]FileInfo{"a.txt": {Size: 1024, SHA256: "abc"}, "b.txt": {Size: 2048, SHA256: "def"}},
+		},
+		{ID: 2, Generic: false, Name: "npm/bar", Version: "1.0.0"},
+	}
+
+	names := func(links map[string]link) []string {
+		result := []string{}
+		for name, l := range links {
+			assert.Equal(t, name, l.Name)
+			result = append(result, name)
+		}
+		return result
+	}
+
+	links, errE := getExpectedLinks(&Config{}, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.ElementsMatch(t, []string{"foo/a.txt", "foo/b.txt", "npm/bar"}, names(links))
+
+	links, errE = getExpectedLinks(&Config{LinkNameTemplate: "{{if .File}}{{.File}} ({{.PackageName}} {{.Version}}){{else}}{{.PackageName}} {{.Version}}{{end}}"}, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.ElementsMatch(t, []string{"a.txt (foo 1.0.0)", "b.txt (foo 1.0.0)", "npm/bar 1.0.0"}, names(links))
+	assert.Equal(t, "a.txt", *links["a.txt (foo 1.0.0)"].File)
+	assert.Nil(t, links["npm/bar 1.0.0"].File)
+
+	links, errE = getExpectedLinks(&Config{LinkNameTemplate: "{{.PackageName}}{{if .File}}/{{.File}} ({{.Size}} bytes, SHA-256 {{.SHA256}}){{end}}"}, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.ElementsMatch(t, []string{"foo/a.txt (1024 bytes, SHA-256 abc)", "foo/b.txt (2048 bytes, SHA-256 def)", "npm/bar"}, names(links))
+
+	links, errE = getExpectedLinks(&Config{MaxLinkFileSize: 1024}, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.ElementsMatch(t, []string{"foo/a.txt", "npm/bar"}, names(links))
+
+	var output strings.Builder
+	printExcludedLinkFiles(&output, &Config{MaxLinkFileSize: 1024}, []Release{{Tag: "v1.0.0"}}, map[string][]Package{"v1.0.0": packages})
+	assert.Equal(t, "Not linking file \"b.txt\" of package \"foo\" for tag \"v1.0.0\": its size 2048 bytes is larger than 1024 bytes.\n", output.String())
+
+	_, errE = getExpectedLinks(&Config{LinkNameTemplate: "{{.PackageName}}"}, packages, nil)
+	assert.EqualError(t, errE, "link name template rendered a duplicate name")
+	assert.Equal(t, "foo", errors.AllDetails(errE)["link"])
+	assert.Equal(t, []string{"file a.txt of package foo 1.0.0", "file b.txt of package foo 1.0.0"}, errors.AllDetails(errE)["sources"])
+
+	// Different packages with the same version collide as well.
+	_, errE = getExpectedLinks(&Config{LinkNameTemplate: "{{.Version}}{{if .File}}/{{.File}}{{end}}"}, append(packages, Package{ //nolint:exhaustruct
+		ID: 3, Generic: false, Name: "npm/baz", Version: "1.0.0",
+	}), nil)
+	assert.EqualError(t, errE, "link name template rendered a duplicate name")
+	assert.Equal(t, "1.0.0", errors.AllDetails(errE)["link"])
+	assert.Equal(t, []string{"package npm/bar 1.0.0", "package npm/baz 1.0.0"}, errors.AllDetails(errE)["sources"])
+
+	_, errE = getExpectedLinks(&Config{LinkNameTemplate: "{{.File}}"}, packages, nil)
+	assert.EqualError(t, errE, "link name template rendered an empty name")
+}
+
+func TestUpsertYankedRemovesLinks(t *testing.T) {
+	t.Parallel()
+
+	server, requests := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Path
+		switch {
+		case r.Method == http.MethodGet && path == "/api/v4/projects/1/releases/v1.0.0":
+			_, _ = w.Write([]byte(`{"tag_name": "v1.0.0", "created_at": "2023-01-01T00:00:00Z"}`))
+		case r.Method == http.MethodPut && path == "/api/v4/projects/1/releases/v1.0.0":
+			_, _ = w.Write([]byte(`{"tag_name": "v1.0.0"}`))
+		case r.Method == http.MethodGet && path == "/api/v4/projects/1/releases/v1.0.0/assets/links":
+			_, _ = w.Write([]byte(`[{"id": 1, "name": "foo/a.txt"}, {"id": 2, "name": "npm/bar"}]`))
+		case strings.HasPrefix(path, "/api/v4/projects/1/releases/v1.0.0/assets/links/"):
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	config := &Config{BaseURL: server.URL, Project: "1", AssetsExcludeYanked: true}
+	client, errE := newClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	packages := []Package{
+		{ID: 1, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"a.txt"}},
+		{ID: 2, Generic: false, Name: "npm/bar", Version: "1.0.0"},
+	}
+	releasedAt := mustParse("2023-01-01 00:00:00 +0000 UTC")
+
+	// Changelog assets are removed as well.
+	assets := []Asset{{Name: "Documentation", URL: "https://example.com/docs"}}
+	errE = Upsert(config, client, client, Release{Tag: "v1.0.0", Yanked: true, Assets: assets}, &releasedAt, nil, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []string{
+		"GET /api/v4/projects/1/releases/v1.0.0",
+		"PUT /api/v4/projects/1/releases/v1.0.0",
+		"GET /api/v4/projects/1/releases/v1.0.0/assets/links",
+		"DELETE /api/v4/projects/1/releases/v1.0.0/assets/links/1",
+		"DELETE /api/v4/projects/1/releases/v1.0.0/assets/links/2",
+	}, requests())
+
+	// Links of releases which are not yanked are kept.
+	errE = Upsert(config, client, client, Release{Tag: "v1.0.0", Yanked: false}, &releasedAt, nil, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []string{
+		"GET /api/v4/projects/1/releases/v1.0.0",
+		"PUT /api/v4/projects/1/releases/v1.0.0",
+		"GET /api/v4/projects/1/releases/v1.0.0/assets/links",
+		"PUT /api/v4/projects/1/releases/v1.0.0/assets/links/1",
+		"PUT /api/v4/projects/1/releases/v1.0.0/assets/links/2",
+	}, requests()[5:])
+}
+
+func TestUpsertUnchangedLinks(t *testing.T) {
+	t.Parallel()
+
+	var baseURL string
+	server, requests := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		path := r.URL.Path
+		switch {
+		case r.Method == http.MethodGet && path == "/api/v4/projects/1/releases/v1.0.0":
+			_, _ = w.Write([]byte(`{"tag_name": "v1.0.0", "created_at": "2023-01-01T00:00:00Z"}`))
+		case r.Method == http.MethodPut && path == "/api/v4/projects/1/releases/v1.0.0":
+			_, _ = w.Write([]byte(`{"tag_name": "v1.0.0"}`))
+		case r.Method == http.MethodGet && path == "/api/v4/projects/1/releases/v1.0.0/assets/links":
+			fileURL := baseURL + "/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/a%2Etxt"
+			packageURL := baseURL + "/group/project/-/packages/2"
+			_, _ = w.Write([]byte(fmt.Sprintf(
+				`[{"id": 1, "name": "foo/a.txt", "url": "%s", "direct_asset_url": "%s", "link_type": "other"}, `+
+					`{"id": 2, "name": "npm/bar", "url": "%s", "direct_asset_url": "%s", "link_type": "package"}]`,
+				fileURL, baseURL+"/group/project/-/releases/v1.0.0/downloads/foo/a.txt", packageURL, packageURL,
+			)))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+	baseURL = server.URL
+
+	config := &Config{BaseURL: server.URL, Project: "1"}
+	client, errE := newClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	packages := []Package{
+		{ID: 1, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"a.txt"}},
+		{ID: 2, Generic: false, Name: "npm/bar", Version: "1.0.0", WebPath: "/group/project/-/packages/2"},
+	}
+	releasedAt := mustParse("2023-01-01 00:00:00 +0000 UTC")
+
+	// Links which have not changed are not updated again.
+	errE = Upsert(config, client, client, Release{Tag: "v1.0.0"}, &releasedAt, nil, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []string{
+		"GET /api/v4/projects/1/releases/v1.0.0",
+		"PUT /api/v4/projects/1/releases/v1.0.0",
+		"GET /api/v4/projects/1/releases/v1.0.0/assets/links",
+	}, requests())
+}
+
+func TestUpsertCreateConflict(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		// Number of times GetRelease responds with 404 before the release is found.
+		missing  int
+		status   int
+		body     string
+		retries  int
+		requests []string
+		err      string
+	}{
+		{
+			1, http.StatusConflict, `{"message": "Release already exists"}`, 0,
+			[]string{"GET", "POST", "GET", "PUT"},
+			"",
+		},
+		{
+			2, http.StatusBadRequest, `{"message": "Release already exists"}`, 0,
+			[]string{"GET", "POST", "GET", "POST", "GET", "PUT"},
+			"",
+		},
+		{
+			1, http.StatusUnprocessableEntity, `{"message": "Release already exists"}`, 0,
+			[]string{"GET", "POST", "GET", "PUT"},
+			"",
+		},
+		{
+			100, http.StatusConflict, `{"message": "Release already exists"}`, 1,
+			[]string{"GET", "POST", "GET", "POST"},
+			"failed to create GitLab release for tag after retries",
+		},
+		{
+			1, http.StatusBadRequest, `{"message": "Tag name is invalid"}`, 0,
+			[]string{"GET", "POST"},
+			"failed to create GitLab release for tag",
+		},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			requests := []string{}
+			gets := 0
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				mu.Lock()
+				defer mu.Unlock()
+				path := r.URL.Path
+				switch {
+				case path == "/api/v4/projects/1/releases/v1.0.0" && r.Method == http.MethodGet:
+					requests = append(requests, r.Method)
+					gets++
+					if gets <= tt.missing {
+						http.NotFound(w, r)
+						return
+					}
+					_, _ = w.Write([]byte(`{"tag_name": "v1.0.0", "created_at": "2023-01-01T00:00:00Z"}`))
+				case path == "/api/v4/projects/1/releases" && r.Method == http.MethodPost:
+					requests = append(requests, r.Method)
+					w.WriteHeader(tt.status)
+					_, _ = w.Write([]byte(tt.body))
+				case path == "/api/v4/projects/1/releases/v1.0.0" && r.Method == http.MethodPut:
+					requests = append(requests, r.Method)
+					_, _ = w.Write([]byte(`{"tag_name": "v1.0.0"}`))
+				case path == "/api/v4/projects/1/releases/v1.0.0/assets/links" && r.Method == http.MethodGet:
+					_, _ = w.Write([]byte(`[]`))
+				default:
+					http.NotFound(w, r)
+				}
+			}))
+			t.Cleanup(server.Close)
+
+			config := &Config{BaseURL: server.URL, Project: "1", CreateConflictRetries: tt.retries}
+			client, errE := newClient(config, nil)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			releasedAt := mustParse("2023-01-01 00:00:00 +0000 UTC")
+			errE = Upsert(config, client, client, Release{Tag: "v1.0.0"}, &releasedAt, nil, nil, nil)
+			if tt.err != "" {
+				require.Error(t, errE)
+				assert.True(t, strings.HasPrefix(errE.Error(), tt.err+": "), errE.Error())
+			} else {
+				assert.NoError(t, errE, "% -+#.1v", errE)
+			}
+			mu.Lock()
+			assert.Equal(t, tt.requests, requests)
+			mu.Unlock()
+		})
+	}
+}
+
+func TestUpsertRecomputesDescription(t *testing.T) {
+	t.Parallel()
+
+	server, requests := fixturesServer(t, filepath.Join("testdata", "fixtures", "upsert.json"))
+
+	config := &Config{BaseURL: server.URL, Project: "1"}
+	client, errE := newClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	release := Release{Tag: "v1.0.0", Changes: "### Added\n- Feature."}
+	releasedAt := mustParse("2023-01-01 00:00:00 +0000 UTC")
+
+	errE = Upsert(config, client, client, release, &releasedAt, nil, nil, []string{"registry.example.com/foo:1.0.0", "registry.example.com/bar:1.0.0"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	// Image has been removed between runs.
+	errE = Upsert(config, client, client, release, &releasedAt, nil, nil, []string{"registry.example.com/foo:1.0.0"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	// All images have been removed between runs.
+	errE = Upsert(config, client, client, release, &releasedAt, nil, nil, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	descriptions := []string{}
+	for _, request := range requests() {
+		if request.Method == http.MethodPut {
+			descriptions = append(descriptions, request.Body["description"].(string)) //nolint:forcetypeassert
+		}
+	}
+	require.Len(t, descriptions, 3)
+	assert.Contains(t, descriptions[0], "registry.example.com/bar:1.0.0")
+	assert.Contains(t, descriptions[1], "registry.example.com/foo:1.0.0")
+	assert.NotContains(t, descriptions[1], "registry.example.com/bar:1.0.0")
+	assert.NotContains(t, descriptions[2], "Docker images")
+}
+
+func TestProjectPackages(t *testing.T) {
+	t.Parallel()
+
+	server, _ := fixturesServer(t, filepath.Join("testdata", "fixtures", "packages-broken.json"))
+
+	client, errE := newClient(&Config{BaseURL: server.URL}, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	for _, concurrency := range []int{0, 1, 2, 10} {
+		_, errE = projectPackages(client, "1", concurrency, maxGitLabPageSize)
+		assert.ErrorContains(t, errE, "failed to list GitLab files for package")
+		assert.Equal(t, "broken", errors.AllDetails(errE)["package"])
+	}
+
+	server, _ = fixturesServer(t, filepath.Join("testdata", "fixtures", "packages.json"))
+	client, errE = newClient(&Config{BaseURL: server.URL}, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	for _, concurrency := range []int{0, 1, 2, 10} {
+		packages, errE := projectPackages(client, "1", concurrency, maxGitLabPageSize)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.Equal(t, []Package{
+			{ID: 1, Generic: false, WebPath: "/packages/1", Name: "npm/bar", Version: "1.0.0", Project: "1"},
+			{
+				ID: 2, Generic: true, WebPath: "/packages/2", Name: "foo", Version: "0.1.0", Files: []string{"a-0.1.0.txt"}, Project: "1",
+				FilesInfo: map[string]FileInfo{"a-0.1.0.txt": {Size: 10, SHA256: "aaa"}},
+			},
+			{
+				ID: 3, Generic: true, WebPath: "/packages/3", Name: "foo", Version: "1.0.0", Files: []string{"a-1.0.0.txt", "b-1.0.0.txt"}, Project: "1",
+				FilesInfo: map[string]FileInfo{"a-1.0.0.txt": {Size: 20, SHA256: "bbb"}, "b-1.0.0.txt": {Size: 0, SHA256: ""}},
+			},
+		}, packages)
+	}
+}
+
+func TestPageSize(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		pageSize int
+		expected int
+	}{
+		{0, 100},
+		{-1, 100},
+		{1, 1},
+		{50, 50},
+		{100, 100},
+		{1000, 100},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.expected, pageSize(&Config{PageSize: tt.pageSize})) //nolint:exhaustruct
+		})
+	}
+}
+
+func TestProjectMilestones(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || r.URL.Path != "/api/v4/projects/1/milestones" {
+			http.NotFound(w, r)
+			return
+		}
+		assert.Equal(t, "100", r.URL.Query().Get("per_page"))
+		milestones := []map[string]interface{}{}
+		for _, m := range []struct{ title, state string }{{"1.0.0", "closed"}, {"1.1.0", "active"}} {
+			if state := r.URL.Query().Get("state"); state == "" || state == m.state {
+				milestones = append(milestones, map[string]interface{}{"title": m.title, "state": m.state})
+			}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(milestones)
+	}))
+	t.Cleanup(server.Close)
+
+	client, errE := newClient(&Config{BaseURL: server.URL}, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	tests := []struct {
+		state      string
+		milestones []string
+	}{
+		{"", []string{"1.0.0", "1.1.0"}},
+		{"all", []string{"1.0.0", "1.1.0"}},
+		{"active", []string{"1.1.0"}},
+		{"closed", []string{"1.0.0"}},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			milestones, errE := projectMilestones(client, "1", tt.state, maxGitLabPageSize)
+			require.NoError(t, errE, "% -+#.1v", errE)
+			assert.Equal(t, tt.milestones, milestones)
+		})
+	}
+}
+
+func TestSelectRelease(t *testing.T) {
+	t.Parallel()
+
+	releases := []Release{{Tag: "v1.1.0"}, {Tag: "v1.0.0", GitTag: "release/1.0.0"}} //nolint:exhaustruct
+
+	// The original git tag (with a stripped prefix) can be used as well.
+	for _, tag := range []string{"v1.0.0", "1.0.0", "release/1.0.0"} {
+		selected, errE := selectRelease(releases, tag)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.Equal(t, []Release{{Tag: "v1.0.0", GitTag: "release/1.0.0"}}, selected) //nolint:exhaustruct
+	}
+
+	_, errE := selectRelease(releases, "v2.0.0")
+	assert.EqualError(t, errE, "tag is not among changelog releases")
+	assert.Equal(t, "v2.0.0", errors.AllDetails(errE)["tag"])
+}
+
+func TestReselectReleases(t *testing.T) {
+	t.Parallel()
+
+	releases := []Release{{Tag: "v1.1.0"}, {Tag: "v1.0.0"}, {Tag: "v0.1.0"}} //nolint:exhaustruct
+	selected := []Release{releases[2], releases[0]}
+	releases[0].Ref = "abc"
+
+	assert.Equal(t, []Release{{Tag: "v0.1.0"}, {Tag: "v1.1.0", Ref: "abc"}}, reselectReleases(releases, selected)) //nolint:exhaustruct
+}
+
+func TestLatestBadgeReleases(t *testing.T) {
+	t.Parallel()
+
+	releases := []Release{
+		{Tag: "v1.2.0", Latest: true}, //nolint:exhaustruct
+		{Tag: "v1.1.0"},               //nolint:exhaustruct
+		{Tag: "v1.0.0"},               //nolint:exhaustruct
+		{Tag: "v0.1.0"},               //nolint:exhaustruct
+	}
+	gitLabReleases := []*gitlab.Release{
+		{TagName: "v1.1.0", Description: "<!-- marker -->\n\n" + latestBadge + "\n\nChanges."}, //nolint:exhaustruct
+		{TagName: "v1.0.0", Description: "<!-- marker -->\n\nChanges."},                        //nolint:exhaustruct
+	}
+
+	assert.Equal(t, []Release{
+		{Tag: "v0.1.0"},               //nolint:exhaustruct
+		{Tag: "v1.2.0", Latest: true}, //nolint:exhaustruct
+		{Tag: "v1.1.0"},               //nolint:exhaustruct
+	}, latestBadgeReleases(releases, []Release{releases[3]}, gitLabReleases))
+
+	// Already selected releases are not repeated.
+	assert.Equal(t, []Release{
+		{Tag: "v1.2.0", Latest: true}, //nolint:exhaustruct
+		{Tag: "v1.1.0"},               //nolint:exhaustruct
+	}, latestBadgeReleases(releases, []Release{releases[0]}, gitLabReleases))
+}
+
+func TestReleaseDateRange(t *testing.T) {
+	t.Parallel()
+
+	dateRange, errE := parseDateRange(&Config{AfterDate: "2023-01-01", BeforeDate: "2024-01-01"}) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.True(t, dateRange.IsSet())
+
+	tests := []struct {
+		date     time.Time
+		contains bool
+	}{
+		{time.Date(2022, 12, 31, 0, 0, 0, 0, time.UTC), false},
+		// After date is inclusive.
+		{time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC), true},
+		{time.Date(2023, 12, 31, 23, 59, 59, 0, time.UTC), true},
+		// Before date is exclusive.
+		{time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), false},
+		// Times are compared as calendar days in UTC.
+		{time.Date(2024, 1, 1, 0, 30, 0, 0, time.FixedZone("CET", 3600)), true},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			assert.Equal(t, tt.contains, dateRange.Contains(tt.date))
+		})
+	}
+
+	releases := []Release{
+		{Tag: "v2.0.0", Date: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Tag: "v1.1.0", Date: time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)},
+		{Tag: "v1.0.0", Date: time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)},
+		{Tag: "v0.1.0", Date: time.Date(2022, 6, 1, 0, 0, 0, 0, time.UTC)},
+	}
+	filtered := filterReleasesByDate(releases, dateRange)
+	assert.Equal(t, releases[1:3], filtered)
+
+	// Only one bound can be set.
+	before, errE := parseDateRange(&Config{BeforeDate: "2023-01-01"}) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, releases[3:], filterReleasesByDate(releases, before))
+
+	unset, errE := parseDateRange(&Config{}) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.False(t, unset.IsSet())
+
+	_, errE = parseDateRange(&Config{AfterDate: "2023-13-01"}) //nolint:exhaustruct
+	assert.ErrorContains(t, errE, "invalid date")
+	assert.Equal(t, "2023-13-01", errors.AllDetails(errE)["after"])
+
+	_, errE = parseDateRange(&Config{AfterDate: "2023-01-01", BeforeDate: "2023-01-01"}) //nolint:exhaustruct
+	assert.EqualError(t, errE, "after date should be before before date")
+}
+
+func TestDeleteAllExceptDateRange(t *testing.T) {
+	t.Parallel()
+
+	server, requests := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/1/releases":
+			_, _ = w.Write([]byte(`[
+				{"tag_name": "v2.0.0", "released_at": "2024-01-01T00:00:00Z"},
+				{"tag_name": "v1.1.0", "released_at": "2023-06-01T12:00:00Z"},
+				{"tag_name": "v1.0.0", "released_at": "2023-01-01T12:00:00Z"},
+				{"tag_name": "v0.1.0", "released_at": "2022-06-01T12:00:00Z"}
+			]`))
+		case r.Method == http.MethodDelete:
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	config := &Config{ //nolint:exhaustruct
+		BaseURL:    server.URL,
+		Project:    "1",
+		Token:      "token",
+		HTTPClient: server.Client(),
+		AfterDate:  "2023-01-01",
+		BeforeDate: "2024-01-01",
+	}
+	client, errE := newClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	// None of GitLab releases is in the changelog, but only those in the date range are deleted.
+	errE = DeleteAllExcept(config, client, client, []Release{})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []string{
+		"GET /api/v4/projects/1/releases",
+		"DELETE /api/v4/projects/1/releases/v1.0.0",
+		"DELETE /api/v4/projects/1/releases/v1.1.0",
+	}, requests())
+}
+
+func TestCreateMissingMilestones(t *testing.T) {
+	t.Parallel()
+
+	server, requests := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v4/projects/1/milestones" {
+			http.NotFound(w, r)
+			return
+		}
+		var options struct {
+			Title string `json:"title"`
+		}
+		err := json.NewDecoder(r.Body).Decode(&options)
+		assert.NoError(t, err)
+		_ = json.NewEncoder(w).Encode(map[string]interface{}{"id": 1, "title": options.Title})
+	})
+
+	client, errE := newClient(&Config{BaseURL: server.URL}, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	releases := []Release{{Tag: "v1.0.0"}, {Tag: "v1.1.0"}, {Tag: "v2.0.0"}}
+	tagsToMilestones := mapMilestonesToTags([]string{"Release 1.1.0"}, releases, false, false, tagTransformations)
+
+	errE = createMissingMilestones(client, "1", releases, tagsToMilestones)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	// Only milestones for releases without them are created.
+	assert.Equal(t, []string{"POST /api/v4/projects/1/milestones", "POST /api/v4/projects/1/milestones"}, requests())
+	assert.Equal(t, map[string][]string{
+		"v1.0.0": {"1.0.0"},
+		"v1.1.0": {"Release 1.1.0"},
+		"v2.0.0": {"2.0.0"},
+	}, tagsToMilestones)
+}
+
+func TestExtraReleases(t *testing.T) {
+	t.Parallel()
+
+	releases := []Release{{Tag: "v1.0.0"}} //nolint:exhaustruct
+	gitLabReleases := []*gitlab.Release{
+		{TagName: "v1.0.0", Description: descriptionMarker + "\n\nChanges."}, //nolint:exhaustruct
+		{TagName: "v0.9.0", Description: descriptionMarker + "\n\nChanges."}, //nolint:exhaustruct
+		{TagName: "v2.0.0-draft", Description: "Created by another tool."},   //nolint:exhaustruct
+	}
+
+	assert.Equal(t, []string{"v0.9.0", "v2.0.0-draft"}, extraReleases(&Config{}, releases, gitLabReleases))  //nolint:exhaustruct
+	assert.Equal(t, []string{"v0.9.0"}, extraReleases(&Config{OnlyManaged: true}, releases, gitLabReleases)) //nolint:exhaustruct
+}
+
+func TestWriteMapping(t *testing.T) {
+	t.Parallel()
+
+	releases := []Release{
+		{Tag: "v1.1.0"}, //nolint:exhaustruct
+		{Tag: "v1.0.0"}, //nolint:exhaustruct
+	}
+
+	var out strings.Builder
+	writeMapping(
+		&out, releases,
+		map[string][]string{"v1.0.0": {"1.0.0"}},
+		map[string][]Package{
+			"v1.0.0": {
+				{ID: 1, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"a.txt"}}, //nolint:exhaustruct
+				{ID: 2, Name: "bar", Version: "v1.0.0", Project: "group/artifacts"},             //nolint:exhaustruct
+			},
+		},
+		map[string][]string{"v1.1.0": {"registry.gitlab.com/group/project:1.1.0"}},
+	)
+	assert.Equal(t, ""+
+		"v1.1.0:\n"+
+		"  milestones:\n"+
+		"  packages:\n"+
+		"  images:\n"+
+		"    - registry.gitlab.com/group/project:1.1.0\n"+
+		"v1.0.0:\n"+
+		"  milestones:\n"+
+		"    - 1.0.0\n"+
+		"  packages:\n"+
+		"    - foo 1.0.0\n"+
+		"      - a.txt\n"+
+		"    - bar v1.0.0 (project group/artifacts)\n"+
+		"  images:\n",
+		out.String())
+}
+
+func TestWriteReport(t *testing.T) {
+	t.Parallel()
+
+	releases := []Release{
+		{Tag: "v1.2.0"}, //nolint:exhaustruct
+		{Tag: "v1.1.0"}, //nolint:exhaustruct
+		{Tag: "v1.0.0"}, //nolint:exhaustruct
+	}
+	drift := releasesDrift{
+		Missing: []string{"v1.2.0"},
+		Extra:   []string{"v0.9.0"},
+		Changed: []string{"v1.1.0"},
+	}
+
+	var out strings.Builder
+	errE := writeReport(&out, &Config{}, releases, drift) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, ""+
+		"TAG     STATUS\n"+
+		"v1.2.0  missing, would be created\n"+
+		"v1.1.0  out of date, would be updated\n"+
+		"v1.0.0  up to date\n"+
+		"v0.9.0  not in the changelog, would be deleted\n",
+		out.String())
+
+	out.Reset()
+	errE = writeReport(&out, &Config{NoCreate: true}, releases, drift) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Contains(t, out.String(), "v1.2.0  missing, would not be created\n")
+}
+
+func TestPlanReleases(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{}
+	releases := []Release{
+		{Tag: "v1.0.0", Changes: "- Feature."},
+		{Tag: "v1.1.0", Changes: "- Another feature."},
+		{Tag: "v2.0.0", Changes: "- Breaking change."},
+		{Tag: "v2.1.0", Changes: "- Fix.", Yanked: true},
+	}
+	tagsToImages := map[string][]string{"v2.0.0": {"registry.example.com/foo:2.0.0"}}
+
+	description := func(release Release) string {
+		d, errE := releaseDescription(config, release, tagsToImages[release.Tag])
+		require.NoError(t, errE, "% -+#.1v", errE)
+		return d
+	}
+
+	gitLabReleases := []*gitlab.Release{
+		{TagName: "v0.1.0", Name: "v0.1.0"},
+		{TagName: "v1.0.0", Name: "v1.0.0", Description: description(releases[0])},
+		{TagName: "v2.0.0", Name: "v2.0.0", Description: description(releases[2])},
+		// Yanked release is missing the suffix in its name.
+		{TagName: "v2.1.0", Name: "v2.1.0", Description: description(releases[3])},
+	}
+
+	drift, errE := planReleases(config, releases, gitLabReleases, tagsToImages)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, releasesDrift{
+		Missing: []string{"v1.1.0"},
+		Extra:   []string{"v0.1.0"},
+		Changed: []string{"v2.1.0"},
+	}, drift)
+	assert.False(t, drift.Empty())
+
+	gitLabReleases = []*gitlab.Release{
+		{TagName: "v1.0.0", Name: "v1.0.0", Description: description(releases[0])},
+	}
+	drift, errE = planReleases(config, releases[:1], gitLabReleases, tagsToImages)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.True(t, drift.Empty())
+}
+
+func TestSortLinks(t *testing.T) {
+	t.Parallel()
+
+	fileLink := func(name string) link {
+		file := name
+		return link{Name: "foo/" + name, ID: nil, Package: nil, File: &file}
+	}
+	names := func(links []link) []string {
+		result := []string{}
+		for _, l := range links {
+			result = append(result, l.Name)
+		}
+		return result
+	}
+
+	links := []link{
+		fileLink("checksums.txt.sig"),
+		fileLink("foo-linux-amd64"),
+		fileLink("SHA256SUMS"),
+		fileLink("foo-1.0.0-src.tar.gz"),
+		fileLink("foo-darwin-arm64"),
+		{Name: "npm/foo", ID: nil, Package: nil, File: nil},
+		fileLink("foo-linux-amd64.sha256"),
+	}
+
+	sortLinks(links, defaultLinkGroups)
+	assert.Equal(t, []string{
+		"foo/foo-1.0.0-src.tar.gz",
+		"foo/foo-darwin-arm64",
+		"foo/foo-linux-amd64",
+		"npm/foo",
+		"foo/SHA256SUMS",
+		"foo/checksums.txt.sig",
+		"foo/foo-linux-amd64.sha256",
+	}, names(links))
+
+	// Links not matching any group are put last.
+	sortLinks(links, []string{"*.sha256, SHA256SUMS", "*-darwin-*"})
+	assert.Equal(t, []string{
+		"foo/SHA256SUMS",
+		"foo/foo-linux-amd64.sha256",
+		"foo/foo-darwin-arm64",
+		"foo/checksums.txt.sig",
+		"foo/foo-1.0.0-src.tar.gz",
+		"foo/foo-linux-amd64",
+		"npm/foo",
+	}, names(links))
+}
+
+func TestOrderLinks(t *testing.T) {
+	t.Parallel()
+
+	fileLink := func(name string, id int) link {
+		file := name
+		l := link{Name: "foo/" + name, ID: nil, Package: nil, File: &file}
+		if id != 0 {
+			l.ID = &id
+		}
+		return l
+	}
+	names := func(links []link) []string {
+		result := []string{}
+		for _, l := range links {
+			result = append(result, l.Name)
+		}
+		return result
+	}
+	barID := 3
+
+	tests := []struct {
+		diff   linksDiff
+		delete []string
+		update []string
+		keep   []string
+		create []string
+	}{
+		{
+			// Already in order, new links are created after existing ones.
+			linksDiff{
+				Delete: []link{},
+				Update: []link{fileLink("foo-1.0.0-src.tar.gz", 1), fileLink("foo-linux-amd64", 2)},
+				Keep:   []link{},
+				Create: []link{fileLink("SHA256SUMS", 0)},
+			},
+			[]string{},
+			[]string{"foo/foo-1.0.0-src.tar.gz", "foo/foo-linux-amd64"},
+			[]string{},
+			[]string{"foo/SHA256SUMS"},
+		},
+		{
+			// A new link belongs before an existing one.
+			linksDiff{
+				Delete: []link{{Name: "bar", ID: &barID, Package: nil, File: nil}},
+				Update: []link{fileLink("foo-1.0.0-src.tar.gz", 1)},
+				Keep:   []link{fileLink("SHA256SUMS", 2)},
+				Create: []link{fileLink("foo-linux-amd64", 0)},
+			},
+			[]string{"bar", "foo/SHA256SUMS"},
+			[]string{"foo/foo-1.0.0-src.tar.gz"},
+			[]string{},
+			[]string{"foo/foo-linux-amd64", "foo/SHA256SUMS"},
+		},
+		{
+			// Existing links were created in the wrong order.
+			linksDiff{
+				Delete: []link{},
+				Update: []link{fileLink("foo-1.0.0-src.tar.gz", 2), fileLink("foo-linux-amd64", 1)},
+				Keep:   []link{},
+				Create: []link{},
+			},
+			[]string{"foo/foo-1.0.0-src.tar.gz", "foo/foo-linux-amd64"},
+			[]string{},
+			[]string{},
+			[]string{"foo/foo-1.0.0-src.tar.gz", "foo/foo-linux-amd64"},
+		},
+		{
+			// Unchanged links which are already in order are kept.
+			linksDiff{
+				Delete: []link{},
+				Update: []link{fileLink("foo-linux-amd64", 2)},
+				Keep:   []link{fileLink("foo-1.0.0-src.tar.gz", 1), fileLink("SHA256SUMS", 3)},
+				Create: []link{},
+			},
+			[]string{},
+			[]string{"foo/foo-linux-amd64"},
+			[]string{"foo/SHA256SUMS", "foo/foo-1.0.0-src.tar.gz"},
+			[]string{},
+		},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			diff := orderLinks(tt.diff, defaultLinkGroups)
+			assert.Equal(t, tt.delete, names(diff.Delete))
+			assert.Equal(t, tt.update, names(diff.Update))
+			assert.Equal(t, tt.keep, names(diff.Keep))
+			assert.Equal(t, tt.create, names(diff.Create))
+			for _, l := range diff.Delete {
+				assert.NotNil(t, l.ID)
+			}
+			for _, l := range diff.Create {
+				assert.Nil(t, l.ID)
+			}
+		})
+	}
+}
+
+func TestExtractAssets(t *testing.T) {
+	t.Parallel()
+
+	releases := []Release{
+		{Tag: "v1.1.0", Changes: "### Added\n- Feature.\n### Assets\n- [Installer](https://example.com/installer.exe)\n- ![Screenshot](https://example.com/s.png)\n- [Docs](https://example.com/docs) and [Manual](https://example.com/manual.pdf)\n- [Signature](https://example.com/installer.sig)[Checksum](https://example.com/installer.sha256)"}, //nolint:exhaustruct
+		{Tag: "v1.0.0", Changes: "### Added\n- Feature."}, //nolint:exhaustruct
+	}
+	errE := extractAssets(releases)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "### Added\n- Feature.", releases[0].Changes)
+	assert.Equal(t, []Asset{
+		{Name: "Installer", URL: "https://example.com/installer.exe"},
+		{Name: "Docs", URL: "https://example.com/docs"},
+		{Name: "Manual", URL: "https://example.com/manual.pdf"},
+		{Name: "Signature", URL: "https://example.com/installer.sig"},
+		{Name: "Checksum", URL: "https://example.com/installer.sha256"},
+	}, releases[0].Assets)
+	assert.Equal(t, "### Added\n- Feature.", releases[1].Changes)
+	assert.Nil(t, releases[1].Assets)
+
+	config := &Config{BaseURL: "https://gitlab.com", Project: "1"} //nolint:exhaustruct
+	links, errE := getExpectedLinks(config, nil, releases[0].Assets)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Len(t, links, 5)
+	options := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, "Installer", links["Installer"])
+	assert.Equal(t, "https://example.com/installer.exe", *options.URL)
+	assert.Nil(t, options.FilePath)
+
+	packages := []Package{{ID: 1, Name: "Docs", Version: "1.1.0", WebPath: "/-/packages/1"}} //nolint:exhaustruct
+	_, errE = getExpectedLinks(config, packages, releases[0].Assets)
+	assert.EqualError(t, errE, "changelog asset has a duplicate link name")
+	assert.Equal(t, []string{"package Docs 1.1.0", "changelog asset https://example.com/docs"}, errors.AllDetails(errE)["sources"])
+
+	releases = []Release{
+		{Tag: "v1.0.0", Changes: "### Assets\n- [A](https://example.com/a)\n- [A](https://example.com/b)"}, //nolint:exhaustruct
+	}
+	errE = extractAssets(releases)
+	assert.EqualError(t, errE, "duplicate asset name in the changelog")
+}
+
+func TestCreateReleaseLinkOptionsDownloadBaseURL(t *testing.T) {
+	t.Parallel()
+
+	file := "foo-linux-amd64"
+	fileLink := link{
+		Name:    "foo/" + file,
+		ID:      nil,
+		Package: &Package{Generic: true, Name: "foo", Version: "1.0.0"}, //nolint:exhaustruct
+		File:    &file,
+		Asset:   nil,
+	}
+	packageLink := link{
+		Name:    "npm/foo",
+		ID:      nil,
+		Package: &Package{WebPath: "/group/project/-/packages/1", Name: "foo", Version: "1.0.0"}, //nolint:exhaustruct
+		File:    nil,
+		Asset:   nil,
+	}
+
+	tests := []struct {
+		baseURL         string
+		downloadBaseURL string
+		fileURL         string
+	}{
+		{"https://gitlab.example.com", "", "https://gitlab.example.com/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/foo-linux-amd64"},
+		{"https://gitlab.example.com/", "", "https://gitlab.example.com/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/foo-linux-amd64"},
+		{"https://gitlab.example.com", "https://api.gitlab.example.com/", "https://api.gitlab.example.com/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/foo-linux-amd64"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			config := &Config{BaseURL: tt.baseURL, DownloadBaseURL: tt.downloadBaseURL, Project: "1"} //nolint:exhaustruct
+
+			options := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, fileLink.Name, fileLink)
+			assert.Equal(t, tt.fileURL, *options.URL)
+
+			// Links to package pages always use the web interface.
+			options = createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, packageLink.Name, packageLink)
+			assert.Equal(t, "https://gitlab.example.com/group/project/-/packages/1", *options.URL)
+		})
+	}
+}
+
+func TestCreateReleaseLinkOptions(t *testing.T) {
+	t.Parallel()
+
+	file := "foo-linux-amd64"
+	fileLink := link{
+		Name: "foo/" + file,
+		ID:   nil,
+		Package: &Package{ //nolint:exhaustruct
+			Generic: true,
+			Name:    "foo",
+			Version: "1.0.0",
+		},
+		File: &file,
+	}
+	packageLink := link{
+		Name: "npm/foo",
+		ID:   nil,
+		Package: &Package{ //nolint:exhaustruct
+			WebPath: "/group/project/-/packages/1",
+			Name:    "foo",
+			Version: "1.0.0",
+		},
+		File: nil,
+	}
+
+	tests := []struct {
+		prefix   string
+		filePath string
+	}{
+		{"", "/foo/foo-linux-amd64"},
+		{"binaries", "/binaries/foo/foo-linux-amd64"},
+		{"/binaries/", "/binaries/foo/foo-linux-amd64"},
+		{"dist/binaries", "/dist/binaries/foo/foo-linux-amd64"},
+	}
+
+	for k, tt := range tests {
+		tt := tt
+
+		t.Run(fmt.Sprintf("case=%d", k), func(t *testing.T) {
+			t.Parallel()
+
+			config := &Config{ //nolint:exhaustruct
+				BaseURL:            "https://gitlab.com/",
+				Project:            "group/project",
+				LinkFilepathPrefix: tt.prefix,
+			}
+
+			create := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, fileLink.Name, fileLink)
+			update := createReleaseLinkOptions[gitlab.UpdateReleaseLinkOptions](config, fileLink.Name, fileLink)
+			asset := createReleaseLinkOptions[gitlab.ReleaseAssetLinkOptions](config, fileLink.Name, fileLink)
+			require.NotNil(t, create.FilePath)
+			assert.Equal(t, tt.filePath, *create.FilePath)
+			// Create and update must use the same file path so that links do not churn.
+			assert.Equal(t, gitlab.UpdateReleaseLinkOptions(create), update)
+			assert.Equal(t, gitlab.ReleaseAssetLinkOptions(create), asset)
+			assert.Equal(t, "https://gitlab.com/api/v4/projects/group%2Fproject/packages/generic/foo/1%2E0%2E0/foo-linux-amd64", *create.URL)
+
+			options := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, packageLink.Name, packageLink)
+			assert.Nil(t, options.FilePath)
+			assert.Equal(t, "https://gitlab.com/group/project/-/packages/1", *options.URL)
+		})
+	}
+}
+
+func TestResolveChangelogPath(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	_, err := git.PlainInit(tempDir, false)
+	require.NoError(t, err)
+	subDir := filepath.Join(tempDir, "sub")
+	err = os.MkdirAll(filepath.Join(tempDir, "docs"), 0o700)
+	require.NoError(t, err)
+	err = os.MkdirAll(subDir, 0o700)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(tempDir, "docs", "CHANGELOG.md"), testChangelog, 0o600)
+	require.NoError(t, err)
+	err = os.WriteFile(filepath.Join(tempDir, "CHANGES.md"), testChangelog, 0o600)
+	require.NoError(t, err)
+	locations := []string{"CHANGELOG.md", "docs/CHANGELOG.md", "CHANGES.md"}
+
+	path, errE := resolveChangelogPath(&Config{}, subDir)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, filepath.Join(subDir, "CHANGELOG.md"), path)
+
+	// Discovery is relative to the repository root.
+	path, errE = resolveChangelogPath(&Config{DiscoverChangelog: true, ChangelogLocations: locations}, subDir)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, filepath.Join(tempDir, "docs", "CHANGELOG.md"), path)
+
+	// Explicit changelog path overrides discovery.
+	path, errE = resolveChangelogPath(&Config{Changelog: "HISTORY.md", DiscoverChangelog: true, ChangelogLocations: locations}, subDir)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, filepath.Join(subDir, "HISTORY.md"), path)
+
+	// URLs are used as they are.
+	path, errE = resolveChangelogPath(&Config{Changelog: "https://example.com/CHANGELOG.md"}, subDir)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "https://example.com/CHANGELOG.md", path)
+
+	_, errE = resolveChangelogPath(&Config{DiscoverChangelog: true, ChangelogLocations: []string{"CHANGELOG.md", "docs"}}, subDir)
+	assert.EqualError(t, errE, "changelog not found")
+	assert.Equal(t, []string{"CHANGELOG.md", "docs"}, errors.AllDetails(errE)["locations"])
+}
+
+func TestFetchChangelog(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/CHANGELOG.md" {
+			_, _ = w.Write(testChangelog)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+
+	assert.True(t, isChangelogURL(server.URL+"/CHANGELOG.md"))
+	assert.True(t, isChangelogURL("HTTPS://example.com/CHANGELOG.md"))
+	assert.False(t, isChangelogURL("CHANGELOG.md"))
+	assert.False(t, isChangelogURL("/tmp/http/CHANGELOG.md"))
+
+	data, errE := fetchChangelog(&Config{}, server.URL+"/CHANGELOG.md")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, testChangelog, data)
+	releases, _, errE := parseChangelogReleases(data, server.URL+"/CHANGELOG.md", []string{"Unreleased"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.NotEmpty(t, releases)
+
+	_, errE = fetchChangelog(&Config{}, server.URL+"/missing.md")
+	assert.EqualError(t, errE, "cannot fetch changelog: unexpected response status")
+	assert.Equal(t, http.StatusNotFound, errors.AllDetails(errE)["status"])
+	assert.Equal(t, server.URL+"/missing.md", errors.AllDetails(errE)["url"])
+}
+
+func TestSyncProjectNotAccessible(t *testing.T) {
+	t.Parallel()
+
+	for _, status := range []int{http.StatusUnauthorized, http.StatusForbidden, http.StatusNotFound} {
+		status := status
+
+		t.Run(fmt.Sprintf("status=%d", status), func(t *testing.T) {
+			t.Parallel()
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Content-Type", "application/json")
+				w.WriteHeader(status)
+				_, _ = w.Write([]byte(`{"message": "error"}`))
+			}))
+			t.Cleanup(server.Close)
+
+			// There is no changelog nor git repository in the directory, but the project is checked first.
+			errE := Sync(&Config{
+				ChangeTo: kong.ChangeDirFlag(t.TempDir()),
+				Project:  "group/project",
+				BaseURL:  server.URL,
+				Token:    "token",
+			})
+			assert.EqualError(t, errE, `cannot access GitLab project "group/project"; check --project and token permissions`)
+			assert.Equal(t, status, errors.AllDetails(errE)["status"])
+		})
+	}
+}
+
+func TestDeleteOrphanedLinks(t *testing.T) {
+	t.Parallel()
+
+	server, requests := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/1/releases":
+			_, _ = w.Write([]byte(`[{"tag_name": "v1.0.0"}, {"tag_name": "v0.9.0"}]`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/1/releases/v1.0.0/assets/links":
+			_, _ = w.Write([]byte(`[{"id": 1, "name": "foo/a.txt"}, {"id": 2, "name": "old"}]`))
+		case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/1/releases/v0.9.0/assets/links":
+			_, _ = w.Write([]byte(`[{"id": 3, "name": "foo/b.txt"}]`))
+		case r.Method == http.MethodDelete:
+			_, _ = w.Write([]byte(`{}`))
+		default:
+			http.NotFound(w, r)
+		}
+	})
+
+	config := &Config{BaseURL: server.URL, Project: "1", AssetsExcludeYanked: true}
+	client, errE := newClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	releases := []Release{{Tag: "v2.0.0"}, {Tag: "v1.0.0"}, {Tag: "v0.9.0", Yanked: true}}
+	tagsToPackages := map[string][]Package{
+		"v2.0.0": {{ID: 3, Generic: true, Name: "foo", Version: "2.0.0", Files: []string{"a.txt"}}},
+		"v1.0.0": {{ID: 2, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"a.txt"}}},
+		"v0.9.0": {{ID: 1, Generic: true, Name: "foo", Version: "0.9.0", Files: []string{"b.txt"}}},
+	}
+
+	errE = deleteOrphanedLinks(config, client, client, releases, tagsToPackages)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	assert.Equal(t, []string{
+		"GET /api/v4/projects/1/releases",
+		"GET /api/v4/projects/1/releases/v1.0.0/assets/links",
+		"DELETE /api/v4/projects/1/releases/v1.0.0/assets/links/2",
+		"GET /api/v4/projects/1/releases/v0.9.0/assets/links",
+		"DELETE /api/v4/projects/1/releases/v0.9.0/assets/links/3",
+	}, requests())
+}
+
+func TestUpsertReconcilesLinks(t *testing.T) {
+	t.Parallel()
+
+	tests := []struct {
+		name          string
+		createStatus  int
+		existingLinks string
+		expectedCalls []string
+		expectedLinks []string
+	}{
+		{
+			// Release has been created, but only some of its links.
+			"created",
+			http.StatusCreated,
+			`[{"id": 1, "name": "foo/a.txt"}]`,
+			[]string{
+				"GET /api/v4/projects/1/releases/v1.0.0",
+				"POST /api/v4/projects/1/releases",
+				"GET /api/v4/projects/1/releases/v1.0.0/assets/links",
+				"POST /api/v4/projects/1/releases/v1.0.0/assets/links",
+			},
+			[]string{"foo/b.txt"},
+		},
+		{
+			// Release has been created in the meantime, without links.
+			"conflict",
+			http.StatusConflict,
+			`[]`,
+			[]string{
+				"GET /api/v4/projects/1/releases/v1.0.0",
+				"POST /api/v4/projects/1/releases",
+				"GET /api/v4/projects/1/releases/v1.0.0",
+				"PUT /api/v4/projects/1/releases/v1.0.0",
+				"GET /api/v4/projects/1/releases/v1.0.0/assets/links",
+				"POST /api/v4/projects/1/releases/v1.0.0/assets/links",
+				"POST /api/v4/projects/1/releases/v1.0.0/assets/links",
+			},
+			[]string{"foo/a.txt", "foo/b.txt"},
+		},
+	}
+
+	for _, tt := range tests {
+		tt := tt
+
+		t.Run(tt.name, func(t *testing.T) {
+			t.Parallel()
+
+			var mu sync.Mutex
+			createdLinks := []string{}
+			created := false
+			server, requests := recordingServer(t, func(w http.ResponseWriter, r *http.Request) {
+				mu.Lock()
+				defer mu.Unlock()
+				w.Header().Set("Content-Type", "application/json")
+				switch {
+				case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/1/releases/v1.0.0":
+					if !created {
+						w.WriteHeader(http.StatusNotFound)
+						_, _ = w.Write([]byte(`{"message": "404 Not Found"}`))
+						return
+					}
+					_, _ = w.Write([]byte(`{"tag_name": "v1.0.0", "created_at": "2023-01-01T00:00:00Z"}`))
+				case r.Method == http.MethodPost && r.URL.Path == "/api/v4/projects/1/releases":
+					created = true
+					w.WriteHeader(tt.createStatus)
+					_, _ = w.Write([]byte(`{"tag_name": "v1.0.0"}`))
+				case r.Method == http.MethodPut && r.URL.Path == "/api/v4/projects/1/releases/v1.0.0":
+					_, _ = w.Write([]byte(`{"tag_name": "v1.0.0"}`))
+				case r.Method == http.MethodGet && r.URL.Path == "/api/v4/projects/1/releases/v1.0.0/assets/links":
+					_, _ = w.Write([]byte(tt.existingLinks))
+				case r.Method == http.MethodPut && r.URL.Path == "/api/v4/projects/1/releases/v1.0.0/assets/links/1":
+					_, _ = w.Write([]byte(`{"id": 1}`))
+				case r.Method == http.MethodPost && r.URL.Path == "/api/v4/projects/1/releases/v1.0.0/assets/links":
+					var options struct {
+						Name string `json:"name"`
+					}
+					err := json.NewDecoder(r.Body).Decode(&options)
+					assert.NoError(t, err)
+					createdLinks = append(createdLinks, options.Name)
+					w.WriteHeader(http.StatusCreated)
+					_, _ = w.Write([]byte(`{"id": 2}`))
+				default:
+					http.NotFound(w, r)
+				}
+			})
+
+			config := &Config{BaseURL: server.URL, Project: "1"}
+			client, errE := newClient(config, nil)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			packages := []Package{{ID: 1, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"a.txt", "b.txt"}}}
+			releasedAt := mustParse("2023-01-01 00:00:00 +0000 UTC")
+			errE = Upsert(config, client, client, Release{Tag: "v1.0.0"}, &releasedAt, nil, packages, nil)
+			require.NoError(t, errE, "% -+#.1v", errE)
+
+			assert.Equal(t, tt.expectedCalls, requests())
+			mu.Lock()
+			defer mu.Unlock()
+			assert.Equal(t, tt.expectedLinks, createdLinks)
+		})
+	}
+}
+
+func TestUpsertTransportError(t *testing.T) {
+	t.Parallel()
+
+	// A server which is closed refuses connections.
+	server := httptest.NewServer(http.NotFoundHandler())
+	server.Close()
+
+	config := &Config{BaseURL: server.URL, Project: "1"}
+	client, errE := newClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	releasedAt := mustParse("2023-01-01 00:00:00 +0000 UTC")
+	assert.NotPanics(t, func() {
+		errE = Upsert(config, client, client, Release{Tag: "v1.0.0"}, &releasedAt, nil, nil, nil)
+	})
+	assert.ErrorContains(t, errE, "failed to get GitLab release for tag")
+	assert.ErrorContains(t, errE, "connection refused")
+}
@@ -0,0 +1,124 @@
+package release
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/xanzy/go-gitlab"
+	"gitlab.com/tozd/go/errors"
+)
+
+// syncState records releases which have been successfully synced, so that
+// an interrupted sync can be resumed.
+type syncState struct {
+	// Releases maps tags of synced releases to hashes of their synced content.
+	Releases map[string]string `json:"releases"`
+}
+
+// readSyncState reads the sync state from the file at path.
+// If the file does not exist, empty state is returned.
+func readSyncState(path string) (*syncState, errors.E) {
+	state := &syncState{
+		Releases: map[string]string{},
+	}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return state, nil
+	} else if err != nil {
+		errE := errors.WithMessage(err, "cannot read state file")
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+	err = json.Unmarshal(data, state)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot parse state file")
+		errors.Details(errE)["path"] = path
+		return nil, errE
+	}
+	if state.Releases == nil {
+		state.Releases = map[string]string{}
+	}
+	return state, nil
+}
+
+// write writes the sync state to the file at path. The file is replaced
+// atomically so that it is not corrupted if the program is interrupted.
+func (s *syncState) write(path string) errors.E {
+	data, err := json.MarshalIndent(s, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	data = append(data, '\n')
+	err = writeFileAtomically(path, data)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot write state file")
+		errors.Details(errE)["path"] = path
+		return errE
+	}
+	return nil
+}
+
+// prune removes from the state releases which are not among releases.
+func (s *syncState) prune(releases []Release) {
+	tags := map[string]bool{}
+	for _, release := range releases {
+		tags[release.Tag] = true
+	}
+	for tag := range s.Releases {
+		if !tags[tag] {
+			delete(s.Releases, tag)
+		}
+	}
+}
+
+// releaseHash returns a hash of everything Upsert syncs for the release:
+// its name, description, date, milestones, and links.
+func releaseHash(
+	config *Config, release Release, releasedAt *time.Time,
+	milestones []string, packages []Package, images []string,
+) (string, errors.E) {
+	name, errE := releaseName(config, release)
+	if errE != nil {
+		return "", errE
+	}
+	description, errE := releaseDescription(config, release, images)
+	if errE != nil {
+		return "", errE
+	}
+	expectedLinks, errE := getExpectedLinks(config, releasePackages(config, release, packages), releaseAssets(config, release))
+	if errE != nil {
+		return "", errE
+	}
+	links := make([]gitlab.CreateReleaseLinkOptions, 0, len(expectedLinks))
+	for _, l := range expectedLinks {
+		links = append(links, createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, l.Name, l))
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return *links[i].Name < *links[j].Name
+	})
+	sortedMilestones := append([]string{}, milestones...)
+	sort.Strings(sortedMilestones)
+
+	data, err := json.Marshal(struct {
+		Name        string                            `json:"name"`
+		Description string                            `json:"description"`
+		ReleasedAt  *time.Time                        `json:"releasedAt"`
+		Milestones  []string                          `json:"milestones"`
+		Links       []gitlab.CreateReleaseLinkOptions `json:"links"`
+	}{
+		Name:        name,
+		Description: description,
+		ReleasedAt:  releasedAt,
+		Milestones:  sortedMilestones,
+		Links:       links,
+	})
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+	hash := sha256.Sum256(data)
+	return hex.EncodeToString(hash[:]), nil
+}
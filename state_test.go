@@ -0,0 +1,82 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSyncState(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "state.json")
+
+	state, errE := readSyncState(path)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, map[string]string{}, state.Releases)
+
+	state.Releases["v1.0.0"] = "abc"
+	state.Releases["v0.9.0"] = "def"
+	errE = state.write(path)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	state, errE = readSyncState(path)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, map[string]string{"v1.0.0": "abc", "v0.9.0": "def"}, state.Releases)
+
+	state.prune([]Release{{Tag: "v1.0.0"}}) //nolint:exhaustruct
+	assert.Equal(t, map[string]string{"v1.0.0": "abc"}, state.Releases)
+
+	// No temporary files are left behind.
+	entries, err := os.ReadDir(tempDir)
+	require.NoError(t, err)
+	assert.Len(t, entries, 1)
+
+	err = os.WriteFile(path, []byte("{"), 0o600)
+	require.NoError(t, err)
+	_, errE = readSyncState(path)
+	assert.ErrorContains(t, errE, "cannot parse state file")
+}
+
+func TestReleaseHash(t *testing.T) {
+	t.Parallel()
+
+	config := &Config{ //nolint:exhaustruct
+		BaseURL: "https://gitlab.com",
+		Project: "group/project",
+	}
+	release := Release{ //nolint:exhaustruct
+		Tag:     "v1.0.0",
+		Changes: "### Added\n\n- Feature.\n",
+	}
+	releasedAt := time.Date(2023, 1, 1, 12, 0, 0, 0, time.UTC)
+	packages := []Package{
+		{ID: 1, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"a.txt"}}, //nolint:exhaustruct
+	}
+
+	hash, errE := releaseHash(config, release, &releasedAt, []string{"1.0.0"}, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	same, errE := releaseHash(config, release, &releasedAt, []string{"1.0.0"}, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, hash, same)
+
+	changed := release
+	changed.Changes = "### Added\n\n- Another feature.\n"
+	other, errE := releaseHash(config, changed, &releasedAt, []string{"1.0.0"}, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.NotEqual(t, hash, other)
+
+	other, errE = releaseHash(config, release, &releasedAt, []string{}, packages, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.NotEqual(t, hash, other)
+
+	other, errE = releaseHash(config, release, &releasedAt, []string{"1.0.0"}, nil, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.NotEqual(t, hash, other)
+}
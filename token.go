@@ -0,0 +1,107 @@
+package release
+
+import (
+	"bytes"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// tokenEnv is the environment variable from which the GitLab API token is read
+// if it is not provided otherwise.
+const tokenEnv = "GITLAB_API_TOKEN"
+
+// readTokenFile reads the GitLab API token from the file at path. The file should not
+// be accessible by group or others.
+func readTokenFile(path string) (string, errors.E) {
+	info, err := os.Stat(path)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot read token file")
+		errors.Details(errE)["path"] = path
+		return "", errE
+	}
+	// Windows does not have Unix file permissions.
+	if runtime.GOOS != "windows" && info.Mode().Perm()&0o077 != 0 {
+		errE := errors.New("token file should not be accessible by group or others")
+		errors.Details(errE)["path"] = path
+		errors.Details(errE)["mode"] = info.Mode().Perm().String()
+		return "", errE
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		errE := errors.WithMessage(err, "cannot read token file")
+		errors.Details(errE)["path"] = path
+		return "", errE
+	}
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		errE := errors.New("token file is empty")
+		errors.Details(errE)["path"] = path
+		return "", errE
+	}
+	return token, nil
+}
+
+// runTokenCommand runs command using the shell and returns its output as
+// the GitLab API token.
+func runTokenCommand(command string) (string, errors.E) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	output, err := cmd.Output()
+	if err != nil {
+		errE := errors.WithMessage(err, "token command failed")
+		errors.Details(errE)["command"] = command
+		errors.Details(errE)["stderr"] = strings.TrimSpace(stderr.String())
+		return "", errE
+	}
+	token := strings.TrimSpace(string(output))
+	if token == "" {
+		errE := errors.New("token command returned an empty token")
+		errors.Details(errE)["command"] = command
+		return "", errE
+	}
+	return token, nil
+}
+
+// resolveToken returns the GitLab API token. The token provided explicitly is used
+// first, then the output of the token command, then the content of the token file,
+// then the GITLAB_API_TOKEN environment variable, and at the end the read token.
+func resolveToken(config *Config) (string, errors.E) {
+	if config.Token != "" {
+		return config.Token, nil
+	}
+	if config.TokenCommand != "" {
+		return runTokenCommand(config.TokenCommand)
+	}
+	if config.TokenFile != "" {
+		return readTokenFile(config.TokenFile)
+	}
+	if token := os.Getenv(tokenEnv); token != "" {
+		return token, nil
+	}
+	// With only the read token provided, it is used for all requests.
+	if config.ReadToken != "" {
+		return config.ReadToken, nil
+	}
+	return "", errors.New("GitLab API token is required; use --token, --token-command, --token-file, or " + tokenEnv + " environment variable")
+}
+
+// readConfig returns configuration to use for requests which only read. If the read token
+// is configured, it is used instead of the token, otherwise config itself is returned.
+func readConfig(config *Config) *Config {
+	if config.ReadToken == "" || config.ReadToken == config.Token {
+		return config
+	}
+	c := *config
+	c.Token = config.ReadToken
+	return &c
+}
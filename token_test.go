@@ -0,0 +1,97 @@
+package release
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadTokenFile(t *testing.T) {
+	t.Parallel()
+
+	tempDir := t.TempDir()
+
+	path := filepath.Join(tempDir, "token")
+	err := os.WriteFile(path, []byte("  secret\n"), 0o600)
+	require.NoError(t, err)
+	token, errE := readTokenFile(path)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "secret", token)
+
+	path = filepath.Join(tempDir, "empty")
+	err = os.WriteFile(path, []byte("\n"), 0o600)
+	require.NoError(t, err)
+	_, errE = readTokenFile(path)
+	assert.EqualError(t, errE, "token file is empty")
+
+	_, errE = readTokenFile(filepath.Join(tempDir, "missing"))
+	assert.ErrorContains(t, errE, "cannot read token file")
+
+	if runtime.GOOS != "windows" {
+		path = filepath.Join(tempDir, "public")
+		err = os.WriteFile(path, []byte("secret"), 0o600)
+		require.NoError(t, err)
+		err = os.Chmod(path, 0o644) //nolint:gosec
+		require.NoError(t, err)
+		_, errE = readTokenFile(path)
+		assert.EqualError(t, errE, "token file should not be accessible by group or others")
+	}
+}
+
+func TestRunTokenCommand(t *testing.T) {
+	t.Parallel()
+
+	if runtime.GOOS == "windows" {
+		t.Skip("test uses Unix shell")
+	}
+
+	token, errE := runTokenCommand("printf ' secret\\n'")
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "secret", token)
+
+	_, errE = runTokenCommand("true")
+	assert.EqualError(t, errE, "token command returned an empty token")
+
+	_, errE = runTokenCommand("echo failure >&2; exit 1")
+	assert.EqualError(t, errE, "token command failed: exit status 1")
+}
+
+//nolint:paralleltest
+func TestResolveToken(t *testing.T) {
+	tempDir := t.TempDir()
+	path := filepath.Join(tempDir, "token")
+	err := os.WriteFile(path, []byte("from-file"), 0o600)
+	require.NoError(t, err)
+	command := "echo from-command"
+
+	t.Setenv(tokenEnv, "from-env")
+
+	tests := []struct {
+		config Config
+		want   string
+	}{
+		{Config{Token: "explicit", TokenCommand: command, TokenFile: path}, "explicit"},
+		{Config{TokenCommand: command, TokenFile: path}, "from-command"},
+		{Config{TokenFile: path}, "from-file"},
+		{Config{}, "from-env"},
+	}
+	for _, tt := range tests {
+		tt := tt
+		token, errE := resolveToken(&tt.config)
+		require.NoError(t, errE, "% -+#.1v", errE)
+		assert.Equal(t, tt.want, token)
+	}
+
+	t.Setenv(tokenEnv, "")
+	// With only the read token, it is used for all requests.
+	token, errE := resolveToken(&Config{ReadToken: "read"})
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, "read", token)
+
+	_, errE = resolveToken(&Config{})
+	assert.EqualError(t, errE, "GitLab API token is required; use --token, --token-command, --token-file, or GITLAB_API_TOKEN environment variable")
+}
@@ -1,6 +1,8 @@
 package release
 
 import (
+	"os"
+	"path/filepath"
 	"regexp"
 	"strings"
 )
@@ -23,3 +25,21 @@ func refSlug(s string) string {
 	s = slugTrimRegex.ReplaceAllString(s, "")
 	return s
 }
+
+// writeFileAtomically writes data to the file at path. The file is replaced
+// atomically so that it is not corrupted if the program is interrupted.
+func writeFileAtomically(path string, data []byte) error {
+	f, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	defer os.Remove(f.Name()) //nolint:errcheck
+	_, err = f.Write(data)
+	if err2 := f.Close(); err == nil {
+		err = err2
+	}
+	if err != nil {
+		return err //nolint:wrapcheck
+	}
+	return os.Rename(f.Name(), path) //nolint:wrapcheck
+}
@@ -0,0 +1,119 @@
+package release
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+
+	"github.com/xanzy/go-gitlab"
+	"gitlab.com/tozd/go/errors"
+)
+
+// linkStatus makes a HEAD request to url and returns the response status code.
+// If token is not empty, it is sent as the GitLab API token.
+func linkStatus(httpClient *http.Client, url, token string) (int, errors.E) {
+	req, err := http.NewRequest(http.MethodHead, url, nil) //nolint:noctx
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	if token != "" {
+		req.Header.Set("Private-Token", token)
+	}
+	res, err := httpClient.Do(req)
+	if err != nil {
+		return 0, errors.WithStack(err)
+	}
+	_, _ = io.Copy(io.Discard, res.Body)
+	res.Body.Close()
+	return res.StatusCode, nil
+}
+
+// verifyLinks makes a HEAD request for each link the release should have, to check
+// that it resolves. Links to package pages are not verified because they require
+// a GitLab web session for private projects. Requests for links under the GitLab
+// download API URL are made with gitLabHTTPClient (with custom headers and basic auth),
+// and all other requests with plain httpClient. The GitLab API token is sent only
+// for links to download files of generic packages.
+//
+// For links which do not resolve, a warning is made, or an error is returned
+// if config.VerifyLinksFatal is set.
+func verifyLinks(
+	config *Config, httpClient, gitLabHTTPClient *http.Client, release Release, packages []Package, warnings *warnings,
+) errors.E {
+	expectedLinks, errE := getExpectedLinks(config, releasePackages(config, release, packages), releaseAssets(config, release))
+	if errE != nil {
+		return errE
+	}
+	names := make([]string, 0, len(expectedLinks))
+	for name := range expectedLinks {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	downloadPrefix := downloadAPIURL(config) + "/"
+	for _, name := range names {
+		l := expectedLinks[name]
+		if l.Asset == nil && l.File == nil {
+			continue
+		}
+		options := createReleaseLinkOptions[gitlab.CreateReleaseLinkOptions](config, l.Name, l)
+		url := *options.URL
+		client := httpClient
+		token := ""
+		if strings.HasPrefix(url, downloadPrefix) {
+			client = gitLabHTTPClient
+			if l.File != nil {
+				token = config.Token
+			}
+		}
+
+		status, errE := linkStatus(client, url, token)
+		var problem string
+		if errE != nil {
+			problem = errE.Error()
+		} else if status < 200 || status > 299 {
+			problem = fmt.Sprintf("status %d", status)
+		} else {
+			continue
+		}
+
+		if config.VerifyLinksFatal {
+			errE = errors.New("release link does not resolve")
+			errors.Details(errE)["link"] = l.Name
+			errors.Details(errE)["url"] = url
+			errors.Details(errE)["problem"] = problem
+			errors.Details(errE)["release"] = release.Tag
+			return errE
+		}
+		warnings.Warnf("link \"%s\" (%s) of release \"%s\" does not resolve: %s.", l.Name, url, release.Tag, problem)
+	}
+	return nil
+}
+
+// checkLinkCount fetches links of the synced release again and warns if their number
+// differs from the number of expected links, which means that some links have not
+// been created (or deleted) even if GitLab API reported success.
+func checkLinkCount(config *Config, client *gitlab.Client, release Release, packages []Package, warnings *warnings) errors.E {
+	links, errE := releaseLinks(client, config.Project, release, pageSize(config))
+	if errE != nil {
+		// With NoCreate the release might not exist, so there is nothing to check.
+		var errorResponse *gitlab.ErrorResponse
+		if config.NoCreate && errors.As(errE, &errorResponse) && errorResponse.Response.StatusCode == http.StatusNotFound {
+			return nil
+		}
+		return errE
+	}
+	expectedLinks, errE := getExpectedLinks(config, releasePackages(config, release, packages), releaseAssets(config, release))
+	if errE != nil {
+		return errE
+	}
+	if len(links) != len(expectedLinks) {
+		warnings.Warnf(
+			"release \"%s\" has %d links after syncing, but %d links are expected.",
+			release.Tag, len(links), len(expectedLinks),
+		)
+	}
+	return nil
+}
@@ -0,0 +1,122 @@
+package release
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
+)
+
+func TestVerifyLinks(t *testing.T) {
+	t.Parallel()
+
+	var mu sync.Mutex
+	credentials := map[string][]string{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, http.MethodHead, r.Method)
+		mu.Lock()
+		_, password, _ := r.BasicAuth()
+		credentials[r.URL.Path] = []string{r.Header.Get("Private-Token"), password, r.Header.Get("X-Custom")}
+		mu.Unlock()
+		switch r.URL.EscapedPath() {
+		case "/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/a%2Etxt", "/docs":
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	packages := []Package{
+		{ID: 1, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"a.txt"}},                    //nolint:exhaustruct
+		{ID: 2, Generic: false, Name: "npm/bar", Version: "1.0.0", WebPath: "/group/project/-/packages/2"}, //nolint:exhaustruct
+	}
+	release := Release{Tag: "v1.0.0", Assets: []Asset{{Name: "Documentation", URL: server.URL + "/docs"}}} //nolint:exhaustruct
+
+	config := &Config{ //nolint:exhaustruct
+		BaseURL:    server.URL,
+		Project:    "1",
+		Token:      "token",
+		BasicAuth:  "user:pass",
+		Headers:    []string{"X-Custom: secret"},
+		HTTPClient: server.Client(),
+	}
+	httpClient, errE := newPlainHTTPClient(config)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	gitLabHTTPClient, errE := newHTTPClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	w := newWarnings(io.Discard)
+	errE = verifyLinks(config, httpClient, gitLabHTTPClient, release, packages, w)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Empty(t, w.Messages())
+	mu.Lock()
+	// Credentials are sent only to GitLab API and package pages are not verified.
+	assert.Equal(t, map[string][]string{
+		"/api/v4/projects/1/packages/generic/foo/1.0.0/a.txt": {"token", "pass", "secret"},
+		"/docs": {"", "", ""},
+	}, credentials)
+	mu.Unlock()
+
+	packages[0].Files = append(packages[0].Files, "b.txt")
+	errE = verifyLinks(config, httpClient, gitLabHTTPClient, release, packages, w)
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []string{
+		`link "foo/b.txt" (` + server.URL + `/api/v4/projects/1/packages/generic/foo/1%2E0%2E0/b%2Etxt) of release "v1.0.0" does not resolve: status 404.`,
+	}, w.Messages())
+
+	config.VerifyLinksFatal = true
+	errE = verifyLinks(config, httpClient, gitLabHTTPClient, release, packages, w)
+	assert.EqualError(t, errE, "release link does not resolve")
+	assert.Equal(t, "foo/b.txt", errors.AllDetails(errE)["link"])
+	assert.Equal(t, "status 404", errors.AllDetails(errE)["problem"])
+}
+
+func TestCheckLinkCount(t *testing.T) {
+	t.Parallel()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v4/projects/1/releases/v1.0.0/assets/links":
+			w.Header().Set("Content-Type", "application/json")
+			_, _ = w.Write([]byte(`[{"id": 1, "name": "foo/a.txt", "url": "https://example.com/a.txt"}]`))
+		default:
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusNotFound)
+			_, _ = w.Write([]byte(`{"message": "404 Not found"}`))
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	config := &Config{BaseURL: server.URL, Project: "1", Token: "token", HTTPClient: server.Client()} //nolint:exhaustruct
+	client, errE := newClient(config, nil)
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	packages := []Package{
+		{ID: 1, Generic: true, Name: "foo", Version: "1.0.0", Files: []string{"a.txt", "b.txt"}}, //nolint:exhaustruct
+	}
+
+	w := newWarnings(io.Discard)
+	errE = checkLinkCount(config, client, Release{Tag: "v1.0.0"}, packages[:1], w) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Equal(t, []string{`release "v1.0.0" has 1 links after syncing, but 2 links are expected.`}, w.Messages())
+
+	packages[0].Files = []string{"a.txt"}
+	w = newWarnings(io.Discard)
+	errE = checkLinkCount(config, client, Release{Tag: "v1.0.0"}, packages, w) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Empty(t, w.Messages())
+
+	// A missing release is an error, unless releases are not created.
+	errE = checkLinkCount(config, client, Release{Tag: "v2.0.0"}, nil, w) //nolint:exhaustruct
+	assert.ErrorContains(t, errE, "failed to list GitLab release links for tag")
+	config.NoCreate = true
+	errE = checkLinkCount(config, client, Release{Tag: "v2.0.0"}, nil, w) //nolint:exhaustruct
+	require.NoError(t, errE, "% -+#.1v", errE)
+	assert.Empty(t, w.Messages())
+}
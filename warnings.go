@@ -0,0 +1,64 @@
+package release
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"sync"
+
+	"gitlab.com/tozd/go/errors"
+)
+
+// warnings prints warnings and collects them, so that sync can fail
+// if any warning has been emitted.
+//
+// A nil *warnings only prints warnings to stderr.
+type warnings struct {
+	Writer io.Writer
+
+	mu       sync.Mutex
+	messages []string
+}
+
+func newWarnings(writer io.Writer) *warnings {
+	return &warnings{
+		Writer:   writer,
+		mu:       sync.Mutex{},
+		messages: []string{},
+	}
+}
+
+// Warnf prints and records a warning. The message should end with a period
+// and should not end with a newline.
+func (w *warnings) Warnf(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	if w == nil {
+		fmt.Fprintf(os.Stderr, "Warning: %s\n", message)
+		return
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	w.messages = append(w.messages, message)
+	fmt.Fprintf(w.Writer, "Warning: %s\n", message)
+}
+
+// Messages returns all recorded warnings.
+func (w *warnings) Messages() []string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	return append([]string{}, w.messages...)
+}
+
+// Error returns an error listing all recorded warnings, if there are any.
+func (w *warnings) Error() errors.E {
+	messages := w.Messages()
+	if len(messages) == 0 {
+		return nil
+	}
+	errE := errors.New("warnings have been emitted")
+	errors.Details(errE)["warnings"] = messages
+	return errE
+}
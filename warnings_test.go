@@ -0,0 +1,28 @@
+package release
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gitlab.com/tozd/go/errors"
+)
+
+func TestWarnings(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	w := newWarnings(&out)
+	errE := w.Error()
+	require.NoError(t, errE, "% -+#.1v", errE)
+
+	w.Warnf("first %s.", "warning")
+	w.Warnf("second warning.")
+	assert.Equal(t, "Warning: first warning.\nWarning: second warning.\n", out.String())
+	assert.Equal(t, []string{"first warning.", "second warning."}, w.Messages())
+
+	errE = w.Error()
+	assert.EqualError(t, errE, "warnings have been emitted")
+	assert.Equal(t, []string{"first warning.", "second warning."}, errors.AllDetails(errE)["warnings"])
+}